@@ -1,27 +1,65 @@
 // tools/main.js
-const { analyzeDirectory } = require('./src/file_processor');
+const fs = require('fs');
+const { analyzeDirectory, walkDirectory } = require('./src/file_processor');
 
-// Get the target directory from the command-line arguments.
+// Usage: node main.js [--ndjson] [--skip-ext=.go,.rs] <directory>
 // process.argv[0] is 'node', process.argv[1] is 'main.js'.
-const targetDir = process.argv[2];
+const args = process.argv.slice(2);
+const ndjson = args.includes('--ndjson');
+const targetDir = args.find(arg => !arg.startsWith('--'));
+
+// Extensions the backend analyzes itself; the walk leaves them out.
+const skipArg = args.find(arg => arg.startsWith('--skip-ext='));
+const skipExtensions = new Set(skipArg ? skipArg.slice('--skip-ext='.length).split(',').filter(Boolean) : []);
 
 if (!targetDir) {
   console.error('Error: Please provide a directory to analyze.');
   process.exit(1);
 }
 
+// pauseCell backs Atomics.wait, used below as a synchronous sleep.
+const pauseCell = new Int32Array(new SharedArrayBuffer(4));
+
+// writeLine writes synchronously so records are never interleaved or lost on exit,
+// and so a slow reader on the Go side naturally throttles the walk.
+function writeLine(record) {
+  const buf = Buffer.from(JSON.stringify(record) + '\n');
+  let offset = 0;
+  while (offset < buf.length) {
+    try {
+      offset += fs.writeSync(1, buf, offset);
+    } catch (err) {
+      // stdout may be a non-blocking pipe; wait briefly for the reader to drain it
+      if (err.code !== 'EAGAIN') throw err;
+      Atomics.wait(pauseCell, 0, 0, 5);
+    }
+  }
+}
+
 // --- Main Execution Logic ---
 try {
-  // Call the single, powerful function from file_processor.js
-  const analysisResults = analyzeDirectory(targetDir);
+  if (ndjson) {
+    // Streaming protocol: one {"kind":"file"} record per analyzed file, followed by
+    // a single {"kind":"end"} record carrying the file count. A missing end record
+    // tells the backend that the output was truncated.
+    let count = 0;
+    walkDirectory(targetDir, file => {
+      writeLine({ kind: 'file', file });
+      count++;
+    }, skipExtensions);
+    writeLine({ kind: 'end', files: count });
+  } else {
+    // Call the single, powerful function from file_processor.js
+    const analysisResults = analyzeDirectory(targetDir, skipExtensions);
 
-  const finalOutput = {
-    files: analysisResults,
-  };
+    const finalOutput = {
+      files: analysisResults,
+    };
 
-  // The most important step: Print the final JSON to standard output.
-  // The Go backend will capture this output.
-  console.log(JSON.stringify(finalOutput, null, 2));
+    // The most important step: Print the final JSON to standard output.
+    // The Go backend will capture this output.
+    console.log(JSON.stringify(finalOutput, null, 2));
+  }
 
 } catch (error) {
   console.error(`NODE.JS ANALYZER ERROR: ${error.message}`);
@@ -1,3 +1,5 @@
+const { location, param } = require('../location');
+
 function extract(tree, config) {
     const results = { functions: [], classes: [], imports: [] };
     const contextStack = [];
@@ -6,8 +8,8 @@ function extract(tree, config) {
         if (!parametersNode) return [];
         return parametersNode.children
             .filter(c => c.type === 'parameter_declaration')
-            .map(p => p.childForFieldName('declarator')?.text || '')
-            .filter(Boolean);
+            .map(p => param(p.childForFieldName('declarator')?.text || '', p))
+            .filter(p => p.name);
     }
 
     function traverse(node) {
@@ -17,14 +19,14 @@ function extract(tree, config) {
         // Find #include directives
         if (node.type === 'include_directive') {
             const pathNode = node.childForFieldName('path');
-            if (pathNode) results.imports.push({ source: pathNode.text });
+            if (pathNode) results.imports.push({ source: pathNode.text, location: location(node) });
         }
 
         // Find class definitions
         if (node.type === 'class_specifier') {
             const nameNode = node.childForFieldName('name');
             if (nameNode) {
-                const classObj = { name: nameNode.text, properties: [], methods: [], is_exported: true };
+                const classObj = { name: nameNode.text, location: location(node), properties: [], methods: [], is_exported: true };
                 results.classes.push(classObj);
                 contextStack.push(classObj);
                 isClassNode = true;
@@ -41,10 +43,14 @@ function extract(tree, config) {
                 const currentContext = contextStack[contextStack.length - 1];
                 const isMethod = currentContext && results.classes.some(c => c.name === currentContext.name);
 
+                const params = getParams(paramsNode);
                 const funcObj = {
                     name: nameNode.text,
-                    params: getParams(paramsNode),
+                    location: location(node),
+                    params: params.map(p => p.name),
+                    param_locations: params.map(p => p.location),
                     calls: [],
+                    call_sites: [],
                     return_types: [node.childForFieldName('type')?.text || 'void'],
                     is_exported: true,
                     is_method_of: isMethod ? currentContext.name : null,
@@ -76,6 +82,7 @@ function extract(tree, config) {
             const callName = node.childForFieldName('function')?.text;
             if (currentContext && callName) {
                 currentContext.calls.push(callName);
+                currentContext.call_sites.push({ name: callName, location: location(node) });
             }
         }
 
@@ -1,3 +1,5 @@
+const { location, param } = require('../location');
+
 function extract(tree, config) {
     const results = { functions: [], classes: [], imports: [] };
     const contextStack = [];
@@ -6,8 +8,8 @@ function extract(tree, config) {
         if (!parametersNode) return [];
         return parametersNode.children
             .filter(c => c.type === 'formal_parameter')
-            .map(p => p.childForFieldName('name')?.text || '')
-            .filter(Boolean);
+            .map(p => param(p.childForFieldName('name')?.text || '', p))
+            .filter(p => p.name);
     }
     
     function traverse(node) {
@@ -16,13 +18,13 @@ function extract(tree, config) {
 
         if (node.type === 'import_directive') {
             const uriNode = node.childForFieldName('uri');
-            if (uriNode) results.imports.push({ source: uriNode.text.replace(/['"]/g, '') });
+            if (uriNode) results.imports.push({ source: uriNode.text.replace(/['"]/g, ''), location: location(node) });
         }
         
         if (node.type === 'class_definition') {
             const nameNode = node.childForFieldName('name');
             if (nameNode) {
-                const classObj = { name: nameNode.text, properties: [], methods: [], is_exported: !nameNode.text.startsWith('_') };
+                const classObj = { name: nameNode.text, location: location(node), properties: [], methods: [], is_exported: !nameNode.text.startsWith('_') };
                 results.classes.push(classObj);
                 contextStack.push(classObj);
                 isClassNode = true;
@@ -37,10 +39,14 @@ function extract(tree, config) {
                 const currentContext = contextStack[contextStack.length - 1];
                  const isMethod = node.type === 'method_signature';
 
+                const params = getParams(paramsNode);
                 const funcObj = {
                     name: nameNode.text,
-                    params: getParams(paramsNode),
+                    location: location(node),
+                    params: params.map(p => p.name),
+                    param_locations: params.map(p => p.location),
                     calls: [],
+                    call_sites: [],
                     return_types: [node.childForFieldName('return_type')?.text || 'dynamic'],
                     is_exported: !nameNode.text.startsWith('_'),
                     is_method_of: isMethod ? currentContext.name : null,
@@ -62,7 +68,10 @@ function extract(tree, config) {
         if (node.type === 'call_expression') {
             const currentContext = contextStack.find(c => c.calls);
             const callName = node.childForFieldName('function')?.text;
-            if (currentContext && callName) currentContext.calls.push(callName);
+            if (currentContext && callName) {
+                currentContext.calls.push(callName);
+                currentContext.call_sites.push({ name: callName, location: location(node) });
+            }
         }
 
         for (const child of node.children) traverse(child);
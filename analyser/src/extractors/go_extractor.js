@@ -1,3 +1,5 @@
+const { location, param } = require('../location');
+
 function extract(tree, config) {
     const results = { functions: [], classes: [], imports: [] };
     const contextStack = [];
@@ -6,7 +8,7 @@ function extract(tree, config) {
         if (!parametersNode || !parametersNode.children) return [];
         return parametersNode.children
             .filter(c => c.type === 'parameter_declaration')
-            .flatMap(p => p.children.filter(id => id.type === 'identifier').map(id => id.text));
+            .flatMap(p => p.children.filter(id => id.type === 'identifier').map(id => param(id.text, id)));
     }
 
     function traverse(node) {
@@ -15,7 +17,7 @@ function extract(tree, config) {
 
         if (node.type === 'import_spec') {
             const pathNode = node.childForFieldName('path');
-            if (pathNode) results.imports.push({ source: pathNode.text.replace(/['"]/g, '') });
+            if (pathNode) results.imports.push({ source: pathNode.text.replace(/['"]/g, ''), location: location(node) });
         }
 
         if (node.type === 'type_spec' && node.childForFieldName('type')?.type === 'struct_type') {
@@ -26,7 +28,7 @@ function extract(tree, config) {
                     .filter(c => c.type === 'field_declaration')
                     .flatMap(f => f.children.filter(id => id.type === 'field_identifier').map(id => id.text)) || [];
 
-                const classObj = { name: nameNode.text, properties, methods: [], is_exported: isExported };
+                const classObj = { name: nameNode.text, location: location(node), properties, methods: [], is_exported: isExported };
                 results.classes.push(classObj);
                 contextStack.push(classObj);
                 isClassNode = true;
@@ -43,10 +45,14 @@ function extract(tree, config) {
                 const isExported = /[A-Z]/.test(nameNode.text[0]);
                 const receiverType = receiverNode?.childForFieldName('type')?.text;
                 
+                const params = getParams(paramsNode);
                 const funcObj = {
                     name: nameNode.text,
-                    params: getParams(paramsNode),
+                    location: location(node),
+                    params: params.map(p => p.name),
+                    param_locations: params.map(p => p.location),
                     calls: [],
+                    call_sites: [],
                     return_types: [node.childForFieldName('result')?.text || 'void'],
                     is_exported: isExported,
                     is_method_of: receiverType || null,
@@ -68,6 +74,7 @@ function extract(tree, config) {
             const callName = node.childForFieldName('function')?.text;
             if (currentContext && callName) {
                 currentContext.calls.push(callName);
+                currentContext.call_sites.push({ name: callName, location: location(node) });
             }
         }
 
@@ -1,3 +1,5 @@
+const { location, param } = require('../location');
+
 function extract(tree, config) {
     const results = { functions: [], classes: [], imports: [] };
     const contextStack = [];
@@ -6,24 +8,31 @@ function extract(tree, config) {
         if (!parametersNode) return [];
         return parametersNode.children
             .filter(c => c.type === 'formal_parameter')
-            .map(p => p.childForFieldName('name')?.text || '')
-            .filter(Boolean);
+            .map(p => param(p.childForFieldName('name')?.text || '', p))
+            .filter(p => p.name);
     }
 
     function traverse(node) {
         let isClassNode = false;
         let isFunctionNode = false;
 
+        if (node.type === 'package_declaration') {
+            const nameNode = node.namedChildren.find(c => c.type === 'scoped_identifier' || c.type === 'identifier');
+            if (nameNode) results.package = nameNode.text;
+        }
+
         if (node.type === 'import_declaration') {
-            const nameNode = node.childForFieldName('name');
-            if (nameNode) results.imports.push({ source: nameNode.text });
+            // The imported name is an unnamed child; "import a.b.*" ends in an asterisk.
+            const nameNode = node.namedChildren.find(c => c.type === 'scoped_identifier' || c.type === 'identifier');
+            const wildcard = node.namedChildren.some(c => c.type === 'asterisk');
+            if (nameNode) results.imports.push({ source: nameNode.text + (wildcard ? '.*' : ''), location: location(node) });
         }
 
         if (node.type === 'class_declaration') {
             const nameNode = node.childForFieldName('name');
             if (nameNode) {
                 const isExported = node.childForFieldName('modifiers')?.text.includes('public') ?? false;
-                const classObj = { name: nameNode.text, properties: [], methods: [], is_exported: isExported };
+                const classObj = { name: nameNode.text, location: location(node), properties: [], methods: [], is_exported: isExported };
                 results.classes.push(classObj);
                 contextStack.push(classObj);
                 isClassNode = true;
@@ -37,10 +46,14 @@ function extract(tree, config) {
                 const currentContext = contextStack[contextStack.length - 1];
                 const isExported = node.childForFieldName('modifiers')?.text.includes('public') ?? false;
 
+                const params = getParams(paramsNode);
                 const funcObj = {
                     name: nameNode.text,
-                    params: getParams(paramsNode),
+                    location: location(node),
+                    params: params.map(p => p.name),
+                    param_locations: params.map(p => p.location),
                     calls: [],
+                    call_sites: [],
                     return_types: [node.childForFieldName('type')?.text || 'void'],
                     is_exported: isExported,
                     is_method_of: currentContext ? currentContext.name : null,
@@ -67,9 +80,13 @@ function extract(tree, config) {
         
         if (node.type === 'method_invocation') {
             const currentContext = contextStack.find(c => c.calls);
-            const callName = node.childForFieldName('name')?.text;
+            // Qualify the call with what it is invoked on, as in "this.save" or "Util.parse".
+            const objectNode = node.childForFieldName('object');
+            const methodName = node.childForFieldName('name')?.text;
+            const callName = methodName && objectNode ? `${objectNode.text}.${methodName}` : methodName;
             if (currentContext && callName) {
                 currentContext.calls.push(callName);
+                currentContext.call_sites.push({ name: callName, location: location(node) });
             }
         }
 
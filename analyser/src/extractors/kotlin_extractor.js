@@ -1,3 +1,5 @@
+const { location, param } = require('../location');
+
 function extract(tree, config) {
     const results = { functions: [], classes: [], imports: [] };
     const contextStack = [];
@@ -6,23 +8,30 @@ function extract(tree, config) {
         if (!parametersNode) return [];
         return parametersNode.children
             .filter(c => c.type === 'function_value_parameter')
-            .map(p => p.childForFieldName('name')?.text || '')
-            .filter(Boolean);
+            .map(p => param(p.childForFieldName('name')?.text || '', p))
+            .filter(p => p.name);
     }
 
     function traverse(node) {
         let isClassNode = false;
         let isFunctionNode = false;
         
+        if (node.type === 'package_header') {
+            const nameNode = node.namedChildren.find(c => c.type === 'identifier');
+            if (nameNode) results.package = nameNode.text;
+        }
+
         if (node.type === 'import_header') {
-            node.children.forEach(imp => results.imports.push({ source: imp.text }));
+            const nameNode = node.namedChildren.find(c => c.type === 'identifier');
+            const wildcard = node.children.some(c => c.type === '.*' || c.type === 'wildcard_import');
+            if (nameNode) results.imports.push({ source: nameNode.text + (wildcard ? '.*' : ''), location: location(node) });
         }
         
         if (node.type === 'class_declaration') {
             const nameNode = node.childForFieldName('name');
             if (nameNode) {
                 const isExported = node.parent.childForFieldName('modifiers')?.text.includes('public') ?? true; // Default public
-                const classObj = { name: nameNode.text, properties: [], methods: [], is_exported: isExported };
+                const classObj = { name: nameNode.text, location: location(node), properties: [], methods: [], is_exported: isExported };
                 results.classes.push(classObj);
                 contextStack.push(classObj);
                 isClassNode = true;
@@ -37,10 +46,14 @@ function extract(tree, config) {
                 const isMethod = currentContext && results.classes.some(c => c.name === currentContext.name);
                 const isExported = node.parent.childForFieldName('modifiers')?.text.includes('public') ?? true;
 
+                const params = getParams(paramsNode);
                 const funcObj = {
                     name: nameNode.text,
-                    params: getParams(paramsNode),
+                    location: location(node),
+                    params: params.map(p => p.name),
+                    param_locations: params.map(p => p.location),
                     calls: [],
+                    call_sites: [],
                     return_types: [node.childForFieldName('type')?.text || 'Unit'],
                     is_exported: isExported,
                     is_method_of: isMethod ? currentContext.name : null,
@@ -63,7 +76,10 @@ function extract(tree, config) {
         if (node.type === 'call_expression') {
             const currentContext = contextStack.find(c => c.calls);
             const callName = node.childForFieldName('callee_expression')?.text;
-            if (currentContext && callName) currentContext.calls.push(callName);
+            if (currentContext && callName) {
+                currentContext.calls.push(callName);
+                currentContext.call_sites.push({ name: callName, location: location(node) });
+            }
         }
 
         for (const child of node.children) traverse(child);
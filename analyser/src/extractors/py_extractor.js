@@ -49,6 +49,8 @@
 // }
 
 // module.exports = { extract };
+const { location, param } = require('../location');
+
 function extract(tree, config) {
     const results = { functions: [], classes: [], imports: [] };
     const contextStack = [];
@@ -58,7 +60,7 @@ function extract(tree, config) {
         // Filters out 'self'
         return parametersNode.children
             .filter(c => c.type === 'identifier' && c.text !== 'self')
-            .map(p => p.text);
+            .map(p => param(p.text, p));
     }
     
     function traverse(node) {
@@ -67,7 +69,7 @@ function extract(tree, config) {
 
         if (node.type === 'import_statement' || node.type === 'from_import_statement') {
             const sourceNode = node.childForFieldName('module_name') || node.child(1);
-            if (sourceNode) results.imports.push({ source: sourceNode.text });
+            if (sourceNode) results.imports.push({ source: sourceNode.text, location: location(node) });
         }
 
         if (node.type === 'class_definition') {
@@ -77,7 +79,7 @@ function extract(tree, config) {
                     .filter(c => c.type === 'expression_statement' && c.child(0).type === 'assignment')
                     .map(a => a.child(0).childForFieldName('left')?.text)
                     .filter(Boolean);
-                const classObj = { name: nameNode.text, properties, methods: [], is_exported: !nameNode.text.startsWith('_') };
+                const classObj = { name: nameNode.text, location: location(node), properties, methods: [], is_exported: !nameNode.text.startsWith('_') };
                 results.classes.push(classObj);
                 contextStack.push(classObj);
                 isClassNode = true;
@@ -92,10 +94,14 @@ function extract(tree, config) {
                 const currentContext = contextStack[contextStack.length - 1];
                 const isMethod = currentContext && results.classes.some(c => c.name === currentContext.name);
 
+                const params = getParams(paramsNode);
                 const funcObj = {
                     name: nameNode.text,
-                    params: getParams(paramsNode),
+                    location: location(node),
+                    params: params.map(p => p.name),
+                    param_locations: params.map(p => p.location),
                     calls: [],
+                    call_sites: [],
                     return_types: [node.childForFieldName('return_type')?.text || 'any'],
                     is_exported: !nameNode.text.startsWith('_'),
                     is_method_of: isMethod ? currentContext.name : null,
@@ -116,6 +122,7 @@ function extract(tree, config) {
             const callName = node.childForFieldName('function')?.text;
             if (currentContext && callName) {
                 currentContext.calls.push(callName);
+                currentContext.call_sites.push({ name: callName, location: location(node) });
             }
         }
 
@@ -1,3 +1,5 @@
+const { location, param } = require('../location');
+
 function extract(tree, config) {
     const results = { functions: [], classes: [], imports: [] };
     const contextStack = [];
@@ -6,8 +8,8 @@ function extract(tree, config) {
         if (!parametersNode) return [];
         return parametersNode.children
             .filter(c => c.type === 'parameter')
-            .map(p => p.childForFieldName('name')?.text || '')
-            .filter(Boolean);
+            .map(p => param(p.childForFieldName('name')?.text || '', p))
+            .filter(p => p.name);
     }
 
     function traverse(node) {
@@ -16,7 +18,7 @@ function extract(tree, config) {
 
         if (node.type === 'import_declaration') {
             const pathNode = node.childForFieldName('path');
-            if (pathNode) results.imports.push({ source: pathNode.text });
+            if (pathNode) results.imports.push({ source: pathNode.text, location: location(node) });
         }
         
         const isClassLike = node.type === 'class_declaration' || node.type === 'struct_declaration';
@@ -24,7 +26,7 @@ function extract(tree, config) {
             const nameNode = node.childForFieldName('name');
             if (nameNode) {
                 const isExported = node.childForFieldName('modifiers')?.text.includes('public') ?? false;
-                const classObj = { name: nameNode.text, properties: [], methods: [], is_exported: isExported };
+                const classObj = { name: nameNode.text, location: location(node), properties: [], methods: [], is_exported: isExported };
                 results.classes.push(classObj);
                 contextStack.push(classObj);
                 isClassNode = true;
@@ -39,10 +41,14 @@ function extract(tree, config) {
                 const isMethod = currentContext && results.classes.some(c => c.name === currentContext.name);
                 const isExported = node.childForFieldName('modifiers')?.text.includes('public') ?? false;
 
+                const params = getParams(paramsNode);
                 const funcObj = {
                     name: nameNode.text,
-                    params: getParams(paramsNode),
+                    location: location(node),
+                    params: params.map(p => p.name),
+                    param_locations: params.map(p => p.location),
                     calls: [],
+                    call_sites: [],
                     return_types: [node.childForFieldName('return_type')?.text || 'Void'],
                     is_exported: isExported,
                     is_method_of: isMethod ? currentContext.name : null,
@@ -67,7 +73,10 @@ function extract(tree, config) {
         if (node.type === 'function_call_expression') {
             const currentContext = contextStack.find(c => c.calls);
             const callName = node.childForFieldName('name')?.text;
-            if (currentContext && callName) currentContext.calls.push(callName);
+            if (currentContext && callName) {
+                currentContext.calls.push(callName);
+                currentContext.call_sites.push({ name: callName, location: location(node) });
+            }
         }
 
         for (const child of node.children) traverse(child);
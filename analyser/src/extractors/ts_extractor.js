@@ -1,3 +1,5 @@
+const { location, param } = require('../location');
+
 function extract(tree, config) {
     const results = { functions: [], classes: [], imports: [] };
     const contextStack = [];
@@ -6,8 +8,8 @@ function extract(tree, config) {
         if (!parametersNode) return [];
         return parametersNode.children
             .filter(c => c.type === 'required_parameter' || c.type === 'optional_parameter')
-            .map(p => p.childForFieldName('pattern')?.text)
-            .filter(Boolean);
+            .map(p => param(p.childForFieldName('pattern')?.text, p))
+            .filter(p => p.name);
     }
     
     function markExported(name) {
@@ -23,7 +25,7 @@ function extract(tree, config) {
 
         if (node.type === 'import_statement') {
             const sourceNode = node.childForFieldName('source');
-            if (sourceNode) results.imports.push({ source: sourceNode.text.replace(/['"]/g, '') });
+            if (sourceNode) results.imports.push({ source: sourceNode.text.replace(/['"]/g, ''), location: location(node) });
         }
         
         if (node.type === 'export_statement') {
@@ -43,7 +45,7 @@ function extract(tree, config) {
         if (node.type === 'class_declaration') {
             const nameNode = node.childForFieldName('name');
             if (nameNode) {
-                const classObj = { name: nameNode.text, properties: [], methods: [], is_exported: false };
+                const classObj = { name: nameNode.text, location: location(node), properties: [], methods: [], is_exported: false };
                 results.classes.push(classObj);
                 contextStack.push(classObj);
                 isClassNode = true;
@@ -57,10 +59,14 @@ function extract(tree, config) {
                 const currentContext = contextStack[contextStack.length - 1];
                 const isMethod = node.type === 'method_definition';
                 
+                const params = getParams(node.childForFieldName('parameters'));
                 const funcObj = {
                     name: nameNode.text,
-                    params: getParams(node.childForFieldName('parameters')),
+                    location: location(node),
+                    params: params.map(p => p.name),
+                    param_locations: params.map(p => p.location),
                     calls: [],
+                    call_sites: [],
                     return_types: [node.childForFieldName('return_type')?.text.substring(2) || 'any'], // TS return types have a ': ' prefix
                     is_exported: false,
                     is_method_of: isMethod ? currentContext.name : null,
@@ -88,6 +94,7 @@ function extract(tree, config) {
             const callName = node.childForFieldName('function')?.text;
             if (currentContext && callName) {
                 currentContext.calls.push(callName);
+                currentContext.call_sites.push({ name: callName, location: location(node) });
             }
         }
 
@@ -5,11 +5,12 @@ const { languageConfig, ignoreExtensions, ignoreDirs } = require('./language');
 
 const parser = new Parser();
 
-function analyzeFile(filePath) {
+function analyzeFile(filePath, skipExtensions = new Set()) {
     const extension = path.extname(filePath);
 
-    // Skip files based on extension or if they have no extension
-    if (ignoreExtensions.has(extension) || extension === '') {
+    // Skip files based on extension or if they have no extension. skipExtensions
+    // holds languages the backend already analyzed natively.
+    if (ignoreExtensions.has(extension) || skipExtensions.has(extension) || extension === '') {
         return null;
     }
 
@@ -33,21 +34,23 @@ function analyzeFile(filePath) {
             ...analysis,
         };
     } catch (err) {
-        // Silently skip files that can't be parsed to keep JSON output clean
-        // Don't return error objects as they clutter the results
+        // Skip files that can't be parsed to keep JSON output clean, but report
+        // them on stderr so the backend records them as diagnostics
+        console.error(JSON.stringify({ level: 'warning', file: filePath, message: `failed to parse: ${err.message}` }));
         return null;
     }
 }
 
-function analyzeDirectory(directoryPath) {
-    let results = [];
+// walkDirectory analyzes every supported file under directoryPath and hands each
+// result to onFile as soon as it is ready, so callers can stream output.
+function walkDirectory(directoryPath, onFile, skipExtensions = new Set()) {
     let items;
 
     try {
         items = fs.readdirSync(directoryPath);
     } catch (err) {
         // Silently skip inaccessible directories
-        return [];
+        return;
     }
 
     for (const item of items) {
@@ -67,15 +70,20 @@ function analyzeDirectory(directoryPath) {
         }
 
         if (stat.isDirectory()) {
-            results = results.concat(analyzeDirectory(itemPath));
+            walkDirectory(itemPath, onFile, skipExtensions);
         } else {
-            const fileAnalysis = analyzeFile(itemPath);
+            const fileAnalysis = analyzeFile(itemPath, skipExtensions);
             if (fileAnalysis) {
-                results.push(fileAnalysis);
+                onFile(fileAnalysis);
             }
         }
     }
+}
+
+function analyzeDirectory(directoryPath, skipExtensions = new Set()) {
+    const results = [];
+    walkDirectory(directoryPath, file => results.push(file), skipExtensions);
     return results;
 }
 
-module.exports = { analyzeDirectory };
+module.exports = { analyzeDirectory, walkDirectory };
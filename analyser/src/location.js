@@ -0,0 +1,17 @@
+// location converts a tree-sitter node's span into the backend's Location shape.
+// Lines and columns are 1-based; the end position is exclusive, as in tree-sitter.
+function location(node) {
+    return {
+        start_line: node.startPosition.row + 1,
+        start_column: node.startPosition.column + 1,
+        end_line: node.endPosition.row + 1,
+        end_column: node.endPosition.column + 1,
+    };
+}
+
+// param turns a parameter node into the { name, location } pair the extractors collect.
+function param(name, node) {
+    return { name, location: location(node) };
+}
+
+module.exports = { location, param };
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/1107-adishjain/codemap/internal/clusters"
+	"github.com/1107-adishjain/codemap/internal/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxClusterElements caps the rows behind an expanded cluster or cluster edge.
+const maxClusterElements = 2000
+
+// clusterTimeout bounds the cluster queries.
+const clusterTimeout = 30 * time.Second
+
+// clusterGraphHandler returns the collapsed cluster-level graph of a project: one node per
+// Cluster and one edge per pair of clusters whose members import or call each other,
+// counting those imports and calls. Calls include the candidates of ambiguous calls
+// (MAY_CALL). The members of a cluster, and the relationships
+// behind an edge, are served by clusterMembersHandler and clusterEdgeHandler.
+func (app *application) clusterGraphHandler(w http.ResponseWriter, r *http.Request) {
+	project := projectFromContext(r)
+	ctx, cancel := context.WithTimeout(r.Context(), clusterTimeout)
+	defer cancel()
+
+	records, err := app.db.ProjectQuery(ctx, project.ID, `
+		MATCH (c:Cluster {project_id: $projectId})
+		RETURN c
+		ORDER BY c.size DESC, c.id
+	`, nil)
+	if err != nil {
+		app.writeClusterError(w, r, err)
+		return
+	}
+	graph, ok := graphElements(records)
+	if !ok {
+		graph = map[string]any{"nodes": []any{}, "edges": []any{}}
+	}
+
+	records, err = app.db.ProjectQuery(ctx, project.ID, `
+		MATCH (ca:Cluster {project_id: $projectId})<-[:IN_CLUSTER]-(a)-[r:IMPORTS|CALLS|MAY_CALL]->(b)-[:IN_CLUSTER]->(cb:Cluster {project_id: $projectId})
+		WHERE ca <> cb
+		WITH ca, cb, count(CASE type(r) WHEN 'IMPORTS' THEN 1 END) AS imports, count(CASE WHEN type(r) <> 'IMPORTS' THEN 1 END) AS calls
+		RETURN elementId(ca) AS source, elementId(cb) AS target, ca.id AS source_cluster, cb.id AS target_cluster, imports, calls
+		ORDER BY imports + calls DESC, source_cluster, target_cluster
+	`, nil)
+	if err != nil {
+		app.writeClusterError(w, r, err)
+		return
+	}
+	edges := make([]map[string]any, 0, len(records))
+	for _, record := range records {
+		imports, _ := record["imports"].(int64)
+		calls, _ := record["calls"].(int64)
+		edges = append(edges, map[string]any{
+			"id":             record["source_cluster"].(string) + "->" + record["target_cluster"].(string),
+			"source":         record["source"],
+			"target":         record["target"],
+			"source_cluster": record["source_cluster"],
+			"target_cluster": record["target_cluster"],
+			"label":          "DEPENDS_ON",
+			"type":           "DEPENDS_ON",
+			"count":          imports + calls,
+			"imports":        imports,
+			"calls":          calls,
+		})
+	}
+	graph["edges"] = edges
+
+	records, err = app.db.ProjectQuery(ctx, project.ID, "MATCH (p:Project {id: $projectId}) RETURN p.clusters_computed_at AS at", nil)
+	if err != nil {
+		app.writeClusterError(w, r, err)
+		return
+	}
+	graph["computed_at"] = nil
+	if len(records) > 0 {
+		graph["computed_at"] = records[0]["at"]
+	}
+	app.writeJSON(w, http.StatusOK, graph)
+}
+
+// clusterMembersHandler expands a cluster: its files and functions and the IMPORTS,
+// CALLS, MAY_CALL and CONTAINS relationships between them, as nodes/edges.
+func (app *application) clusterMembersHandler(w http.ResponseWriter, r *http.Request) {
+	project := projectFromContext(r)
+	ctx, cancel := context.WithTimeout(r.Context(), clusterTimeout)
+	defer cancel()
+
+	params := map[string]any{"cluster": chi.URLParam(r, "clusterId"), "limit": maxClusterElements}
+	records, err := app.db.ProjectQuery(ctx, project.ID, `
+		MATCH (c:Cluster {project_id: $projectId, id: $cluster})
+		OPTIONAL MATCH (n)-[:IN_CLUSTER]->(c)
+		WITH c, n ORDER BY coalesce(n.path, n.id) LIMIT $limit
+		OPTIONAL MATCH (n)-[r:IMPORTS|CALLS|MAY_CALL|CONTAINS]->(m)-[:IN_CLUSTER]->(c)
+		RETURN c, n, r, m
+		LIMIT $limit
+	`, params)
+	if err != nil {
+		app.writeClusterError(w, r, err)
+		return
+	}
+	if len(records) == 0 {
+		app.errorResponse(w, r, http.StatusNotFound, "Cluster not found")
+		return
+	}
+	app.writeClusterGraph(w, records)
+}
+
+// clusterEdgeHandler expands a cluster-level edge: the IMPORTS, CALLS and MAY_CALL
+// relationships from members of one cluster to members of another, with their ends, as nodes/edges.
+func (app *application) clusterEdgeHandler(w http.ResponseWriter, r *http.Request) {
+	project := projectFromContext(r)
+	ctx, cancel := context.WithTimeout(r.Context(), clusterTimeout)
+	defer cancel()
+
+	params := map[string]any{
+		"source": chi.URLParam(r, "clusterId"),
+		"target": chi.URLParam(r, "targetId"),
+		"limit":  maxClusterElements,
+	}
+	records, err := app.db.ProjectQuery(ctx, project.ID, `
+		MATCH (:Cluster {project_id: $projectId, id: $source})<-[:IN_CLUSTER]-(a)-[r:IMPORTS|CALLS|MAY_CALL]->(b)-[:IN_CLUSTER]->(:Cluster {project_id: $projectId, id: $target})
+		RETURN a, r, b
+		ORDER BY coalesce(a.path, a.id), coalesce(b.path, b.id)
+		LIMIT $limit
+	`, params)
+	if err != nil {
+		app.writeClusterError(w, r, err)
+		return
+	}
+	app.writeClusterGraph(w, records)
+}
+
+// writeClusterGraph writes the graph elements of an expansion and whether it was cut at
+// maxClusterElements rows.
+func (app *application) writeClusterGraph(w http.ResponseWriter, records []map[string]any) {
+	graph, ok := graphElements(records)
+	if !ok {
+		graph = map[string]any{"nodes": []any{}, "edges": []any{}}
+	}
+	graph["truncated"] = len(records) >= maxClusterElements
+	app.writeJSON(w, http.StatusOK, graph)
+}
+
+// recomputeClustersHandler clusters the project graph again, e.g. for projects imported
+// before clustering existed. It is refused while an analysis job is running, since the
+// job clusters the graph itself once its import is done.
+func (app *application) recomputeClustersHandler(w http.ResponseWriter, r *http.Request) {
+	project := projectFromContext(r)
+	job, err := app.db.GetLatestJobByProject(project.ID)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusInternalServerError, "Failed to fetch job: "+err.Error())
+		return
+	}
+	if job != nil && !database.Terminal(job.Stage) {
+		app.errorResponse(w, r, http.StatusConflict, "Project is being analysed; clusters are computed when the job finishes")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Minute)
+	defer cancel()
+	stats, err := clusters.Compute(ctx, app.db, project.ID)
+	if err != nil {
+		app.writeClusterError(w, r, err)
+		return
+	}
+	app.writeJSON(w, http.StatusOK, map[string]any{
+		"project_id":  project.ID,
+		"clusters":    stats.Clusters,
+		"files":       stats.Files,
+		"functions":   stats.Functions,
+		"duration_ms": stats.Duration.Milliseconds(),
+	})
+}
+
+// writeClusterError answers a failed cluster query, 504 when it ran out of time.
+func (app *application) writeClusterError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		app.errorResponse(w, r, http.StatusGatewayTimeout, "Cluster query took too long")
+		return
+	}
+	app.errorResponse(w, r, http.StatusInternalServerError, "Failed to query clusters: "+err.Error())
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/1107-adishjain/codemap/internal/graphalg"
+)
+
+// Levels of the cycle report.
+const (
+	cycleLevelFile      = "file"
+	cycleLevelDirectory = "directory"
+	cycleLevelAll       = "all"
+)
+
+// maxDirDepth caps the dirDepth parameter of the cycle report.
+const maxDirDepth = 32
+
+// importCycle is a set of files, or directories, that all import one another.
+type importCycle struct {
+	Members []string `json:"members"`
+	// Cycle is a shortest cycle through the first member, which is repeated at the end.
+	Cycle []string `json:"cycle"`
+	// Edges are the imports between members.
+	Edges []cycleEdge `json:"edges"`
+}
+
+// cycleEdge is an import between two members of a cycle. Directory-level edges list the
+// file imports they roll up.
+type cycleEdge struct {
+	Source string     `json:"source"`
+	Target string     `json:"target"`
+	Files  []fileEdge `json:"files,omitempty"`
+}
+
+// fileEdge is an import of one file by another.
+type fileEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// cycleOptions are the parameters shared by the cycle report and check.
+type cycleOptions struct {
+	level string
+	// dirDepth keeps only the first dirDepth segments of each directory when rolling
+	// files up, so "src/api/v1" is counted as "src/api" at depth 2; 0 keeps them all.
+	dirDepth int
+}
+
+// parseCycleOptions reads the level (file, directory or all, the default) and dirDepth
+// parameters.
+func parseCycleOptions(r *http.Request) (cycleOptions, error) {
+	query := r.URL.Query()
+	opts := cycleOptions{level: cycleLevelAll}
+	switch level := query.Get("level"); level {
+	case "":
+	case cycleLevelFile, cycleLevelDirectory, cycleLevelAll:
+		opts.level = level
+	default:
+		return cycleOptions{}, errors.New("level must be file, directory or all")
+	}
+	depth, err := intParam(query.Get("dirDepth"), 0, 0, maxDirDepth)
+	if err != nil {
+		return cycleOptions{}, errors.New("dirDepth " + err.Error())
+	}
+	opts.dirDepth = depth
+	return opts, nil
+}
+
+// cycleReportHandler finds circular imports: the strongly connected components of the
+// File IMPORTS File graph and of its rollup to directories, each with its members, a
+// shortest cycle through them and the imports that close it.
+func (app *application) cycleReportHandler(w http.ResponseWriter, r *http.Request) {
+	project := projectFromContext(r)
+	opts, err := parseCycleOptions(r)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	files, dirs, err := app.findImportCycles(r.Context(), project.ID, opts)
+	if err != nil {
+		app.writeCycleError(w, r, err)
+		return
+	}
+	app.writeJSON(w, http.StatusOK, map[string]any{
+		"project_id":       project.ID,
+		"level":            opts.level,
+		"file_cycles":      files,
+		"directory_cycles": dirs,
+		"summary":          cycleSummary(files, dirs),
+	})
+}
+
+// cycleCheckHandler is the pass/fail form of the cycle report, for CI: it answers 200
+// with status "pass" when the project has at most max cycles (0 by default) at the
+// requested level, and 422 with status "fail" and the cycles otherwise, so that
+// `curl --fail` fails the build.
+func (app *application) cycleCheckHandler(w http.ResponseWriter, r *http.Request) {
+	project := projectFromContext(r)
+	opts, err := parseCycleOptions(r)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	allowed, err := intParam(r.URL.Query().Get("max"), 0, 0, 1<<20)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "max "+err.Error())
+		return
+	}
+	files, dirs, err := app.findImportCycles(r.Context(), project.ID, opts)
+	if err != nil {
+		app.writeCycleError(w, r, err)
+		return
+	}
+
+	status, code := "pass", http.StatusOK
+	if len(files)+len(dirs) > allowed {
+		status, code = "fail", http.StatusUnprocessableEntity
+	}
+	app.writeJSON(w, code, map[string]any{
+		"project_id":       project.ID,
+		"status":           status,
+		"level":            opts.level,
+		"max":              allowed,
+		"summary":          cycleSummary(files, dirs),
+		"file_cycles":      files,
+		"directory_cycles": dirs,
+	})
+}
+
+// findImportCycles loads the project's file imports and returns the cycles at the levels
+// opts asks for; a level not asked for yields an empty list.
+func (app *application) findImportCycles(ctx context.Context, projectID string, opts cycleOptions) ([]importCycle, []importCycle, error) {
+	ctx, cancel := context.WithTimeout(ctx, reportTimeout)
+	defer cancel()
+	// A file matching its own import is an artefact of import resolution, not a cycle.
+	records, err := app.db.ProjectQuery(ctx, projectID, `
+		MATCH (a:File {project_id: $projectId})-[:IMPORTS]->(b:File {project_id: $projectId})
+		WHERE a <> b
+		RETURN DISTINCT a.path AS source, b.path AS target
+		ORDER BY source, target
+	`, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files, dirs := []importCycle{}, []importCycle{}
+	fileGraph, dirGraph := graphalg.New(), graphalg.New()
+	rollup := map[fileEdge][]fileEdge{}
+	for _, record := range records {
+		source, _ := record["source"].(string)
+		target, _ := record["target"].(string)
+		fileGraph.AddEdge(source, target)
+		from, to := rollupDir(source, opts.dirDepth), rollupDir(target, opts.dirDepth)
+		if from != to {
+			dirGraph.AddEdge(from, to)
+			key := fileEdge{Source: from, Target: to}
+			rollup[key] = append(rollup[key], fileEdge{Source: source, Target: target})
+		}
+	}
+	if opts.level != cycleLevelDirectory {
+		files = cyclesOf(fileGraph, nil)
+	}
+	if opts.level != cycleLevelFile {
+		dirs = cyclesOf(dirGraph, rollup)
+	}
+	return files, dirs, nil
+}
+
+// cyclesOf returns the cycles of g. rollup, when given, maps each edge of g to the file
+// imports behind it.
+func cyclesOf(g *graphalg.Graph, rollup map[fileEdge][]fileEdge) []importCycle {
+	components := g.StronglyConnectedComponents()
+	cycles := make([]importCycle, 0, len(components))
+	for _, members := range components {
+		in := make(map[string]bool, len(members))
+		for _, m := range members {
+			in[m] = true
+		}
+		c := importCycle{Members: members, Cycle: g.ShortestCycle(members[0], members), Edges: []cycleEdge{}}
+		for _, m := range members {
+			for _, t := range g.Successors(m) {
+				if in[t] {
+					c.Edges = append(c.Edges, cycleEdge{Source: m, Target: t, Files: rollup[fileEdge{Source: m, Target: t}]})
+				}
+			}
+		}
+		cycles = append(cycles, c)
+	}
+	return cycles
+}
+
+// rollupDir returns the directory of the file at p, cut to its first depth segments when
+// depth is positive. Files at the root are in ".".
+func rollupDir(p string, depth int) string {
+	dir := path.Dir(p)
+	if depth > 0 && dir != "." {
+		if parts := strings.Split(dir, "/"); len(parts) > depth {
+			dir = strings.Join(parts[:depth], "/")
+		}
+	}
+	return dir
+}
+
+// cycleSummary counts the cycles of a report and the files and directories in them.
+func cycleSummary(files, dirs []importCycle) map[string]int {
+	summary := map[string]int{
+		"file_cycles": len(files), "files_in_cycles": 0,
+		"directory_cycles": len(dirs), "directories_in_cycles": 0,
+	}
+	for _, c := range files {
+		summary["files_in_cycles"] += len(c.Members)
+	}
+	for _, c := range dirs {
+		summary["directories_in_cycles"] += len(c.Members)
+	}
+	return summary
+}
+
+// writeCycleError answers a failed cycle query, 504 when it ran out of time.
+func (app *application) writeCycleError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		app.errorResponse(w, r, http.StatusGatewayTimeout, "Cycle detection took too long")
+		return
+	}
+	app.errorResponse(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to detect cycles: %v", err))
+}
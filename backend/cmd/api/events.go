@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/1107-adishjain/codemap/internal/database"
+	"github.com/1107-adishjain/codemap/internal/events"
+)
+
+// sseHeartbeat keeps idle event streams alive through proxies.
+const sseHeartbeat = 15 * time.Second
+
+// projectEventsHandler streams pipeline events for a project as Server-Sent Events.
+// Clients resume after a reconnect by sending the Last-Event-ID header (or lastEventId query parameter).
+func (app *application) projectEventsHandler(w http.ResponseWriter, r *http.Request) {
+	project := projectFromContext(r)
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		app.errorResponse(w, r, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	lastIDStr := r.Header.Get("Last-Event-ID")
+	if lastIDStr == "" {
+		lastIDStr = r.URL.Query().Get("lastEventId")
+	}
+	var last events.Cursor
+	if lastIDStr != "" {
+		cursor, err := events.ParseCursor(lastIDStr)
+		if err != nil {
+			app.errorResponse(w, r, http.StatusBadRequest, "Invalid Last-Event-ID")
+			return
+		}
+		last = cursor
+	}
+
+	backlog, live, cancel := app.events.Subscribe(project.ID, last)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// With nothing buffered (e.g. after a server restart or for an old project),
+	// start from the job state stored in Postgres.
+	if len(backlog) == 0 {
+		snapshot, err := app.jobSnapshotEvent(project.ID)
+		if err != nil {
+			app.logError(r, err)
+		} else if snapshot != nil {
+			if err := writeSSE(w, *snapshot, false); err != nil {
+				return
+			}
+			flusher.Flush()
+			if snapshot.Terminal() {
+				return
+			}
+		}
+	}
+
+	for _, e := range backlog {
+		if err := writeSSE(w, e, true); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+	if len(backlog) > 0 && backlog[len(backlog)-1].Terminal() {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-app.shutdown.Done():
+			// Clients reconnect with Last-Event-ID once the server is back.
+			return
+		case e, ok := <-live:
+			if !ok {
+				// Dropped for falling behind; the client reconnects with Last-Event-ID.
+				return
+			}
+			if err := writeSSE(w, e, true); err != nil {
+				return
+			}
+			flusher.Flush()
+			if e.Terminal() {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// jobSnapshotEvent builds an event describing the project's latest job as stored in Postgres.
+func (app *application) jobSnapshotEvent(projectID string) (*events.Event, error) {
+	job, err := app.db.GetLatestJobByProject(projectID)
+	if err != nil || job == nil {
+		return nil, err
+	}
+	e := events.Event{
+		ProjectID: projectID,
+		JobID:     job.ID,
+		Attempt:   job.Attempts,
+		Type:      events.TypeStage,
+		Stage:     job.Stage,
+		Error:     job.Error,
+		Time:      job.UpdatedAt,
+	}
+	switch job.Stage {
+	case database.StageCompleted:
+		e.Type = events.TypeCompleted
+	case database.StageFailed:
+		e.Type = events.TypeFailed
+	case database.StageCancelled:
+		e.Type = events.TypeCancelled
+	}
+	return &e, nil
+}
+
+// writeSSE writes one event in text/event-stream framing. Snapshots are sent without an
+// id so they do not move the client's Last-Event-ID.
+func writeSSE(w http.ResponseWriter, e events.Event, withID bool) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if withID {
+		if _, err := fmt.Fprintf(w, "id: %s\n", e.Cursor()); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+	return err
+}
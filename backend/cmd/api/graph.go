@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// graphElements collects the nodes and relationships found anywhere in query results,
+// including inside paths, lists and maps, into the nodes/edges shape the frontend
+// renders. Each element appears once, in the order first seen. It reports false when the
+// results hold no graph elements, e.g. for table queries.
+func graphElements(results []map[string]any) (map[string]any, bool) {
+	nodes := []map[string]any{}
+	edges := []map[string]any{}
+	seen := make(map[string]bool)
+
+	addNode := func(n neo4j.Node) {
+		if seen[n.ElementId] {
+			return
+		}
+		seen[n.ElementId] = true
+		node := make(map[string]any, len(n.Props)+4)
+		for k, v := range n.Props {
+			node[k] = v
+		}
+		// The element ID identifies the node to edges; keep the node's own id property.
+		if id, ok := n.Props["id"]; ok {
+			node["node_id"] = id
+		}
+		label := ""
+		if len(n.Labels) > 0 {
+			label = n.Labels[0]
+		}
+		name, _ := n.Props["name"].(string)
+		node["id"] = n.ElementId
+		node["label"] = label
+		node["type"] = label
+		node["name"] = name
+		nodes = append(nodes, node)
+	}
+	addEdge := func(r neo4j.Relationship) {
+		if seen[r.ElementId] {
+			return
+		}
+		seen[r.ElementId] = true
+		edge := make(map[string]any, len(r.Props)+5)
+		for k, v := range r.Props {
+			edge[k] = v
+		}
+		edge["id"] = r.ElementId
+		edge["source"] = r.StartElementId
+		edge["target"] = r.EndElementId
+		edge["label"] = r.Type
+		edge["type"] = r.Type
+		edges = append(edges, edge)
+	}
+
+	var visit func(v any)
+	var visitMap func(m map[string]any)
+	visit = func(v any) {
+		switch v := v.(type) {
+		case neo4j.Node:
+			addNode(v)
+		case neo4j.Relationship:
+			addEdge(v)
+		case neo4j.Path:
+			for _, n := range v.Nodes {
+				addNode(n)
+			}
+			for _, r := range v.Relationships {
+				addEdge(r)
+			}
+		case []any:
+			for _, item := range v {
+				visit(item)
+			}
+		case map[string]any:
+			visitMap(v)
+		}
+	}
+	// Visit columns and map entries in key order so the output order is stable.
+	visitMap = func(m map[string]any) {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			visit(m[k])
+		}
+	}
+	for _, row := range results {
+		visitMap(row)
+	}
+	if len(nodes) == 0 && len(edges) == 0 {
+		return nil, false
+	}
+	return map[string]any{"nodes": nodes, "edges": edges}, true
+}
@@ -1,16 +1,17 @@
 package main
 
 import (
-	"archive/zip"
-	"github.com/1107-adishjain/codemap/internal/analysis"
+	"github.com/1107-adishjain/codemap/internal/cypher"
+	"github.com/1107-adishjain/codemap/internal/database"
+	"github.com/1107-adishjain/codemap/internal/metrics"
 	middlewares "github.com/1107-adishjain/codemap/internal/middleware"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 )
@@ -25,7 +26,9 @@ func (app *application) healthCheckHandler(w http.ResponseWriter, r *http.Reques
 	app.writeJSON(w, http.StatusOK, data)
 }
 
-// uploadHandler handles the file upload and analysis process.
+// uploadHandler stores the uploaded archive in S3 and enqueues it for analysis, so
+// whichever worker claims the job can fetch it. The pipeline runs on the worker pool;
+// progress is reported by projectStatusHandler.
 func (app *application) uploadHandler(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseMultipartForm(300 << 20); err != nil { // 300MB max of zip file the user can upload
 		app.errorResponse(w, r, http.StatusBadRequest, "Could not parse multipart form.")
@@ -37,86 +40,49 @@ func (app *application) uploadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer file.Close()
-	// Use configured path for temporary uploads
-	tempDir, err := os.MkdirTemp(app.config.TempUploads, "codemap-upload-*")
-	if err != nil {
-		app.errorResponse(w, r, http.StatusInternalServerError, "Could not create temp directory.")
-		return
-	}
-	defer os.RemoveAll(tempDir)
-	zipPath := filepath.Join(tempDir, handler.Filename)
-	tempFile, err := os.Create(zipPath)
-	if err != nil {
-		app.errorResponse(w, r, http.StatusInternalServerError, "Could not create temp file.")
-		return
-	}
-	// Stream file to local temp file first (better for large files)
-	_, err = io.Copy(tempFile, file)
-	tempFile.Close()
-	if err != nil {
-		app.errorResponse(w, r, http.StatusInternalServerError, "Could not save uploaded file.")
+	userID, ok := r.Context().Value(middlewares.UserIDKey).(string)
+	if !ok {
+		app.errorResponse(w, r, http.StatusUnauthorized, "User ID not found in context")
 		return
 	}
-
-	// Read file for S3 upload (now from disk, not memory)
-	zipData, err := os.ReadFile(zipPath)
+	zipData, err := io.ReadAll(file)
 	if err != nil {
-		app.errorResponse(w, r, http.StatusInternalServerError, "Could not read temp file.")
+		app.errorResponse(w, r, http.StatusInternalServerError, "Could not read uploaded file.")
 		return
 	}
-
-	// Upload to S3
-	s3Key, err := app.s3.UploadZipFile(zipData, handler.Filename)
+	s3Key, err := app.s3.UploadZipFile(zipData, filepath.Base(handler.Filename))
 	if err != nil {
 		app.errorResponse(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to upload to S3: %v", err))
 		return
 	}
-	// add the logic of adding the project in the database after successful upload to s3
-	userID, ok := r.Context().Value(middlewares.UserIDKey).(string)
-	if !ok {
-		app.errorResponse(w, r, http.StatusUnauthorized, "User ID not found in context")
-		return
-	}
+	app.logger.Printf("📤 Uploaded %s to S3: %s (Size: %d bytes)", handler.Filename, s3Key, len(zipData))
 
 	projectID, err := app.db.CreateProject(userID, handler.Filename, s3Key)
 	if err != nil {
+		if err := app.s3.DeleteFile(s3Key); err != nil {
+			app.logger.Printf("Could not delete %s from S3: %v", s3Key, err)
+		}
 		app.errorResponse(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to add project to database: %v", err))
 		return
 	}
-
-	app.logger.Printf("📤 Uploaded %s to S3: %s (Size: %d bytes)", handler.Filename, s3Key, len(zipData))
-	unzipDest := filepath.Join(tempDir, "unzipped")
-	if err := unzip(zipPath, unzipDest); err != nil {
-		app.errorResponse(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to unzip file: %v", err))
-		return
-	}
-	// Pass the configured tools path and the unzipped directory to the runner
-	analysisResult, err := analysis.Run(app.config.ToolsPath, unzipDest)
+	// The archive is the project's now, so a failure here can be retried from it.
+	jobID, err := app.db.CreateJob(projectID, database.SourceS3, s3Key)
 	if err != nil {
-		app.errorResponse(w, r, http.StatusInternalServerError, err.Error())
-		return
-	}
-	// Import the result into Neo4j
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute) // 15-minute timeout for import
-	defer cancel()
-	if err := app.db.ImportAnalysis(ctx, analysisResult, projectID, handler.Filename); err != nil {
-		app.errorResponse(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to import data to Neo4j: %v", err))
-		return
-	}
-	// update project status to 'completed'
-	if err := app.db.UpdateProjectStatus(projectID, "completed"); err != nil {
-		app.errorResponse(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to update project status: %v", err))
+		app.errorResponse(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to enqueue analysis job: %v", err))
 		return
 	}
-	app.logger.Printf("✅ Analysis and import completed for project ID: %s", projectID)
+	app.jobs.Notify()
+	app.logger.Printf("📥 Queued upload %s as job %s for project %s", handler.Filename, jobID, projectID)
 
-	// Send a success response
 	app.writeJSON(w, http.StatusAccepted, map[string]string{
-		"message": "Upload successful. Codebase has been analyzed and imported.",
-		"s3_key":  s3Key,
+		"message":    "Upload received. Analysis has been queued.",
+		"project_id": projectID,
+		"job_id":     jobID,
+		"status_url": fmt.Sprintf("/api/v1/projects/%s/status", projectID),
 	})
 }
 
+// githubHandler enqueues a GitHub repository for cloning and analysis.
 func (app *application) githubHandler(w http.ResponseWriter, r *http.Request) {
 	var payload struct {
 		RepoURL string `json:"repo_url"`
@@ -137,12 +103,6 @@ func (app *application) githubHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Upload GitHub repo to S3 and get the local temp directory path
-	s3Key, tempDir, err := app.s3.UploadGitRepo(payload.RepoURL)
-	if err != nil {
-		app.errorResponse(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to clone and upload repository: %v", err))
-		return
-	}
 	userID, ok := r.Context().Value(middlewares.UserIDKey).(string)
 	if !ok {
 		app.errorResponse(w, r, http.StatusUnauthorized, "User ID not found in context")
@@ -150,41 +110,114 @@ func (app *application) githubHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	repoName := extractRepoName(payload.RepoURL)
-	projectID, err := app.db.CreateProject(userID, repoName, s3Key)
+	projectID, err := app.db.CreateProject(userID, repoName, "")
 	if err != nil {
 		app.errorResponse(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to add project to database: %v", err))
 		return
 	}
-	// Clean up temp directory after we are done
-	defer os.RemoveAll(tempDir)
+	jobID, err := app.db.CreateJob(projectID, database.SourceGitHub, payload.RepoURL)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to enqueue analysis job: %v", err))
+		return
+	}
+	app.jobs.Notify()
+	app.logger.Printf("📥 Queued GitHub repo %s as job %s for project %s", payload.RepoURL, jobID, projectID)
 
-	app.logger.Printf("📤 Uploaded GitHub repo to S3: %s", s3Key)
+	app.writeJSON(w, http.StatusAccepted, map[string]string{
+		"message":    "GitHub repository queued for analysis.",
+		"project_id": projectID,
+		"job_id":     jobID,
+		"repo_url":   payload.RepoURL,
+		"status_url": fmt.Sprintf("/api/v1/projects/%s/status", projectID),
+	})
+}
 
-	analysisResult, err := analysis.Run(app.config.ToolsPath, tempDir)
+// projectStatusHandler reports the pipeline stage and error of a project's latest analysis job.
+func (app *application) projectStatusHandler(w http.ResponseWriter, r *http.Request) {
+	project := projectFromContext(r)
+	job, err := app.db.GetLatestJobByProject(project.ID)
 	if err != nil {
-		app.errorResponse(w, r, http.StatusInternalServerError, fmt.Sprintf("Analysis failed: %v", err))
+		app.errorResponse(w, r, http.StatusInternalServerError, "Failed to fetch job status: "+err.Error())
 		return
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
-	defer cancel()
-	// Import analysis results into Neo4j
-	if err := app.db.ImportAnalysis(ctx, analysisResult, projectID, repoName); err != nil {
-		app.errorResponse(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to import analysis to Neo4j: %v", err))
+	if job == nil {
+		app.errorResponse(w, r, http.StatusNotFound, "No analysis job found for this project")
 		return
 	}
+	app.writeJSON(w, http.StatusOK, map[string]any{
+		"project_id":  project.ID,
+		"job_id":      job.ID,
+		"stage":       job.Stage,
+		"error":       job.Error,
+		"attempts":    job.Attempts,
+		"created_at":  job.CreatedAt,
+		"started_at":  job.StartedAt,
+		"finished_at": job.FinishedAt,
+		"updated_at":  job.UpdatedAt,
+	})
+}
 
-	// update project status to 'completed'
-	if err := app.db.UpdateProjectStatus(projectID, "completed"); err != nil {
-		app.errorResponse(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to update project status: %v", err))
+// cancelJobHandler cancels the project's queued or running analysis job.
+func (app *application) cancelJobHandler(w http.ResponseWriter, r *http.Request) {
+	project := projectFromContext(r)
+	job, err := app.db.GetLatestJobByProject(project.ID)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusInternalServerError, "Failed to fetch job: "+err.Error())
 		return
 	}
-	app.logger.Printf("✅ Analysis and import completed for project ID: %s", projectID)
+	if job == nil {
+		app.errorResponse(w, r, http.StatusNotFound, "No analysis job found for this project")
+		return
+	}
+	cancelled, err := app.jobs.Cancel(job)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusInternalServerError, "Failed to cancel job: "+err.Error())
+		return
+	}
+	if !cancelled {
+		app.errorResponse(w, r, http.StatusConflict, fmt.Sprintf("Job is not running (stage: %s)", job.Stage))
+		return
+	}
+	app.writeJSON(w, http.StatusAccepted, map[string]string{
+		"message":    "Cancellation requested.",
+		"project_id": project.ID,
+		"job_id":     job.ID,
+	})
+}
+
+// retryJobHandler re-runs a failed or cancelled analysis from the archive already stored in S3.
+func (app *application) retryJobHandler(w http.ResponseWriter, r *http.Request) {
+	project := projectFromContext(r)
+	job, err := app.db.GetLatestJobByProject(project.ID)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusInternalServerError, "Failed to fetch job: "+err.Error())
+		return
+	}
+	if job != nil && job.Stage != database.StageFailed && job.Stage != database.StageCancelled {
+		app.errorResponse(w, r, http.StatusConflict, fmt.Sprintf("Only failed or cancelled jobs can be retried (stage: %s)", job.Stage))
+		return
+	}
+	if project.S3Key == "" {
+		app.errorResponse(w, r, http.StatusConflict, "The project's archive was never stored; please upload it again")
+		return
+	}
+
+	jobID, err := app.db.CreateJob(project.ID, database.SourceS3, project.S3Key)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to enqueue analysis job: %v", err))
+		return
+	}
+	if err := app.db.UpdateProjectStatus(project.ID, "pending"); err != nil {
+		app.logError(r, err)
+	}
+	app.jobs.Notify()
+	app.logger.Printf("🔁 Queued retry of project %s as job %s", project.ID, jobID)
 
-	// Send success response
 	app.writeJSON(w, http.StatusAccepted, map[string]string{
-		"message":  "GitHub repository analyzed and imported to Neo4j successfully.",
-		"s3_key":   s3Key,
-		"repo_url": payload.RepoURL,
+		"message":    "Analysis has been queued again.",
+		"project_id": project.ID,
+		"job_id":     jobID,
+		"status_url": fmt.Sprintf("/api/v1/projects/%s/status", project.ID),
 	})
 }
 
@@ -200,127 +233,46 @@ func (app *application) queryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get projectID from query parameter
-	projectID := r.URL.Query().Get("projectId")
-	if projectID != "" {
-		if payload.Params == nil {
-			payload.Params = make(map[string]any)
-		}
-		payload.Params["projectId"] = projectID
+	// The query is confined to the project checked by requireProject; the Project*
+	// queries bind $projectId themselves, overriding anything the client put in params.
+	project := projectFromContext(r)
+	query, err := cypher.Scope(payload.Query)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+		return
 	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	results, err := app.db.Query(ctx, payload.Query, payload.Params)
+	p, err := app.parsePage(r, app.config.QueryPageSize)
 	if err != nil {
-		app.errorResponse(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to execute query: %v", err))
+		app.errorResponse(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Debug: Print the raw Neo4j query results to the server log for analysis
-	app.logger.Printf("[DEBUG] Raw Neo4j query results: %+v", results)
-
-	// Detect if this is a graph query (contains nodes/edges/relationships)
-	isGraph := false
-	for _, row := range results {
-		for _, v := range row {
-			if node, ok := v.(map[string]any); ok && (node["Labels"] != nil || node["Type"] != nil) {
-				isGraph = true
-				break
-			}
-		}
-		if isGraph {
-			break
-		}
-	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
 
-	if isGraph {
-		nodeMap := make(map[string]map[string]any)
-		edgeMap := make(map[string]map[string]any)
-
-		for _, row := range results {
-			for _, v := range row {
-				if node, ok := v.(map[string]any); ok && node["Labels"] != nil {
-					id := fmt.Sprintf("%v", node["Id"])
-					if _, exists := nodeMap[id]; !exists {
-						props := map[string]any{}
-						if p, ok := node["Props"].(map[string]any); ok {
-							for pk, pv := range p {
-								props[pk] = pv
-							}
-						}
-						labels := node["Labels"].([]any)
-						label := ""
-						if len(labels) > 0 {
-							label = fmt.Sprintf("%v", labels[0])
-						}
-						// Always include the 'name' property if present, for function/class nodes
-						name := ""
-						if n, ok := props["name"]; ok {
-							name = fmt.Sprintf("%v", n)
-						}
-						nodeMap[id] = map[string]any{
-							"id":    id,
-							"label": label,
-							"type":  label,
-							"name":  name,
-						}
-						for pk, pv := range props {
-							nodeMap[id][pk] = pv
-						}
-					}
-				} else if edge, ok := v.(map[string]any); ok && edge["Type"] != nil && edge["StartId"] != nil && edge["EndId"] != nil {
-					id := fmt.Sprintf("%v", edge["Id"])
-					if _, exists := edgeMap[id]; !exists {
-						props := map[string]any{}
-						if p, ok := edge["Props"].(map[string]any); ok {
-							for pk, pv := range p {
-								props[pk] = pv
-							}
-						}
-						edgeMap[id] = map[string]any{
-							"id":     id,
-							"source": fmt.Sprintf("%v", edge["StartId"]),
-							"target": fmt.Sprintf("%v", edge["EndId"]),
-							"label":  fmt.Sprintf("%v", edge["Type"]),
-							"type":   fmt.Sprintf("%v", edge["Type"]),
-						}
-						for pk, pv := range props {
-							edgeMap[id][pk] = pv
-						}
-					}
-				}
-			}
+	app.writeQueryResults(ctx, w, r, p, project.ID, query, payload.Params, func(records []map[string]any, next *int) any {
+		// Graph queries (returning nodes, relationships or paths) are shaped into nodes/edges
+		if graph, ok := graphElements(records); ok {
+			graph["next_offset"] = next
+			return graph
 		}
-		nodes := make([]map[string]any, 0, len(nodeMap))
-		for _, n := range nodeMap {
-			nodes = append(nodes, n)
-		}
-		edges := make([]map[string]any, 0, len(edgeMap))
-		for _, e := range edgeMap {
-			edges = append(edges, e)
-		}
-		response := map[string]any{
-			"nodes": nodes,
-			"edges": edges,
-		}
-		app.writeJSON(w, http.StatusOK, response)
-	} else {
 		// Return raw results for table queries
-		app.writeJSON(w, http.StatusOK, results)
-	}
+		return records
+	})
 }
 
+// projectNodes is the label expression matching every node a project owns.
+var projectNodes = strings.Join(database.ProjectNodeLabels, "|")
+
 // graphSummaryHandler returns high-level statistics about the codebase graph
 func (app *application) graphSummaryHandler(w http.ResponseWriter, r *http.Request) {
 	query := `
-		MATCH (n)
+		MATCH (n:` + projectNodes + ` {project_id: $projectId})
 		WITH labels(n)[0] as nodeType, count(n) as count
 		RETURN nodeType, count
-		ORDER BY count DESC
+		ORDER BY count DESC, nodeType
 	`
-	app.runGraphQuery(w, r, query, map[string]any{}, 10*time.Second, "summary")
+	app.runGraphQuery(w, r, query, map[string]any{}, 10*time.Second, "summary", app.config.QueryPageSize)
 }
 
 // graphNodeDetailsHandler returns detailed information and connections for a specific node
@@ -331,62 +283,70 @@ func (app *application) graphNodeDetailsHandler(w http.ResponseWriter, r *http.R
 		return
 	}
 	query := `
-		MATCH (n)
+		MATCH (n:` + projectNodes + ` {project_id: $projectId})
 		WHERE elementId(n) = $nodeId OR n.id = $nodeId OR n.name = $nodeId
 		OPTIONAL MATCH (n)-[r]-(connected)
 		RETURN n, collect({relationship: r, node: connected}) as connections
 		LIMIT 1
 	`
-	app.runGraphQuery(w, r, query, map[string]any{"nodeId": nodeID}, 10*time.Second, "")
+	app.runGraphQuery(w, r, query, map[string]any{"nodeId": nodeID}, 10*time.Second, "", app.config.QueryPageSize)
 }
 
 // graphFileHierarchyHandler returns the file structure hierarchy
 func (app *application) graphFileHierarchyHandler(w http.ResponseWriter, r *http.Request) {
 	query := `
-		MATCH (f:File)
+		MATCH (f:File {project_id: $projectId})
 		OPTIONAL MATCH (f)-[r:CONTAINS]->(content)
 		WITH f, count(content) as itemCount, collect(labels(content)[0]) as contentTypes
 		RETURN f.path as path, f.language as language, itemCount, contentTypes
 		ORDER BY path
-		LIMIT 100
 	`
-	app.runGraphQuery(w, r, query, map[string]any{}, 15*time.Second, "files")
+	app.runGraphQuery(w, r, query, map[string]any{}, 15*time.Second, "files", app.config.QueryPageSize)
 }
 
-// graphTopNodesHandler returns the most connected/important nodes
+// graphTopNodesHandler returns the most connected/important nodes. By default nodes are
+// ranked by their number of relationships of any type; by=<metric> (see the metrics
+// package) ranks the scored functions, classes and files by that metric instead.
 func (app *application) graphTopNodesHandler(w http.ResponseWriter, r *http.Request) {
 	nodeType := r.URL.Query().Get("type") // File, Function, Class, etc.
-	limitStr := r.URL.Query().Get("limit")
-	limit := 20
-	if limitStr != "" {
-		if l, err := fmt.Sscanf(limitStr, "%d", &limit); err == nil && l == 1 {
-			// limit parsed successfully
-		}
-	}
-	params := map[string]any{"limit": limit}
+	params := map[string]any{}
 	var query string
-	if nodeType != "" {
+	if by := r.URL.Query().Get("by"); by != "" {
+		if !slices.Contains(metrics.Names, by) {
+			app.errorResponse(w, r, http.StatusBadRequest, "by must be one of "+strings.Join(metrics.Names, ", "))
+			return
+		}
+		// by is one of the fixed metric names, so it can be written into the query.
+		query = fmt.Sprintf(`
+			MATCH (n:%[2]s {project_id: $projectId})
+			WHERE n.%[1]s IS NOT NULL AND ($nodeType = '' OR $nodeType IN labels(n))
+			OPTIONAL MATCH (n)-[r]-()
+			WITH n, labels(n)[0] as type, count(r) as connections
+			RETURN n, type, connections, n.%[1]s as score
+			ORDER BY score DESC, elementId(n)
+		`, by, strings.Join(metrics.Labels, "|"))
+		params["nodeType"] = nodeType
+	} else if nodeType != "" {
 		query = `
-			MATCH (n)
+			MATCH (n:` + projectNodes + ` {project_id: $projectId})
 			WHERE $nodeType IN labels(n)
 			OPTIONAL MATCH (n)-[r]-(connected)
 			WITH n, count(r) as connections
 			WHERE connections > 0
 			RETURN n, connections
-			ORDER BY connections DESC
-			LIMIT $limit
+			ORDER BY connections DESC, elementId(n)
 		`
 		params["nodeType"] = nodeType
 	} else {
 		query = `
-			MATCH (n)-[r]-()
+			MATCH (n:` + projectNodes + ` {project_id: $projectId})-[r]-()
 			WITH n, labels(n)[0] as type, count(r) as connections
 			RETURN n, type, connections
-			ORDER BY connections DESC
-			LIMIT $limit
+			ORDER BY connections DESC, elementId(n)
 		`
 	}
-	app.runGraphQuery(w, r, query, params, 15*time.Second, "topNodes")
+	// The page size (limit, 20 by default) is the number of top nodes returned.
+	app.runGraphQuery(w, r, query, params, 15*time.Second, "topNodes", 20)
 }
 
 // --- HELPER METHODS ---
@@ -411,44 +371,6 @@ func (app *application) logError(r *http.Request, err error) {
 	app.logger.Println(err)
 }
 
-// unzip function to extract a zip archive.
-func unzip(src, dest string) error {
-	r, err := zip.OpenReader(src)
-	if err != nil {
-		return err
-	}
-	defer r.Close()
-	os.MkdirAll(dest, 0755)
-	for _, f := range r.File {
-		fpath := filepath.Join(dest, f.Name)
-		if !strings.HasPrefix(fpath, filepath.Clean(dest)+string(os.PathSeparator)) {
-			return fmt.Errorf("%s: illegal file path", fpath)
-		}
-		if f.FileInfo().IsDir() {
-			os.MkdirAll(fpath, os.ModePerm)
-			continue
-		}
-		if err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
-			return err
-		}
-		outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-		if err != nil {
-			return err
-		}
-		rc, err := f.Open()
-		if err != nil {
-			return err
-		}
-		_, err = io.Copy(outFile, rc)
-		outFile.Close()
-		rc.Close()
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 // extractRepoName extracts the repository name from a GitHub URL.
 func extractRepoName(repoURL string) string {
 	parts := strings.Split(strings.TrimSuffix(repoURL, ".git"), "/")
@@ -459,30 +381,29 @@ func extractRepoName(repoURL string) string {
 }
 
 // --- GRAPH QUERY HELPER ---
-// runGraphQuery is a modular helper for running graph queries with projectID and error handling
+// runGraphQuery is a modular helper for running graph queries with projectID and error handling.
+// query must scope itself with $projectId, which is set to the project checked by requireProject.
+// Results are paginated (or streamed) like those of queryHandler.
 func (app *application) runGraphQuery(
 	w http.ResponseWriter, r *http.Request,
-	query string, params map[string]any, timeout time.Duration, wrapKey string,
+	query string, params map[string]any, timeout time.Duration, wrapKey string, defaultLimit int,
 ) {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	// Add projectID if present
-	projectID := r.URL.Query().Get("projectId")
-	if projectID != "" {
-		params["projectId"] = projectID
-	}
-
-	results, err := app.db.Query(ctx, query, params)
+	project := projectFromContext(r)
+	p, err := app.parsePage(r, defaultLimit)
 	if err != nil {
-		app.errorResponse(w, r, http.StatusInternalServerError, "Failed to execute graph query: "+err.Error())
+		app.errorResponse(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
-	if wrapKey != "" {
-		app.writeJSON(w, http.StatusOK, map[string]any{wrapKey: results})
-	} else {
-		app.writeJSON(w, http.StatusOK, results)
-	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	app.writeQueryResults(ctx, w, r, p, project.ID, query, params, func(records []map[string]any, next *int) any {
+		if wrapKey != "" {
+			return map[string]any{wrapKey: records, "next_offset": next}
+		}
+		return records
+	})
 }
 
 func (app *application) listProjectsHandler(w http.ResponseWriter, r *http.Request) {
@@ -498,3 +419,75 @@ func (app *application) listProjectsHandler(w http.ResponseWriter, r *http.Reque
 	}
 	app.writeJSON(w, http.StatusOK, map[string]any{"projects": projects})
 }
+
+// deleteProjectTimeout bounds a project deletion, including waiting for a running job to stop.
+const deleteProjectTimeout = 10 * time.Minute
+
+// deleteProjectHandler removes a project: its running job, its Neo4j subgraph, its archive
+// in S3 and finally its Postgres row. Every step is safe to repeat, and once one fails the
+// later ones are skipped, so the project stays listed with its archive and the request can
+// simply be retried; the response then reports which steps failed.
+func (app *application) deleteProjectHandler(w http.ResponseWriter, r *http.Request) {
+	project := projectFromContext(r)
+	ctx, cancel := context.WithTimeout(r.Context(), deleteProjectTimeout)
+	defer cancel()
+
+	steps := map[string]string{}
+	failed := false
+	record := func(step string, err error) {
+		if err != nil {
+			app.logError(r, fmt.Errorf("delete project %s: %s: %w", project.ID, step, err))
+			steps[step] = "failed: " + err.Error()
+			failed = true
+			return
+		}
+		steps[step] = "deleted"
+	}
+
+	// Stop the analysis first, or it could keep writing nodes while they are removed. A job
+	// cancelled in another process is only over once its worker has set finished_at.
+	job, err := app.db.GetLatestJobByProject(project.ID)
+	switch {
+	case err != nil:
+		record("job", err)
+	case job == nil || database.Terminal(job.Stage) && job.FinishedAt != nil:
+		steps["job"] = "skipped"
+	default:
+		record("job", app.jobs.CancelAndWait(ctx, job))
+	}
+
+	if failed {
+		steps["graph"] = "skipped"
+	} else {
+		record("graph", app.db.DeleteProjectGraph(ctx, project.ID))
+	}
+	// The archive is what a retry re-analyses, so it is kept until the project is gone.
+	if failed || project.S3Key == "" {
+		steps["archive"] = "skipped"
+	} else {
+		record("archive", app.s3.DeleteFile(project.S3Key))
+	}
+	if !failed {
+		app.source.Remove(project.ID)
+	}
+
+	if failed {
+		steps["database"] = "skipped"
+	} else {
+		record("database", app.db.DeleteProject(project.ID))
+	}
+	if failed {
+		app.writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error":      "Project was only partially deleted; retry the request",
+			"project_id": project.ID,
+			"steps":      steps,
+		})
+		return
+	}
+	app.logger.Printf("🗑️ Deleted project %s", project.ID)
+	app.writeJSON(w, http.StatusOK, map[string]any{
+		"message":    "Project deleted.",
+		"project_id": project.ID,
+		"steps":      steps,
+	})
+}
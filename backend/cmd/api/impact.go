@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits of an impact analysis.
+const (
+	defaultImpactDepth = 3
+	maxImpactDepth     = 10
+	// maxImpactNodes caps the dependents collected; the walk stops once it is reached.
+	maxImpactNodes = 5000
+	// maxImpactInputs caps the nodes and changed files a request can name.
+	maxImpactInputs = 1000
+	impactTimeout   = 30 * time.Second
+)
+
+// impactNode is a function, class or file in an impact report.
+type impactNode struct {
+	ID       string `json:"id"`
+	NodeID   string `json:"node_id,omitempty"`
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Distance int    `json:"distance"`
+}
+
+// impactRequest names what changed: symbols or files (node, see resolveSymbol) and
+// changed file paths, e.g. the files touched by a patch.
+type impactRequest struct {
+	Nodes         []string `json:"nodes"`
+	Files         []string `json:"files"`
+	Depth         *int     `json:"depth"`
+	MinConfidence *float64 `json:"min_confidence"`
+}
+
+// Each level of the walk and the expansion of the changed symbols return their nodes in
+// this shape.
+const impactNodeColumns = `
+	RETURN DISTINCT elementId(n) AS id, n.id AS node_id, labels(n)[0] AS type,
+		coalesce(n.name, n.path) AS name, coalesce(n.path, split(n.id, '#')[0]) AS path
+`
+
+// impactHandler reports the blast radius of a change: every function, class and file that
+// transitively depends on the changed symbols or files, through incoming CALLS, MAY_CALL,
+// IMPORTS and HAS_METHOD relationships, grouped by distance and by file. MAY_CALL edges,
+// the candidates of calls that could not be bound to a single function, are followed when
+// their confidence is at least min_confidence (0, the default, follows them all). GET
+// takes node and files parameters (both repeatable, files also comma-separated), depth
+// and min_confidence; POST takes the same as a JSON body, for patches touching many files.
+func (app *application) impactHandler(w http.ResponseWriter, r *http.Request) {
+	project := projectFromContext(r)
+
+	var req impactRequest
+	if r.Method == http.MethodPost {
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			app.errorResponse(w, r, http.StatusBadRequest, "Invalid impact request: "+err.Error())
+			return
+		}
+	} else {
+		query := r.URL.Query()
+		req.Nodes = query["node"]
+		for _, v := range query["files"] {
+			req.Files = append(req.Files, strings.Split(v, ",")...)
+		}
+		if v := query.Get("depth"); v != "" {
+			depth, err := intParam(v, defaultImpactDepth, 1, maxImpactDepth)
+			if err != nil {
+				app.errorResponse(w, r, http.StatusBadRequest, "depth "+err.Error())
+				return
+			}
+			req.Depth = &depth
+		}
+		if v := query.Get("min_confidence"); v != "" {
+			minConfidence, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				app.errorResponse(w, r, http.StatusBadRequest, "min_confidence must be a number between 0 and 1")
+				return
+			}
+			req.MinConfidence = &minConfidence
+		}
+	}
+	depth := defaultImpactDepth
+	if req.Depth != nil {
+		depth = *req.Depth
+	}
+	if depth < 1 || depth > maxImpactDepth {
+		app.errorResponse(w, r, http.StatusBadRequest, fmt.Sprintf("depth must be between 1 and %d", maxImpactDepth))
+		return
+	}
+	minConfidence := 0.0
+	if req.MinConfidence != nil {
+		minConfidence = *req.MinConfidence
+	}
+	if minConfidence < 0 || minConfidence > 1 {
+		app.errorResponse(w, r, http.StatusBadRequest, "min_confidence must be a number between 0 and 1")
+		return
+	}
+	files := cleanPaths(req.Files)
+	if len(req.Nodes) == 0 && len(files) == 0 {
+		app.errorResponse(w, r, http.StatusBadRequest, "node or files is required")
+		return
+	}
+	if len(req.Nodes)+len(files) > maxImpactInputs {
+		app.errorResponse(w, r, http.StatusBadRequest, fmt.Sprintf("too many nodes and files; at most %d are allowed", maxImpactInputs))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), impactTimeout)
+	defer cancel()
+
+	var changed []string
+	for _, ref := range req.Nodes {
+		n, err := app.resolveSymbol(ctx, project.ID, ref)
+		if err != nil {
+			app.writeSymbolError(w, r, "node", err)
+			return
+		}
+		changed = append(changed, n.ElementId)
+	}
+	// Changed paths unknown to the graph are reported rather than rejected: a patch
+	// may add files that were never analysed.
+	unmatched := []string{}
+	if len(files) > 0 {
+		records, err := app.db.ProjectQuery(ctx, project.ID, `
+			MATCH (f:File {project_id: $projectId}) WHERE f.path IN $paths
+			RETURN elementId(f) AS id, f.path AS path
+		`, map[string]any{"paths": files})
+		if err != nil {
+			app.writeImpactError(w, r, err)
+			return
+		}
+		found := make(map[string]bool, len(records))
+		for _, record := range records {
+			changed = append(changed, record["id"].(string))
+			found[record["path"].(string)] = true
+		}
+		for _, path := range files {
+			if !found[path] {
+				unmatched = append(unmatched, path)
+			}
+		}
+	}
+
+	nodes, truncated, err := app.walkImpact(ctx, project.ID, changed, depth, minConfidence)
+	if err != nil {
+		app.writeImpactError(w, r, err)
+		return
+	}
+
+	changedNodes := []impactNode{}
+	byDistance := make([][]impactNode, depth)
+	byFile := map[string][]impactNode{}
+	for _, n := range nodes {
+		if n.Distance == 0 {
+			changedNodes = append(changedNodes, n)
+			continue
+		}
+		byDistance[n.Distance-1] = append(byDistance[n.Distance-1], n)
+		byFile[n.Path] = append(byFile[n.Path], n)
+	}
+	distances := []map[string]any{}
+	for i, level := range byDistance {
+		if len(level) > 0 {
+			distances = append(distances, map[string]any{"distance": i + 1, "nodes": level})
+		}
+	}
+	paths := make([]string, 0, len(byFile))
+	for path := range byFile {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	filesOut := make([]map[string]any, 0, len(paths))
+	for _, path := range paths {
+		// Nodes were appended in walk order, so the first is the closest.
+		filesOut = append(filesOut, map[string]any{"path": path, "distance": byFile[path][0].Distance, "nodes": byFile[path]})
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{
+		"depth":           depth,
+		"min_confidence":  minConfidence,
+		"changed":         changedNodes,
+		"unmatched_files": unmatched,
+		"by_distance":     distances,
+		"by_file":         filesOut,
+		"total":           len(nodes) - len(changedNodes),
+		"truncated":       truncated,
+	})
+}
+
+// walkImpact returns the changed nodes, the files, classes and functions inside them and,
+// breadth first, everything depending on those up to depth relationships away. Changed
+// files and classes stand for their contents, since callers depend on a file's functions
+// and a class's methods rather than on the file or class node itself. Each node is
+// visited once, at its shortest distance, so cycles end the walk instead of repeating it.
+// Possible calls are followed when their confidence is at least minConfidence.
+func (app *application) walkImpact(ctx context.Context, projectID string, changed []string, depth int, minConfidence float64) ([]impactNode, bool, error) {
+	if len(changed) == 0 {
+		return nil, false, nil
+	}
+	records, err := app.db.ProjectQuery(ctx, projectID, `
+		MATCH (s {project_id: $projectId}) WHERE elementId(s) IN $changed
+		MATCH (s)-[:CONTAINS|HAS_METHOD*0..2]->(n:File|Class|Function {project_id: $projectId})
+	`+impactNodeColumns, map[string]any{"changed": changed})
+	if err != nil {
+		return nil, false, err
+	}
+
+	var nodes []impactNode
+	seen := map[string]bool{}
+	add := func(records []map[string]any, distance int) []string {
+		var added []string
+		for _, record := range records {
+			id := record["id"].(string)
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			n := impactNode{ID: id, Distance: distance}
+			n.NodeID, _ = record["node_id"].(string)
+			n.Type, _ = record["type"].(string)
+			n.Name, _ = record["name"].(string)
+			n.Path, _ = record["path"].(string)
+			nodes = append(nodes, n)
+			added = append(added, id)
+		}
+		return added
+	}
+	frontier := add(records, 0)
+
+	for distance := 1; distance <= depth && len(frontier) > 0; distance++ {
+		records, err := app.db.ProjectQuery(ctx, projectID, `
+			MATCH (m {project_id: $projectId}) WHERE elementId(m) IN $frontier
+			MATCH (n:File|Class|Function {project_id: $projectId})-[r:CALLS|MAY_CALL|IMPORTS|HAS_METHOD]->(m)
+			WHERE NOT r:MAY_CALL OR r.confidence >= $minConfidence
+		`+impactNodeColumns+`
+			ORDER BY path, name
+		`, map[string]any{"frontier": frontier, "minConfidence": minConfidence})
+		if err != nil {
+			return nil, false, err
+		}
+		frontier = add(records, distance)
+		if len(nodes) >= maxImpactNodes {
+			return nodes[:maxImpactNodes], true, nil
+		}
+	}
+	return nodes, false, nil
+}
+
+// writeImpactError answers a failed impact query, 504 when it ran out of time.
+func (app *application) writeImpactError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		app.errorResponse(w, r, http.StatusGatewayTimeout, "Impact analysis took too long; lower the depth")
+		return
+	}
+	app.errorResponse(w, r, http.StatusInternalServerError, "Failed to analyse impact: "+err.Error())
+}
+
+// cleanPaths trims the changed file paths of a request, drops empty ones and the "./"
+// prefix, and removes duplicates.
+func cleanPaths(paths []string) []string {
+	var cleaned []string
+	seen := map[string]bool{}
+	for _, path := range paths {
+		path = strings.TrimPrefix(strings.TrimSpace(path), "./")
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		cleaned = append(cleaned, path)
+	}
+	return cleaned
+}
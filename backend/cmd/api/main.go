@@ -12,7 +12,10 @@ import (
 	"time"
 
 	"github.com/1107-adishjain/codemap/internal/config"
+	"github.com/1107-adishjain/codemap/internal/events"
+	"github.com/1107-adishjain/codemap/internal/jobs"
 	"github.com/1107-adishjain/codemap/internal/s3"
+	"github.com/1107-adishjain/codemap/internal/source"
 
 	"github.com/1107-adishjain/codemap/internal/database"
 
@@ -24,6 +27,12 @@ type application struct {
 	db     *database.DB
 	logger *log.Logger
 	s3     *s3.Service
+	jobs   *jobs.Pool
+	events *events.Broker
+	source *source.Cache
+	// shutdown is done once the server starts shutting down, which long-lived
+	// streams watch so that they end instead of holding the shutdown up.
+	shutdown context.Context
 }
 
 func main() {
@@ -52,6 +61,12 @@ func main() {
 	}
 	defer dbNeo4j.Close(context.Background())
 
+	if err := dbNeo4j.EnsureSchema(context.Background()); err != nil {
+		logger.Fatalf("Could not apply Neo4j schema: %v", err)
+	} else {
+		logger.Printf("Neo4j schema at version %d", database.SchemaVersion())
+	}
+
 	// put the Postgres connection into the Neo4j DB struct:
 	dbNeo4j.SQL = db
 
@@ -62,11 +77,20 @@ func main() {
 		logger.Println("S3 succesfully initalized")
 	}
 
+	broker := events.NewBroker()
+	jobPool := jobs.NewPool(cfg, dbNeo4j, s3Service, broker, logger)
+	jobPool.Start()
+
+	shutdown, startShutdown := context.WithCancel(context.Background())
 	app := &application{
-		config: cfg,
-		db:     dbNeo4j,
-		logger: logger,
-		s3:     s3Service,
+		config:   cfg,
+		db:       dbNeo4j,
+		logger:   logger,
+		s3:       s3Service,
+		jobs:     jobPool,
+		events:   broker,
+		source:   source.NewCache(cfg.SourceCacheDir, cfg.SourceCacheEntries, s3Service),
+		shutdown: shutdown,
 	}
 
 	srv := &http.Server{
@@ -77,6 +101,7 @@ func main() {
 		ReadTimeout:  10 * time.Minute,
 		WriteTimeout: 45 * time.Minute,
 	}
+	srv.RegisterOnShutdown(startShutdown)
 
 	shutdownError := make(chan error)
 
@@ -89,8 +114,16 @@ func main() {
 
 		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 		defer cancel()
-
-		shutdownError <- srv.Shutdown(ctx)
+		err := srv.Shutdown(ctx)
+
+		// Stop the workers after the HTTP server so no new jobs are enqueued meanwhile,
+		// and even if it did not stop in time, so that running jobs are requeued.
+		stopCtx, cancelStop := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancelStop()
+		if stopErr := jobPool.Stop(stopCtx); err == nil {
+			err = stopErr
+		}
+		shutdownError <- err
 	}()
 
 	logger.Printf("Starting server on %s", srv.Addr)
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/1107-adishjain/codemap/internal/database"
+	"github.com/1107-adishjain/codemap/internal/metrics"
+)
+
+// metricKinds maps the kind parameter of the metrics endpoint to node labels.
+var metricKinds = map[string]string{"function": "Function", "class": "Class", "file": "File"}
+
+// metricsHandler lists the scored functions, classes and files of a project (see the
+// metrics package), sorted by sort (pagerank by default) in order (desc by default).
+// kind limits the node kinds (function, class, file; comma-separated), path keeps the
+// nodes under a path prefix, name those whose name contains a string, and min_<metric>
+// and max_<metric> bound a metric, e.g. min_fan_in=10. Results are paginated like the
+// query endpoints and can be streamed as NDJSON.
+func (app *application) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	project := projectFromContext(r)
+	values := r.URL.Query()
+
+	labels := slices.Clone(metrics.Labels)
+	if v := values.Get("kind"); v != "" {
+		labels = nil
+		for _, kind := range strings.Split(v, ",") {
+			label, ok := metricKinds[strings.TrimSpace(kind)]
+			if !ok {
+				app.errorResponse(w, r, http.StatusBadRequest, fmt.Sprintf("unknown kind %q (expected function, class or file)", kind))
+				return
+			}
+			labels = append(labels, label)
+		}
+	}
+	sortBy := metrics.PageRank
+	if v := values.Get("sort"); v != "" {
+		if !slices.Contains(metrics.Names, v) {
+			app.errorResponse(w, r, http.StatusBadRequest, "sort must be one of "+strings.Join(metrics.Names, ", "))
+			return
+		}
+		sortBy = v
+	}
+	order := "DESC"
+	switch values.Get("order") {
+	case "", "desc":
+	case "asc":
+		order = "ASC"
+	default:
+		app.errorResponse(w, r, http.StatusBadRequest, "order must be asc or desc")
+		return
+	}
+
+	// Metric names and labels are checked against fixed lists above; every value is
+	// passed as a parameter.
+	params := map[string]any{}
+	where := []string{"n.pagerank IS NOT NULL"}
+	if v := values.Get("path"); v != "" {
+		params["path"] = v
+		where = append(where, "coalesce(n.path, split(n.id, '#')[0]) STARTS WITH $path")
+	}
+	if v := values.Get("name"); v != "" {
+		params["name"] = v
+		where = append(where, "toLower(coalesce(n.name, n.path)) CONTAINS toLower($name)")
+	}
+	for _, name := range metrics.Names {
+		for _, bound := range []struct{ prefix, op string }{{"min_", ">="}, {"max_", "<="}} {
+			v := values.Get(bound.prefix + name)
+			if v == "" {
+				continue
+			}
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				app.errorResponse(w, r, http.StatusBadRequest, bound.prefix+name+" must be a number")
+				return
+			}
+			params[bound.prefix+name] = f
+			where = append(where, fmt.Sprintf("n.%s %s $%s%s", name, bound.op, bound.prefix, name))
+		}
+	}
+	query := fmt.Sprintf(`
+		MATCH (n:%s {project_id: $projectId})
+		WHERE %s
+		RETURN elementId(n) AS id, n.id AS node_id, labels(n)[0] AS type,
+			coalesce(n.name, n.path) AS name, coalesce(n.path, split(n.id, '#')[0]) AS path,
+			n.fan_in AS fan_in, n.fan_out AS fan_out, n.pagerank AS pagerank,
+			n.betweenness AS betweenness, n.instability AS instability
+		ORDER BY n.%s %s, elementId(n)
+	`, strings.Join(labels, "|"), strings.Join(where, " AND "), sortBy, order)
+
+	p, err := app.parsePage(r, app.config.QueryPageSize)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	var computedAt any
+	if !p.stream {
+		records, err := app.db.ProjectQuery(ctx, project.ID, "MATCH (p:Project {id: $projectId}) RETURN p.metrics_computed_at AS at", nil)
+		if err != nil {
+			app.errorResponse(w, r, http.StatusInternalServerError, "Failed to read metrics: "+err.Error())
+			return
+		}
+		if len(records) > 0 {
+			computedAt = records[0]["at"]
+		}
+	}
+	app.writeQueryResults(ctx, w, r, p, project.ID, query, params, func(records []map[string]any, next *int) any {
+		return map[string]any{
+			"project_id":  project.ID,
+			"computed_at": computedAt,
+			"sort":        sortBy,
+			"order":       strings.ToLower(order),
+			"nodes":       records,
+			"next_offset": next,
+		}
+	})
+}
+
+// recomputeMetricsHandler scores the project graph again, e.g. for projects imported
+// before metrics existed. It is refused while an analysis job is running, since the job
+// computes them itself once its import is done.
+func (app *application) recomputeMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	project := projectFromContext(r)
+	job, err := app.db.GetLatestJobByProject(project.ID)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusInternalServerError, "Failed to fetch job: "+err.Error())
+		return
+	}
+	if job != nil && !database.Terminal(job.Stage) {
+		app.errorResponse(w, r, http.StatusConflict, "Project is being analysed; metrics are computed when the job finishes")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Minute)
+	defer cancel()
+	stats, err := metrics.Compute(ctx, app.db, project.ID)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			app.errorResponse(w, r, http.StatusGatewayTimeout, "Computing metrics took too long")
+			return
+		}
+		app.errorResponse(w, r, http.StatusInternalServerError, "Failed to compute metrics: "+err.Error())
+		return
+	}
+	app.writeJSON(w, http.StatusOK, map[string]any{
+		"project_id":  project.ID,
+		"nodes":       stats.Nodes,
+		"duration_ms": stats.Duration.Milliseconds(),
+	})
+}
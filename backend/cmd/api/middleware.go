@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/1107-adishjain/codemap/internal/database"
+	middlewares "github.com/1107-adishjain/codemap/internal/middleware"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type contextKey string
+
+const projectContextKey contextKey = "project"
+
+// requireProject loads the project a request is about and checks that the authenticated
+// user owns it, answering 404 for unknown projects and 403 for other users' projects.
+// The project is named by the {id} URL parameter on /projects/{id} routes and by the
+// projectId query parameter elsewhere. Handlers behind it get the project through
+// projectFromContext.
+func (app *application) requireProject(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(middlewares.UserIDKey).(string)
+		if !ok || userID == "" {
+			app.errorResponse(w, r, http.StatusUnauthorized, "User ID not found in context")
+			return
+		}
+		projectID := chi.URLParam(r, "id")
+		if projectID == "" {
+			projectID = r.URL.Query().Get("projectId")
+			if projectID == "" {
+				app.errorResponse(w, r, http.StatusBadRequest, "projectId parameter is required")
+				return
+			}
+		}
+		if _, err := uuid.Parse(projectID); err != nil {
+			app.errorResponse(w, r, http.StatusNotFound, "Project not found")
+			return
+		}
+		project, err := app.db.GetProjectByID(projectID)
+		if err != nil {
+			app.errorResponse(w, r, http.StatusInternalServerError, "Failed to fetch project: "+err.Error())
+			return
+		}
+		if project == nil {
+			app.errorResponse(w, r, http.StatusNotFound, "Project not found")
+			return
+		}
+		if project.UserID != userID {
+			app.errorResponse(w, r, http.StatusForbidden, "You do not have access to this project")
+			return
+		}
+		ctx := context.WithValue(r.Context(), projectContextKey, project)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// projectFromContext returns the project loaded by requireProject.
+func projectFromContext(r *http.Request) *database.Project {
+	return r.Context().Value(projectContextKey).(*database.Project)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ndjsonContentType selects, and labels, the streaming mode of the query endpoints.
+const ndjsonContentType = "application/x-ndjson"
+
+// streamFlushEvery is how many NDJSON records are written between flushes.
+const streamFlushEvery = 100
+
+// page is a client's request for part of a query's records. Pages are offsets into the
+// query's results: each page re-runs the query and skips the records before it, so a
+// page costs as much as every page before it, and consecutive pages only line up for
+// queries with a total ORDER BY on data that is not being reimported meanwhile.
+type page struct {
+	offset int
+	limit  int
+	// stream asks for every record, as NDJSON, instead of one page.
+	stream bool
+}
+
+// parsePage reads the limit and offset parameters, or the NDJSON streaming mode
+// (format=ndjson or an Accept header naming application/x-ndjson), of a query request.
+func (app *application) parsePage(r *http.Request, defaultLimit int) (page, error) {
+	p := page{limit: defaultLimit}
+	values := r.URL.Query()
+	p.stream = values.Get("format") == "ndjson" || strings.Contains(r.Header.Get("Accept"), ndjsonContentType)
+
+	if v := values.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > app.config.QueryMaxPageSize {
+			return page{}, fmt.Errorf("limit must be between 1 and %d", app.config.QueryMaxPageSize)
+		}
+		p.limit = n
+	}
+	if v := values.Get("offset"); v != "" {
+		if p.stream {
+			return page{}, errors.New("offset cannot be combined with streaming")
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return page{}, errors.New("offset must be a non-negative integer")
+		}
+		p.offset = n
+	}
+	return p, nil
+}
+
+// writeQueryResults runs a project query and writes its records. By default one page is
+// written as JSON, in the form built by shape from the page's records and the offset of
+// the next page (nil on the last page); the offset is also sent as X-Next-Offset. In
+// streaming mode every record is written as an NDJSON line as Neo4j yields it, up to
+// QueryStreamMaxRows, followed by a summary line.
+func (app *application) writeQueryResults(
+	ctx context.Context, w http.ResponseWriter, r *http.Request, p page,
+	projectID, query string, params map[string]any,
+	shape func(records []map[string]any, next *int) any,
+) {
+	if p.stream {
+		app.streamQueryResults(ctx, w, r, projectID, query, params)
+		return
+	}
+	records, more, err := app.db.ProjectQueryPage(ctx, projectID, query, params, p.offset, p.limit)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusInternalServerError, "Failed to execute query: "+err.Error())
+		return
+	}
+	var next *int
+	if more {
+		offset := p.offset + p.limit
+		next = &offset
+		w.Header().Set("X-Next-Offset", strconv.Itoa(offset))
+	}
+	app.writeJSON(w, http.StatusOK, shape(records, next))
+}
+
+// streamQueryResults writes every record of a project query as a line
+// {"type":"record","record":{...}}, then {"type":"summary","rows":n,"truncated":bool}.
+// Errors before the first record get a normal error response; later ones, once the
+// status has been sent, end the stream with {"type":"error","error":"..."}.
+func (app *application) streamQueryResults(ctx context.Context, w http.ResponseWriter, r *http.Request, projectID, query string, params map[string]any) {
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	started := false
+	start := func() {
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", ndjsonContentType)
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+
+	written := 0
+	rows, truncated, err := app.db.ProjectQueryEach(ctx, projectID, query, params, app.config.QueryStreamMaxRows, func(record map[string]any) error {
+		start()
+		if err := enc.Encode(map[string]any{"type": "record", "record": record}); err != nil {
+			return err
+		}
+		written++
+		if flusher != nil && written%streamFlushEvery == 0 {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil && !started {
+		app.errorResponse(w, r, http.StatusInternalServerError, "Failed to execute query: "+err.Error())
+		return
+	}
+	start()
+	if err != nil {
+		app.logError(r, err)
+		enc.Encode(map[string]any{"type": "error", "error": err.Error()})
+		return
+	}
+	enc.Encode(map[string]any{"type": "summary", "rows": rows, "truncated": truncated})
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/1107-adishjain/codemap/internal/cypher"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Limits of the path finder. Paths are followed with relationship uniqueness and only
+// simple paths (no repeated node) are kept, so cycles can't make a search run forever,
+// but the number of paths grows quickly with depth.
+const (
+	defaultPathDepth = 6
+	maxPathDepth     = 8
+	defaultPathCount = 5
+	maxPathCount     = 25
+	pathTimeout      = 20 * time.Second
+)
+
+// defaultPathKinds are the relationships followed when kinds is not given. MAY_CALL
+// links a call that could not be bound to a single function to each of its candidates.
+var defaultPathKinds = []string{"CALLS", "MAY_CALL", "IMPORTS", "HAS_METHOD"}
+
+// errSymbolNotFound and errAmbiguousSymbol are returned by resolveSymbol.
+var (
+	errSymbolNotFound  = errors.New("symbol not found")
+	errAmbiguousSymbol = errors.New("symbol is ambiguous")
+)
+
+// maxSymbolCandidates caps the candidates listed for an ambiguous symbol.
+const maxSymbolCandidates = 10
+
+// symbolError names the reference that matched no node, or several.
+type symbolError struct {
+	ref        string
+	err        error
+	candidates []map[string]any
+}
+
+func (e *symbolError) Error() string { return fmt.Sprintf("%s: %s", e.err, e.ref) }
+func (e *symbolError) Unwrap() error { return e.err }
+
+// resolveSymbol finds the node a client refers to: by element ID, node ID (e.g.
+// "src/app.go#main"), File path or, failing those, a Function or Class name that is
+// unique in the project.
+func (app *application) resolveSymbol(ctx context.Context, projectID, ref string) (neo4j.Node, error) {
+	records, err := app.db.ProjectQuery(ctx, projectID, `
+		MATCH (n:File|Class|Function {project_id: $projectId})
+		WHERE elementId(n) = $ref OR n.id = $ref OR n.path = $ref
+		RETURN n
+		LIMIT 1
+	`, map[string]any{"ref": ref})
+	if err != nil {
+		return neo4j.Node{}, err
+	}
+	if len(records) == 1 {
+		return records[0]["n"].(neo4j.Node), nil
+	}
+
+	records, err = app.db.ProjectQuery(ctx, projectID, `
+		MATCH (n:Function|Class {project_id: $projectId, name: $ref})
+		RETURN n
+		ORDER BY n.id
+		LIMIT $limit
+	`, map[string]any{"ref": ref, "limit": maxSymbolCandidates + 1})
+	if err != nil {
+		return neo4j.Node{}, err
+	}
+	switch len(records) {
+	case 0:
+		return neo4j.Node{}, &symbolError{ref: ref, err: errSymbolNotFound}
+	case 1:
+		return records[0]["n"].(neo4j.Node), nil
+	}
+	symErr := &symbolError{ref: ref, err: errAmbiguousSymbol}
+	for i, record := range records {
+		if i == maxSymbolCandidates {
+			break
+		}
+		n := record["n"].(neo4j.Node)
+		symErr.candidates = append(symErr.candidates, map[string]any{"id": n.ElementId, "node_id": n.Props["id"], "name": n.Props["name"]})
+	}
+	return neo4j.Node{}, symErr
+}
+
+// writeSymbolError answers a failed resolveSymbol: 404 for unknown symbols, 409 with the
+// candidates for ambiguous ones.
+func (app *application) writeSymbolError(w http.ResponseWriter, r *http.Request, param string, err error) {
+	var symErr *symbolError
+	switch {
+	case errors.As(err, &symErr) && errors.Is(err, errAmbiguousSymbol):
+		app.writeJSON(w, http.StatusConflict, map[string]any{
+			"error":      fmt.Sprintf("%s %q matches several symbols; pass a node ID instead", param, symErr.ref),
+			"candidates": symErr.candidates,
+		})
+	case errors.As(err, &symErr):
+		app.errorResponse(w, r, http.StatusNotFound, fmt.Sprintf("%s %q not found in project", param, symErr.ref))
+	default:
+		app.errorResponse(w, r, http.StatusInternalServerError, "Failed to resolve "+param+": "+err.Error())
+	}
+}
+
+// graphPathsHandler finds how one symbol reaches another: the shortest path and up to k
+// shortest simple paths from `from` to `to`, following the relationship types in kinds
+// (CALLS, MAY_CALL, IMPORTS and HAS_METHOD by default) in their own direction, up to
+// maxDepth hops.
+func (app *application) graphPathsHandler(w http.ResponseWriter, r *http.Request) {
+	project := projectFromContext(r)
+	query := r.URL.Query()
+	from, to := query.Get("from"), query.Get("to")
+	if from == "" || to == "" {
+		app.errorResponse(w, r, http.StatusBadRequest, "from and to parameters are required")
+		return
+	}
+	depth, err := intParam(query.Get("maxDepth"), defaultPathDepth, 1, maxPathDepth)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "maxDepth "+err.Error())
+		return
+	}
+	k, err := intParam(query.Get("k"), defaultPathCount, 1, maxPathCount)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "k "+err.Error())
+		return
+	}
+	kinds := defaultPathKinds
+	if v := query.Get("kinds"); v != "" {
+		kinds = strings.Split(v, ",")
+	}
+	types, err := cypher.RelationshipPattern(kinds)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), pathTimeout)
+	defer cancel()
+
+	source, err := app.resolveSymbol(ctx, project.ID, from)
+	if err != nil {
+		app.writeSymbolError(w, r, "from", err)
+		return
+	}
+	target, err := app.resolveSymbol(ctx, project.ID, to)
+	if err != nil {
+		app.writeSymbolError(w, r, "to", err)
+		return
+	}
+	if source.ElementId == target.ElementId {
+		app.errorResponse(w, r, http.StatusBadRequest, "from and to name the same symbol")
+		return
+	}
+
+	params := map[string]any{"from": source.ElementId, "to": target.ElementId, "k": k}
+	// Relationship types and the depth bound cannot be parameters; both were validated above.
+	endpoints := `
+		MATCH (a {project_id: $projectId}) WHERE elementId(a) = $from
+		MATCH (b {project_id: $projectId}) WHERE elementId(b) = $to
+	`
+	// shortestPath and allShortestPaths are answered by a bidirectional search, so they are
+	// cheap even when no path exists. Should the shortest paths be fewer than k, longer ones
+	// are searched one length at a time, each search stopping as soon as it has the paths
+	// still missing, rather than listing every path up to maxDepth to sort them.
+	shortestFn := "shortestPath"
+	if k > 1 {
+		shortestFn = "allShortestPaths"
+	}
+	records, err := app.db.ProjectQuery(ctx, project.ID, endpoints+fmt.Sprintf(`
+		MATCH p = %s((a)-[:%s*1..%d]->(b))
+		RETURN p
+		LIMIT $k
+	`, shortestFn, types, depth), params)
+	if err == nil && len(records) > 0 && len(records) < k {
+		shortest := len(records[0]["p"].(neo4j.Path).Relationships)
+		for length := shortest + 1; length <= depth && len(records) < k; length++ {
+			params["k"] = k - len(records)
+			var more []map[string]any
+			more, err = app.db.ProjectQuery(ctx, project.ID, endpoints+fmt.Sprintf(`
+				MATCH p = (a)-[:%s*%d]->(b)
+				WHERE all(n IN nodes(p) WHERE single(m IN nodes(p) WHERE m = n))
+				RETURN p
+				LIMIT $k
+			`, types, length), params)
+			if err != nil {
+				break
+			}
+			records = append(records, more...)
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		app.errorResponse(w, r, http.StatusGatewayTimeout, "Path search took too long; lower maxDepth or narrow kinds")
+		return
+	}
+	if err != nil {
+		app.errorResponse(w, r, http.StatusInternalServerError, "Failed to find paths: "+err.Error())
+		return
+	}
+
+	paths := make([]map[string]any, 0, len(records))
+	for _, record := range records {
+		p := record["p"].(neo4j.Path)
+		nodeIDs := make([]string, len(p.Nodes))
+		for i, n := range p.Nodes {
+			nodeIDs[i] = n.ElementId
+		}
+		edgeIDs := make([]string, len(p.Relationships))
+		for i, rel := range p.Relationships {
+			edgeIDs[i] = rel.ElementId
+		}
+		paths = append(paths, map[string]any{"length": len(p.Relationships), "nodes": nodeIDs, "edges": edgeIDs})
+	}
+	graph, ok := graphElements(records)
+	if !ok {
+		graph = map[string]any{"nodes": []any{}, "edges": []any{}}
+	}
+	// Paths are ordered by length, so the first is a shortest one.
+	var shortest any
+	if len(paths) > 0 {
+		shortest = paths[0]
+	}
+	graph["from"] = source.ElementId
+	graph["to"] = target.ElementId
+	graph["shortest"] = shortest
+	graph["paths"] = paths
+	app.writeJSON(w, http.StatusOK, graph)
+}
+
+// intParam parses an optional integer parameter in [min, max], returning def when empty.
+func intParam(value string, def, min, max int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("must be between %d and %d", min, max)
+	}
+	return n, nil
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reportTimeout bounds the queries behind a report.
+const reportTimeout = 60 * time.Second
+
+// csvContentType selects, and labels, the CSV form of a report.
+const csvContentType = "text/csv"
+
+// Built-in kinds of entry point, named by the roots parameter of the dead-code report.
+const (
+	rootsMain     = "main"
+	rootsTests    = "tests"
+	rootsHandlers = "handlers"
+)
+
+// nonModuleLanguages are analysed languages whose files are not imported by code, so they
+// are never reported as unimported.
+var nonModuleLanguages = []string{"html", "css", "json", "yaml"}
+
+// testFilePattern matches the test files of the languages the analyser supports.
+var testFilePattern = regexp.MustCompile(`(^|/)(tests?|__tests__|spec)/|_test\.(go|py)$|(^|/)test_[^/]*\.py$|\.(test|spec)\.[jt]sx?$|Tests?\.(java|kt)$`)
+
+// mainFilePattern matches the files programs usually start from.
+var mainFilePattern = regexp.MustCompile(`(^|/)(main|index|__main__|__init__)\.[^/]+$`)
+
+// unreferencedClassReason is the reason given for every class of the dead-code report.
+const unreferencedClassReason = "unreferenced class (heuristic): no method called from outside the class " +
+	"and file never imported; instantiations and same-file references are not tracked"
+
+// handlerNamePattern matches the names of functions that serve HTTP requests and so are
+// called by a router rather than by project code.
+var handlerNamePattern = regexp.MustCompile(`(?i)handler$|^ServeHTTP$`)
+
+// deadCodeItem is a function, class or file the dead-code report lists.
+type deadCodeItem struct {
+	Kind   string `json:"kind"`
+	ID     string `json:"id,omitempty"`
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Line   *int64 `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// entryPoints decides which functions, classes and files are roots: code that is used
+// even though nothing in the graph refers to it.
+type entryPoints struct {
+	main, tests, handlers bool
+	// patterns are the entry globs, matched against file paths and node IDs.
+	patterns []*regexp.Regexp
+}
+
+// parseEntryPoints reads the roots parameter, a comma-separated list of the built-in kinds
+// of entry point to honour (main, tests and handlers by default; empty for none), and the
+// repeatable entry parameter, a glob such as "cmd/**", "**/*_test.go" or
+// "src/server.js#start". '*' matches within a path segment and '**' across segments.
+func parseEntryPoints(r *http.Request) (entryPoints, error) {
+	query := r.URL.Query()
+	e := entryPoints{main: true, tests: true, handlers: true}
+	if query.Has("roots") {
+		e = entryPoints{}
+		for _, kind := range strings.Split(query.Get("roots"), ",") {
+			switch strings.TrimSpace(kind) {
+			case rootsMain:
+				e.main = true
+			case rootsTests:
+				e.tests = true
+			case rootsHandlers:
+				e.handlers = true
+			case "":
+			default:
+				return entryPoints{}, fmt.Errorf("unknown roots kind %q (expected main, tests or handlers)", kind)
+			}
+		}
+	}
+	for _, pattern := range query["entry"] {
+		re, err := globPattern(pattern)
+		if err != nil {
+			return entryPoints{}, err
+		}
+		e.patterns = append(e.patterns, re)
+	}
+	return e, nil
+}
+
+// isRootFile reports whether everything in the file at p is an entry point.
+func (e entryPoints) isRootFile(p string) bool {
+	if e.tests && testFilePattern.MatchString(p) {
+		return true
+	}
+	if e.main && mainFilePattern.MatchString(p) {
+		return true
+	}
+	for _, re := range e.patterns {
+		if re.MatchString(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRootSymbol reports whether the function or class name, with node ID id, in the file
+// at p is an entry point.
+func (e entryPoints) isRootSymbol(id, name, p string, function bool) bool {
+	if e.isRootFile(p) {
+		return true
+	}
+	if function && e.main && (name == "main" || name == "init") {
+		return true
+	}
+	if function && e.handlers && handlerNamePattern.MatchString(name) {
+		return true
+	}
+	for _, re := range e.patterns {
+		if re.MatchString(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// globPattern compiles an entry glob into an anchored regexp.
+func globPattern(glob string) (*regexp.Regexp, error) {
+	if glob == "" {
+		return nil, errors.New("entry pattern must not be empty")
+	}
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				// "**/" also matches no directory at all.
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					b.WriteString("(.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/#]*")
+			}
+		case '?':
+			b.WriteString("[^/#]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// deadCodeReportHandler lists code nothing refers to: non-exported functions no other
+// function calls, classes whose methods are never called from outside the class and
+// whose file no other file imports, and files no other file imports. A call that may
+// target a function (MAY_CALL) counts as a call, so ambiguous calls keep all their
+// candidates alive. Entry points (see parseEntryPoints) are never reported. The report
+// is JSON, or CSV with format=csv or an Accept header naming text/csv.
+//
+// The class section is a heuristic: the graph records neither instantiations (new Foo())
+// nor references from a class's own file, so a class used only that way is listed. The
+// JSON response lists the heuristic sections under "heuristic", and each class's reason
+// says the same.
+func (app *application) deadCodeReportHandler(w http.ResponseWriter, r *http.Request) {
+	project := projectFromContext(r)
+	entries, err := parseEntryPoints(r)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), reportTimeout)
+	defer cancel()
+	items, err := app.findDeadCode(ctx, project.ID, entries)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			app.errorResponse(w, r, http.StatusGatewayTimeout, "Dead code report took too long")
+			return
+		}
+		app.errorResponse(w, r, http.StatusInternalServerError, "Failed to build dead code report: "+err.Error())
+		return
+	}
+
+	if wantsCSV(r) {
+		rows := make([][]string, 0, len(items))
+		for _, item := range items {
+			line := ""
+			if item.Line != nil {
+				line = strconv.FormatInt(*item.Line, 10)
+			}
+			rows = append(rows, []string{item.Kind, item.Path, item.Name, item.ID, line, item.Reason})
+		}
+		app.writeCSV(w, r, "dead-code-"+project.ID+".csv", []string{"kind", "path", "name", "id", "line", "reason"}, rows)
+		return
+	}
+
+	functions, classes, files := []deadCodeItem{}, []deadCodeItem{}, []deadCodeItem{}
+	for _, item := range items {
+		switch item.Kind {
+		case "function":
+			functions = append(functions, item)
+		case "class":
+			classes = append(classes, item)
+		default:
+			files = append(files, item)
+		}
+	}
+	app.writeJSON(w, http.StatusOK, map[string]any{
+		"project_id": project.ID,
+		"functions":  functions,
+		"classes":    classes,
+		"files":      files,
+		"heuristic":  []string{"classes"},
+		"summary": map[string]int{
+			"functions": len(functions),
+			"classes":   len(classes),
+			"files":     len(files),
+		},
+	})
+}
+
+// findDeadCode runs the dead-code queries and drops the entry points from their results.
+func (app *application) findDeadCode(ctx context.Context, projectID string, entries entryPoints) ([]deadCodeItem, error) {
+	var items []deadCodeItem
+
+	// Recursive calls don't keep a function alive.
+	records, err := app.db.ProjectQuery(ctx, projectID, `
+		MATCH (fn:Function {project_id: $projectId})
+		WHERE coalesce(fn.is_exported, false) = false
+			AND NOT EXISTS { MATCH (caller:Function)-[:CALLS|MAY_CALL]->(fn) WHERE caller <> fn }
+		RETURN fn.id AS id, fn.name AS name, split(fn.id, '#')[0] AS path, fn.start_line AS line
+		ORDER BY id
+	`, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		item := deadCodeRecord("function", record, "not exported and never called")
+		if !entries.isRootSymbol(item.ID, item.Name, item.Path, true) {
+			items = append(items, item)
+		}
+	}
+
+	records, err = app.db.ProjectQuery(ctx, projectID, `
+		MATCH (c:Class {project_id: $projectId})
+		WHERE NOT EXISTS {
+				MATCH (c)-[:HAS_METHOD|OWNS_METHOD]->(:Function)<-[:CALLS|MAY_CALL]-(caller:Function)
+				WHERE NOT (c)-[:HAS_METHOD|OWNS_METHOD]->(caller)
+			}
+			AND NOT EXISTS {
+				MATCH (f:File)-[:CONTAINS]->(c)
+				MATCH (other:File)-[:IMPORTS]->(f)
+				WHERE other <> f
+			}
+		RETURN c.id AS id, c.name AS name, split(c.id, '#')[0] AS path, c.start_line AS line
+		ORDER BY id
+	`, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		item := deadCodeRecord("class", record, unreferencedClassReason)
+		if !entries.isRootSymbol(item.ID, item.Name, item.Path, false) {
+			items = append(items, item)
+		}
+	}
+
+	// A file holding an entry-point function is itself an entry point.
+	records, err = app.db.ProjectQuery(ctx, projectID, `
+		MATCH (f:File {project_id: $projectId})
+		WHERE NOT coalesce(f.language, '') IN $skip
+			AND NOT EXISTS { MATCH (other:File)-[:IMPORTS]->(f) WHERE other <> f }
+		OPTIONAL MATCH (f)-[:CONTAINS]->(fn:Function)
+		RETURN f.path AS path, [fn IN collect(fn) | [fn.id, fn.name]] AS functions
+		ORDER BY path
+	`, map[string]any{"skip": nonModuleLanguages})
+	if err != nil {
+		return nil, err
+	}
+files:
+	for _, record := range records {
+		p, _ := record["path"].(string)
+		if entries.isRootFile(p) {
+			continue
+		}
+		functions, _ := record["functions"].([]any)
+		for _, fn := range functions {
+			pair, _ := fn.([]any)
+			if len(pair) != 2 {
+				continue
+			}
+			id, _ := pair[0].(string)
+			name, _ := pair[1].(string)
+			if entries.isRootSymbol(id, name, p, true) {
+				continue files
+			}
+		}
+		items = append(items, deadCodeItem{Kind: "file", Name: path.Base(p), Path: p, Reason: "never imported"})
+	}
+	return items, nil
+}
+
+// deadCodeRecord builds a report item from a function or class record.
+func deadCodeRecord(kind string, record map[string]any, reason string) deadCodeItem {
+	item := deadCodeItem{Kind: kind, Reason: reason}
+	item.ID, _ = record["id"].(string)
+	item.Name, _ = record["name"].(string)
+	item.Path, _ = record["path"].(string)
+	if line, ok := record["line"].(int64); ok {
+		item.Line = &line
+	}
+	return item
+}
+
+// wantsCSV reports whether a report was asked for as CSV.
+func wantsCSV(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "csv" || strings.Contains(r.Header.Get("Accept"), csvContentType)
+}
+
+// writeCSV writes a report as a CSV attachment named filename.
+func (app *application) writeCSV(w http.ResponseWriter, r *http.Request, filename string, header []string, rows [][]string) {
+	w.Header().Set("Content-Type", csvContentType+"; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	cw.WriteAll(rows)
+	if err := cw.Error(); err != nil {
+		app.logError(r, err)
+	}
+}
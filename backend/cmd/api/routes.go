@@ -24,7 +24,7 @@ func (app *application) routes(db *sql.DB) http.Handler {
 		AllowedOrigins:   []string{"http://localhost:3000"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", "X-Next-Offset"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
@@ -40,14 +40,42 @@ func (app *application) routes(db *sql.DB) http.Handler {
 		r.Get("/healthcheck", app.healthCheckHandler)
 		r.Post("/upload", app.uploadHandler)
 		r.Post("/github", app.githubHandler)
-		r.Post("/query", app.queryHandler)
-		
-		// New hierarchical graph endpoints
-		r.Get("/graph/summary", app.graphSummaryHandler)
-		r.Get("/graph/node", app.graphNodeDetailsHandler)
-		r.Get("/graph/files", app.graphFileHierarchyHandler)
-		r.Get("/graph/top-nodes", app.graphTopNodesHandler)
 		r.Get("/projects", app.listProjectsHandler)
+
+		// Everything below reads or changes a single project the user must own.
+		r.Group(func(r chi.Router) {
+			r.Use(app.requireProject)
+			r.Post("/query", app.queryHandler)
+
+			// New hierarchical graph endpoints
+			r.Get("/graph/summary", app.graphSummaryHandler)
+			r.Get("/graph/node", app.graphNodeDetailsHandler)
+			r.Get("/graph/files", app.graphFileHierarchyHandler)
+			r.Get("/graph/top-nodes", app.graphTopNodesHandler)
+		})
+		r.Route("/projects/{id}", func(r chi.Router) {
+			r.Use(app.requireProject)
+			r.Delete("/", app.deleteProjectHandler)
+			r.Get("/status", app.projectStatusHandler)
+			r.Get("/events", app.projectEventsHandler)
+			r.Get("/source", app.projectSourceHandler)
+			r.Get("/tree", app.treeHandler)
+			r.Post("/graph/search", app.graphSearchHandler)
+			r.Get("/graph/paths", app.graphPathsHandler)
+			r.Get("/impact", app.impactHandler)
+			r.Post("/impact", app.impactHandler)
+			r.Get("/reports/dead-code", app.deadCodeReportHandler)
+			r.Get("/reports/cycles", app.cycleReportHandler)
+			r.Get("/reports/cycles/check", app.cycleCheckHandler)
+			r.Get("/metrics", app.metricsHandler)
+			r.Post("/metrics", app.recomputeMetricsHandler)
+			r.Get("/clusters", app.clusterGraphHandler)
+			r.Post("/clusters", app.recomputeClustersHandler)
+			r.Get("/clusters/{clusterId}", app.clusterMembersHandler)
+			r.Get("/clusters/{clusterId}/edges/{targetId}", app.clusterEdgeHandler)
+			r.Post("/cancel", app.cancelJobHandler)
+			r.Post("/retry", app.retryJobHandler)
+		})
 	})
 
 	return http.MaxBytesHandler(r, 300*1024*1024) 
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/1107-adishjain/codemap/internal/cypher"
+)
+
+// graphSearchHandler runs a structured search (see cypher.Search) against the project's
+// graph and returns the matched nodes, and the paths followed from them, as nodes/edges.
+func (app *application) graphSearchHandler(w http.ResponseWriter, r *http.Request) {
+	project := projectFromContext(r)
+
+	var search cypher.Search
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&search); err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "Invalid search: "+err.Error())
+		return
+	}
+	query, params, err := search.Compile()
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	results, err := app.db.ProjectQuery(ctx, project.ID, query, params)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			app.errorResponse(w, r, http.StatusGatewayTimeout, "Search took too long; narrow the filters or lower the depth")
+			return
+		}
+		app.errorResponse(w, r, http.StatusInternalServerError, "Failed to execute search: "+err.Error())
+		return
+	}
+
+	graph, ok := graphElements(results)
+	if !ok {
+		graph = map[string]any{"nodes": []any{}, "edges": []any{}}
+	}
+	graph["truncated"] = len(results) >= cypher.MaxSearchPaths
+	app.writeJSON(w, http.StatusOK, graph)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/1107-adishjain/codemap/internal/source"
+)
+
+// projectSourceHandler returns a file from the project's stored archive, or a range of
+// its lines when start and/or end are given. path is a File path as found in the graph,
+// e.g. the part of a node ID before the '#'.
+func (app *application) projectSourceHandler(w http.ResponseWriter, r *http.Request) {
+	project := projectFromContext(r)
+	query := r.URL.Query()
+	path := query.Get("path")
+	if path == "" {
+		app.errorResponse(w, r, http.StatusBadRequest, "path parameter is required")
+		return
+	}
+	start, err := lineParam(query.Get("start"))
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "start must be a positive line number")
+		return
+	}
+	end, err := lineParam(query.Get("end"))
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "end must be a positive line number")
+		return
+	}
+	if end != 0 && end < start {
+		app.errorResponse(w, r, http.StatusBadRequest, "end must not be before start")
+		return
+	}
+	if project.S3Key == "" {
+		app.errorResponse(w, r, http.StatusNotFound, "Source is not available for this project yet")
+		return
+	}
+
+	tree, err := app.source.Tree(project.ID, project.S3Key)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusBadGateway, "Failed to fetch project source")
+		return
+	}
+	file, err := source.Resolve(tree, path)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusNotFound, "File not found in project source")
+		return
+	}
+	snippet, err := source.Read(file, start, end)
+	switch {
+	case errors.Is(err, source.ErrBinary):
+		app.errorResponse(w, r, http.StatusUnsupportedMediaType, err.Error())
+		return
+	case err != nil:
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, "Failed to read file: "+err.Error())
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{
+		"project_id":  project.ID,
+		"path":        path,
+		"start_line":  snippet.StartLine,
+		"end_line":    snippet.EndLine,
+		"total_lines": snippet.TotalLines,
+		"content":     snippet.Content,
+	})
+}
+
+// lineParam parses an optional 1-based line number; empty means unset (0).
+func lineParam(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, errors.New("invalid line number")
+	}
+	return n, nil
+}
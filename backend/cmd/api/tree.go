@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// treeTimeout bounds the tree queries.
+const treeTimeout = 15 * time.Second
+
+// treeHandler lists the immediate children of a directory of a project: its directories
+// first, then its files, each with the number of files, functions and classes at or
+// below it, so clients can expand the tree one level at a time. dir is a repo-relative
+// directory path; empty (the default) lists the root of the project. Graphs imported
+// before directories were recorded have an empty tree until they are analysed again.
+func (app *application) treeHandler(w http.ResponseWriter, r *http.Request) {
+	project := projectFromContext(r)
+	dir := strings.Trim(r.URL.Query().Get("dir"), "/")
+	if dir == "." {
+		dir = ""
+	}
+	params := map[string]any{"dir": dir}
+
+	// Directories and files alike are counted by the IDs below them: a directory's
+	// paths start with its path and a slash, a file's symbol IDs with its path and '#'.
+	parent := "MATCH (parent:Project {id: $projectId})"
+	if dir != "" {
+		parent = "MATCH (parent:Directory {project_id: $projectId, path: $dir})"
+	}
+	query := parent + `
+		MATCH (parent)-[:CONTAINS]->(child:Directory|File {project_id: $projectId})
+		WITH child, child:Directory AS directory,
+			child.path + CASE WHEN child:Directory THEN '/' ELSE '#' END AS prefix
+		OPTIONAL MATCH (pkg:Package)-[:CONTAINS]->(child)
+		RETURN
+			elementId(child) AS id,
+			CASE WHEN directory THEN 'directory' ELSE 'file' END AS type,
+			coalesce(child.name, last(split(child.path, '/'))) AS name,
+			child.path AS path,
+			child.language AS language,
+			pkg.name AS package,
+			CASE WHEN directory
+				THEN COUNT { MATCH (f:File {project_id: $projectId}) WHERE f.path STARTS WITH prefix }
+				ELSE 1 END AS files,
+			COUNT { MATCH (fn:Function {project_id: $projectId}) WHERE fn.id STARTS WITH prefix } AS functions,
+			COUNT { MATCH (c:Class {project_id: $projectId}) WHERE c.id STARTS WITH prefix } AS classes
+		ORDER BY directory DESC, name, path
+	`
+
+	p, err := app.parsePage(r, app.config.QueryPageSize)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), treeTimeout)
+	defer cancel()
+
+	records, err := app.db.ProjectQuery(ctx, project.ID, parent+" RETURN count(parent) AS n", params)
+	if err != nil {
+		app.writeTreeError(w, r, err)
+		return
+	}
+	if n, _ := records[0]["n"].(int64); n == 0 {
+		app.errorResponse(w, r, http.StatusNotFound, "Directory not found")
+		return
+	}
+	app.writeQueryResults(ctx, w, r, p, project.ID, query, params, func(records []map[string]any, next *int) any {
+		return map[string]any{
+			"project_id":  project.ID,
+			"dir":         dir,
+			"children":    records,
+			"next_offset": next,
+		}
+	})
+}
+
+// writeTreeError answers a failed tree query, 504 when it ran out of time.
+func (app *application) writeTreeError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		app.errorResponse(w, r, http.StatusGatewayTimeout, "Tree query took too long")
+		return
+	}
+	app.errorResponse(w, r, http.StatusInternalServerError, "Failed to query tree: "+err.Error())
+}
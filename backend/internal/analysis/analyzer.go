@@ -0,0 +1,80 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/1107-adishjain/codemap/internal/models"
+)
+
+// Analyzer extracts the files of one or more languages from a source tree in-process.
+type Analyzer interface {
+	// Name identifies the analyzer in logs and diagnostics.
+	Name() string
+	// Extensions lists the file extensions the analyzer covers, e.g. ".go".
+	Extensions() []string
+	// Analyze returns every file under dir with one of the analyzer's extensions.
+	// File paths are dir joined with the repo-relative path, as the Node.js tool reports them.
+	Analyze(ctx context.Context, dir string) ([]models.File, error)
+}
+
+// Registry runs the native analyzers and falls back to the Node.js tool for
+// every language they do not cover, merging both into one stream of files.
+type Registry struct {
+	analyzers []Analyzer
+}
+
+func NewRegistry(analyzers ...Analyzer) *Registry {
+	return &Registry{analyzers: analyzers}
+}
+
+// DefaultRegistry returns a registry with all built-in native analyzers.
+func DefaultRegistry() *Registry {
+	return NewRegistry(GoAnalyzer{})
+}
+
+// Stream hands every file of opts.TargetDir to onFile: first the output of the native
+// analyzers, then whatever the Node.js tool extracts from the remaining extensions.
+// A native analyzer that fails is reported as a diagnostic and its languages are left
+// to the Node.js tool. opts.Timeout bounds the whole run. As with Stream, file paths
+// are made relative to opts.TargetDir.
+func (r *Registry) Stream(ctx context.Context, opts Options, onFile func(models.File) error) (*StreamResult, error) {
+	ctx, cancel := opts.withTimeout(ctx)
+	defer cancel()
+
+	var diagnostics []models.Diagnostic
+	native := 0
+	skip := append([]string(nil), opts.SkipExtensions...)
+	for _, analyzer := range r.analyzers {
+		files, err := analyzer.Analyze(ctx, opts.TargetDir)
+		if ctx.Err() != nil {
+			return nil, opts.stopError(ctx, diagnostics)
+		}
+		if err != nil {
+			fmt.Printf("⚠️ ANALYSIS: %s analyzer failed, falling back to the Node.js tool: %v\n", analyzer.Name(), err)
+			diagnostics = append(diagnostics, models.Diagnostic{
+				Level:   levelWarning,
+				Message: fmt.Sprintf("%s analyzer failed, falling back to the Node.js tool: %v", analyzer.Name(), err),
+			})
+			continue
+		}
+		fmt.Printf("🔧 ANALYSIS: %s analyzer found %d files\n", analyzer.Name(), len(files))
+		for _, file := range files {
+			relativeTo(opts.TargetDir, &file)
+			if err := onFile(file); err != nil {
+				return nil, err
+			}
+			native++
+		}
+		skip = append(skip, analyzer.Extensions()...)
+	}
+
+	opts.SkipExtensions = skip
+	result, err := Stream(ctx, opts, onFile)
+	if err != nil {
+		return nil, err
+	}
+	result.Files += native
+	result.Diagnostics = append(diagnostics, result.Diagnostics...)
+	return result, nil
+}
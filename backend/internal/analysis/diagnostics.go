@@ -0,0 +1,109 @@
+package analysis
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/1107-adishjain/codemap/internal/models"
+)
+
+const (
+	levelError   = "error"
+	levelWarning = "warning"
+	levelInfo    = "info"
+
+	// maxDiagnostics caps how many stderr messages are kept per run.
+	maxDiagnostics = 1000
+	// maxLineLength caps a single stderr line; longer lines are truncated.
+	maxLineLength = 64 * 1024
+)
+
+// diagnosticWriter splits the analyser's stderr into lines and turns each one into a
+// models.Diagnostic. Lines may be JSON objects ({"level", "message", "file"}) or plain
+// text, in which case the level is guessed from the content.
+type diagnosticWriter struct {
+	partial     []byte
+	diagnostics []models.Diagnostic
+	dropped     int
+}
+
+func (w *diagnosticWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.buffer(p)
+			break
+		}
+		w.buffer(p[:i])
+		w.flushLine()
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+func (w *diagnosticWriter) buffer(p []byte) {
+	if room := maxLineLength - len(w.partial); room > 0 {
+		if len(p) > room {
+			p = p[:room]
+		}
+		w.partial = append(w.partial, p...)
+	}
+}
+
+func (w *diagnosticWriter) flushLine() {
+	line := strings.TrimRight(string(w.partial), "\r")
+	w.partial = w.partial[:0]
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+
+	// Stack frames and similar indented output belong to the previous message.
+	if n := len(w.diagnostics); n > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "STACK TRACE:")) {
+		w.diagnostics[n-1].Message += "\n" + line
+		return
+	}
+
+	if len(w.diagnostics) >= maxDiagnostics {
+		w.dropped++
+		return
+	}
+	w.diagnostics = append(w.diagnostics, parseDiagnostic(line))
+}
+
+// Diagnostics returns the collected diagnostics, flushing any unterminated last line.
+func (w *diagnosticWriter) Diagnostics() []models.Diagnostic {
+	if len(w.partial) > 0 {
+		w.flushLine()
+	}
+	if w.dropped > 0 {
+		return append(w.diagnostics, models.Diagnostic{
+			Level:   levelWarning,
+			Message: "further analyser output was dropped",
+		})
+	}
+	return w.diagnostics
+}
+
+func parseDiagnostic(line string) models.Diagnostic {
+	if strings.HasPrefix(line, "{") {
+		var d models.Diagnostic
+		if err := json.Unmarshal([]byte(line), &d); err == nil && d.Message != "" {
+			if d.Level == "" {
+				d.Level = levelWarning
+			}
+			return d
+		}
+	}
+
+	lower := strings.ToLower(line)
+	switch {
+	case strings.Contains(lower, "error"):
+		return models.Diagnostic{Level: levelError, Message: line}
+	case strings.Contains(lower, "warn"):
+		return models.Diagnostic{Level: levelWarning, Message: line}
+	default:
+		return models.Diagnostic{Level: levelInfo, Message: line}
+	}
+}
@@ -0,0 +1,654 @@
+package analysis
+
+import (
+	"bufio"
+	"context"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/1107-adishjain/codemap/internal/models"
+)
+
+// ignoredDirs mirrors the analyser's ignoreDirs, plus the Go-specific vendor and testdata.
+var ignoredDirs = map[string]bool{
+	".git": true, "node_modules": true, "temp-uploads": true, "temp-clones": true,
+	".next": true, "dist": true, "build": true, ".nuxt": true, "coverage": true,
+	".nyc_output": true, "target": true, "bin": true, "obj": true, ".vscode": true,
+	".idea": true, "__pycache__": true, ".pytest_cache": true,
+	"vendor": true, "testdata": true,
+}
+
+// buildContext decides which files make up a package. The tree is analysed as it builds
+// for linux/amd64; files excluded there by their name or build constraints, e.g. the
+// windows variant of a function or a //go:build ignore generator, are analysed as units
+// of their own, so their declarations neither clash with the package's nor capture its
+// calls.
+var buildContext = func() build.Context {
+	ctxt := build.Default
+	ctxt.GOOS, ctxt.GOARCH = "linux", "amd64"
+	ctxt.CgoEnabled = true
+	ctxt.BuildTags = nil
+	return ctxt
+}()
+
+// GoAnalyzer extracts Go source in-process with go/parser and go/types. Unlike the
+// tree-sitter extractor it knows method receivers and interface methods, and resolves
+// calls to the exact function they target, across packages of the analysed tree.
+//
+// Packages inside the tree are type-checked from source, keyed by the module paths of
+// the go.mod files found in it. Everything else (the standard library and third-party
+// modules) is replaced by an empty package: calls into it stay unresolved and are
+// recorded by name, while type errors caused by the missing declarations are ignored.
+type GoAnalyzer struct{}
+
+func (GoAnalyzer) Name() string { return "go" }
+
+func (GoAnalyzer) Extensions() []string { return []string{".go"} }
+
+func (GoAnalyzer) Analyze(ctx context.Context, dir string) ([]models.File, error) {
+	a := &goAnalysis{
+		fset:     token.NewFileSet(),
+		root:     dir,
+		modules:  make(map[string]string),
+		byPath:   make(map[string]*goPackage),
+		external: make(map[string]*types.Package),
+		files:    make(map[string]bool),
+	}
+	if err := a.load(); err != nil {
+		return nil, err
+	}
+
+	var files []models.File
+	for _, pkg := range a.packages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		a.check(pkg)
+		for i, file := range pkg.files {
+			if i < pkg.emitFrom {
+				continue
+			}
+			files = append(files, a.extractFile(pkg, file))
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// goPackage is one type-checking unit: a package, its in-package tests, or its external tests.
+type goPackage struct {
+	importPath string
+	files      []*ast.File
+	paths      []string
+	errors     []string
+	// emitFrom is the index of the first file this unit reports. The in-package test
+	// unit re-checks the package's own files but only reports its _test.go files.
+	emitFrom int
+
+	types    *types.Package
+	info     *types.Info
+	checking bool
+}
+
+type goAnalysis struct {
+	fset *token.FileSet
+	root string
+	// modules maps directories containing a go.mod to their module path.
+	modules  map[string]string
+	packages []*goPackage
+	// byPath holds the importable (non-test) packages by import path.
+	byPath   map[string]*goPackage
+	external map[string]*types.Package
+	files    map[string]bool
+}
+
+type parsedGoFile struct {
+	path   string
+	ast    *ast.File
+	errMsg string
+	// excluded is set for files buildContext leaves out of their package.
+	excluded bool
+}
+
+// load walks the tree, parses every .go file and groups the files into packages.
+func (a *goAnalysis) load() error {
+	byDir := make(map[string][]parsedGoFile)
+	err := filepath.WalkDir(a.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Silently skip inaccessible entries, as the Node.js tool does
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if p != a.root && ignoredDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch {
+		case d.Name() == "go.mod":
+			if modPath := ReadModulePath(p); modPath != "" {
+				a.modules[filepath.Dir(p)] = modPath
+			}
+		case strings.HasSuffix(d.Name(), ".go"):
+			file, perr := parser.ParseFile(a.fset, p, nil, parser.SkipObjectResolution|parser.AllErrors)
+			if file == nil {
+				return nil
+			}
+			parsed := parsedGoFile{path: p, ast: file}
+			if perr != nil {
+				parsed.errMsg = perr.Error()
+			}
+			if match, err := buildContext.MatchFile(filepath.Dir(p), d.Name()); err == nil && !match {
+				parsed.excluded = true
+			}
+			byDir[filepath.Dir(p)] = append(byDir[filepath.Dir(p)], parsed)
+			a.files[p] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	dirs := make([]string, 0, len(byDir))
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		a.addDir(dir, byDir[dir])
+	}
+	return nil
+}
+
+// addDir splits a directory's files into the package proper, its in-package tests and
+// its external (_test suffixed) tests. Stray files declaring another package name
+// become units of their own, as does every file buildContext excludes.
+func (a *goAnalysis) addDir(dir string, files []parsedGoFile) {
+	counts := make(map[string]int)
+	for _, f := range files {
+		if !f.excluded && !strings.HasSuffix(f.path, "_test.go") {
+			counts[f.ast.Name.Name]++
+		}
+	}
+	primary := ""
+	for name, n := range counts {
+		if n > counts[primary] || (n == counts[primary] && name < primary) {
+			primary = name
+		}
+	}
+	if primary == "" && len(files) > 0 {
+		primary = strings.TrimSuffix(files[0].ast.Name.Name, "_test")
+	}
+
+	importPath := a.importPath(dir)
+	pkg := &goPackage{importPath: importPath}
+	inTests := &goPackage{importPath: importPath}
+	xTests := &goPackage{importPath: importPath + "_test"}
+	others := make(map[string]*goPackage)
+	var excluded []*goPackage
+	for _, f := range files {
+		name := f.ast.Name.Name
+		isTest := strings.HasSuffix(f.path, "_test.go")
+		switch {
+		case f.excluded:
+			unit := &goPackage{importPath: importPath + "#" + filepath.Base(f.path)}
+			unit.add(f)
+			excluded = append(excluded, unit)
+		case name == primary && !isTest:
+			pkg.add(f)
+		case name == primary && isTest:
+			inTests.add(f)
+		case name == primary+"_test" && isTest:
+			xTests.add(f)
+		default:
+			other, ok := others[name]
+			if !ok {
+				other = &goPackage{importPath: importPath + "#" + name}
+				others[name] = other
+			}
+			other.add(f)
+		}
+	}
+
+	if len(pkg.files) > 0 {
+		a.packages = append(a.packages, pkg)
+		a.byPath[importPath] = pkg
+	}
+	if len(inTests.files) > 0 {
+		inTests.emitFrom = len(pkg.files)
+		inTests.files = append(append([]*ast.File(nil), pkg.files...), inTests.files...)
+		inTests.paths = append(append([]string(nil), pkg.paths...), inTests.paths...)
+		inTests.errors = append(make([]string, len(pkg.files)), inTests.errors...)
+		a.packages = append(a.packages, inTests)
+	}
+	if len(xTests.files) > 0 {
+		a.packages = append(a.packages, xTests)
+	}
+	names := make([]string, 0, len(others))
+	for name := range others {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		a.packages = append(a.packages, others[name])
+	}
+	a.packages = append(a.packages, excluded...)
+}
+
+func (p *goPackage) add(f parsedGoFile) {
+	p.files = append(p.files, f.ast)
+	p.paths = append(p.paths, f.path)
+	p.errors = append(p.errors, f.errMsg)
+}
+
+// importPath derives a directory's import path from the nearest enclosing go.mod.
+// Directories outside any module use their path relative to the tree root.
+func (a *goAnalysis) importPath(dir string) string {
+	if modDir, modPath := a.module(dir); modDir != "" {
+		rel, err := filepath.Rel(modDir, dir)
+		if err != nil || rel == "." {
+			return modPath
+		}
+		return modPath + "/" + filepath.ToSlash(rel)
+	}
+	rel, err := filepath.Rel(a.root, dir)
+	if err != nil {
+		return filepath.ToSlash(dir)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// module returns the directory and path of the module enclosing dir, or two empty
+// strings when dir is outside any module.
+func (a *goAnalysis) module(dir string) (string, string) {
+	for d := dir; ; d = filepath.Dir(d) {
+		if modPath, ok := a.modules[d]; ok {
+			return d, modPath
+		}
+		if d == a.root || filepath.Dir(d) == d {
+			return "", ""
+		}
+	}
+}
+
+// check type-checks a unit once, importing tree packages on demand.
+func (a *goAnalysis) check(pkg *goPackage) *types.Package {
+	if pkg.types != nil {
+		return pkg.types
+	}
+	pkg.checking = true
+	pkg.info = &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	conf := types.Config{
+		Importer:    a,
+		FakeImportC: true,
+		// Missing third-party declarations make errors unavoidable; keep going.
+		Error: func(error) {},
+	}
+	pkg.types, _ = conf.Check(pkg.importPath, a.fset, pkg.files, pkg.info)
+	pkg.checking = false
+	return pkg.types
+}
+
+// Import implements types.Importer.
+func (a *goAnalysis) Import(importPath string) (*types.Package, error) {
+	if pkg, ok := a.byPath[importPath]; ok {
+		if pkg.checking {
+			// Import cycle: invalid Go, but don't recurse forever.
+			return a.stub(importPath), nil
+		}
+		return a.check(pkg), nil
+	}
+	return a.stub(importPath), nil
+}
+
+// stub returns an empty, complete package standing in for code outside the tree.
+func (a *goAnalysis) stub(importPath string) *types.Package {
+	if pkg, ok := a.external[importPath]; ok {
+		return pkg
+	}
+	pkg := types.NewPackage(importPath, guessPackageName(importPath))
+	pkg.MarkComplete()
+	a.external[importPath] = pkg
+	return pkg
+}
+
+// guessPackageName applies the usual conventions: the last path element, skipping
+// major version suffixes and common "go-" prefixes.
+func guessPackageName(importPath string) string {
+	elems := strings.Split(importPath, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = elems[len(elems)-2]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	name = strings.TrimSuffix(name, ".go")
+	return strings.NewReplacer("-", "", ".", "").Replace(name)
+}
+
+func (a *goAnalysis) extractFile(pkg *goPackage, file *ast.File) models.File {
+	index := 0
+	for i, f := range pkg.files {
+		if f == file {
+			index = i
+		}
+	}
+	path := pkg.paths[index]
+	_, module := a.module(filepath.Dir(path))
+	out := models.File{
+		Path:     path,
+		Language: "go",
+		// Units for stray package clauses carry a #name suffix that is no import path.
+		Package: strings.Split(pkg.importPath, "#")[0],
+		Module:  module,
+		Error:   pkg.errors[index],
+	}
+	qualifier := types.RelativeTo(pkg.types)
+
+	for _, imp := range file.Imports {
+		out.Imports = append(out.Imports, models.Import{
+			Source:   strings.Trim(imp.Path.Value, "\"`"),
+			Location: a.location(imp),
+		})
+	}
+
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			fn := models.Function{
+				Name:        decl.Name.Name,
+				IsExported:  decl.Name.IsExported(),
+				ReturnTypes: a.resultTypes(pkg, decl.Type.Results, qualifier),
+				Location:    a.location(decl),
+			}
+			fn.Params, fn.ParamLocations = a.params(decl.Type.Params)
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				fn.IsMethodOf = receiverTypeName(decl.Recv.List[0].Type)
+			}
+			if decl.Body != nil {
+				a.collectCalls(pkg, decl.Body, &fn)
+			}
+			out.Functions = append(out.Functions, fn)
+
+		case *ast.GenDecl:
+			if decl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				ts := spec.(*ast.TypeSpec)
+				// A lone "type X struct" spans its whole declaration, keyword included.
+				var span ast.Node = ts
+				if !decl.Lparen.IsValid() {
+					span = decl
+				}
+				switch t := ts.Type.(type) {
+				case *ast.StructType:
+					out.Classes = append(out.Classes, models.Class{
+						Name:       ts.Name.Name,
+						IsExported: ts.Name.IsExported(),
+						Properties: fieldNames(t.Fields),
+						Location:   a.location(span),
+					})
+				case *ast.InterfaceType:
+					class := models.Class{Name: ts.Name.Name, IsExported: ts.Name.IsExported(), Location: a.location(span)}
+					for _, m := range t.Methods.List {
+						ft, ok := m.Type.(*ast.FuncType)
+						if !ok {
+							// Embedded interface or type constraint
+							class.Properties = append(class.Properties, types.ExprString(m.Type))
+							continue
+						}
+						for _, name := range m.Names {
+							class.Methods = append(class.Methods, name.Name)
+							method := models.Function{
+								Name:        name.Name,
+								IsExported:  name.IsExported(),
+								ReturnTypes: a.resultTypes(pkg, ft.Results, qualifier),
+								IsMethodOf:  ts.Name.Name,
+								Location:    a.location(m),
+							}
+							method.Params, method.ParamLocations = a.params(ft.Params)
+							out.Functions = append(out.Functions, method)
+						}
+					}
+					out.Classes = append(out.Classes, class)
+				}
+			}
+		}
+	}
+
+	// HAS_METHOD edges are keyed by file, so only methods declared next to their type are listed.
+	for i := range out.Classes {
+		for _, fn := range out.Functions {
+			if fn.IsMethodOf == out.Classes[i].Name && !containsString(out.Classes[i].Methods, fn.Name) {
+				out.Classes[i].Methods = append(out.Classes[i].Methods, fn.Name)
+			}
+		}
+	}
+	return out
+}
+
+// collectCalls records every call in body, including those inside closures. Calls bound
+// to a function declared in the tree become ResolvedCalls; the rest are kept by name.
+// Every occurrence is kept as a call site.
+func (a *goAnalysis) collectCalls(pkg *goPackage, body *ast.BlockStmt, fn *models.Function) {
+	seenCalls := make(map[string]bool)
+	// resolved indexes fn.ResolvedCalls by target
+	type target struct{ name, class, file string }
+	resolved := make(map[target]int)
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		fun := ast.Unparen(call.Fun)
+		if _, ok := fun.(*ast.FuncLit); ok {
+			// Immediately invoked closure; its body is inspected in place.
+			return true
+		}
+		if tv, ok := pkg.info.Types[fun]; ok && (tv.IsType() || tv.IsBuiltin()) {
+			return true
+		}
+		if callee := a.callee(pkg, fun); callee != nil {
+			pos := a.fset.Position(callee.Pos())
+			if a.files[pos.Filename] {
+				key := target{callee.Name(), methodOf(callee), pos.Filename}
+				i, ok := resolved[key]
+				if !ok {
+					i = len(fn.ResolvedCalls)
+					resolved[key] = i
+					fn.ResolvedCalls = append(fn.ResolvedCalls, models.ResolvedCall{Name: key.name, Class: key.class, File: key.file})
+				}
+				fn.ResolvedCalls[i].Sites = append(fn.ResolvedCalls[i].Sites, *a.location(call))
+				return true
+			}
+		}
+		name := types.ExprString(fun)
+		if !seenCalls[name] {
+			seenCalls[name] = true
+			fn.Calls = append(fn.Calls, name)
+		}
+		fn.CallSites = append(fn.CallSites, models.CallSite{Name: name, Location: *a.location(call)})
+		return true
+	})
+}
+
+// callee returns the function or method a call expression denotes, if known.
+func (a *goAnalysis) callee(pkg *goPackage, fun ast.Expr) *types.Func {
+	switch f := fun.(type) {
+	case *ast.IndexExpr:
+		return a.callee(pkg, ast.Unparen(f.X))
+	case *ast.IndexListExpr:
+		return a.callee(pkg, ast.Unparen(f.X))
+	case *ast.Ident:
+		fn, _ := pkg.info.Uses[f].(*types.Func)
+		return originOf(fn)
+	case *ast.SelectorExpr:
+		if sel, ok := pkg.info.Selections[f]; ok {
+			fn, _ := sel.Obj().(*types.Func)
+			return originOf(fn)
+		}
+		fn, _ := pkg.info.Uses[f.Sel].(*types.Func)
+		return originOf(fn)
+	}
+	return nil
+}
+
+func originOf(fn *types.Func) *types.Func {
+	if fn == nil {
+		return nil
+	}
+	return fn.Origin()
+}
+
+func (a *goAnalysis) resultTypes(pkg *goPackage, results *ast.FieldList, qualifier types.Qualifier) []string {
+	if results == nil || len(results.List) == 0 {
+		return []string{"void"}
+	}
+	var out []string
+	for _, field := range results.List {
+		typ := types.ExprString(field.Type)
+		if t := pkg.info.TypeOf(field.Type); t != nil && t != types.Typ[types.Invalid] {
+			typ = types.TypeString(t, qualifier)
+		}
+		for n := max(len(field.Names), 1); n > 0; n-- {
+			out = append(out, typ)
+		}
+	}
+	return out
+}
+
+// params returns the named parameters of a signature with their locations.
+func (a *goAnalysis) params(params *ast.FieldList) ([]string, []models.Location) {
+	if params == nil {
+		return nil, nil
+	}
+	var names []string
+	var locations []models.Location
+	for _, field := range params.List {
+		for _, name := range field.Names {
+			if name.Name != "_" {
+				names = append(names, name.Name)
+				locations = append(locations, *a.location(name))
+			}
+		}
+	}
+	return names, locations
+}
+
+// location converts a node's span into a models.Location.
+func (a *goAnalysis) location(n ast.Node) *models.Location {
+	start, end := a.fset.Position(n.Pos()), a.fset.Position(n.End())
+	return &models.Location{
+		StartLine:   start.Line,
+		StartColumn: start.Column,
+		EndLine:     end.Line,
+		EndColumn:   end.Column,
+	}
+}
+
+func fieldNames(fields *ast.FieldList) []string {
+	if fields == nil {
+		return nil
+	}
+	var names []string
+	for _, field := range fields.List {
+		if len(field.Names) == 0 {
+			// Embedded field: named after its type
+			names = append(names, receiverTypeName(field.Type))
+			continue
+		}
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+	}
+	return names
+}
+
+// methodOf returns the name of the type fn is a method of, as receiverTypeName gives it
+// for declarations, or "" for a function.
+func methodOf(fn *types.Func) string {
+	sig, ok := fn.Type().(*types.Signature)
+	if !ok || sig.Recv() == nil {
+		return ""
+	}
+	t := sig.Recv().Type()
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	if named, ok := t.(*types.Named); ok {
+		return named.Obj().Name()
+	}
+	return types.TypeString(t, func(*types.Package) string { return "" })
+}
+
+// receiverTypeName strips pointers, type parameters and package qualifiers from a type expression.
+func receiverTypeName(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.SelectorExpr:
+			return e.Sel.Name
+		case *ast.Ident:
+			return e.Name
+		default:
+			return types.ExprString(expr)
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadModulePath returns the module path declared in a go.mod file, or "" if there is none.
+func ReadModulePath(goMod string) string {
+	f, err := os.Open(goMod)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if rest, ok := strings.CutPrefix(line, "module"); ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t') {
+			if i := strings.Index(rest, "//"); i >= 0 {
+				rest = rest[:i]
+			}
+			if modPath := strings.Trim(strings.TrimSpace(rest), "\"`"); modPath != "" {
+				return path.Clean(modPath)
+			}
+			return ""
+		}
+	}
+	return ""
+}
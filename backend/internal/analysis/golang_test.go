@@ -0,0 +1,173 @@
+package analysis
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/1107-adishjain/codemap/internal/models"
+)
+
+// goTree is a module exercising receivers, generics and build constraints.
+var goTree = map[string]string{
+	"go.mod": "module example.com/app\n",
+	"server.go": `package app
+
+type Server struct{ addr string }
+
+func (s *Server) Start() error { s.log(); return nil }
+
+func (s Server) Addr() string { return s.addr }
+
+func (s *Server) log() {}
+`,
+	"stack.go": `package app
+
+type Stack[T any] struct{ items []T }
+
+func (s *Stack[T]) Push(v T) { s.items = append(s.items, v) }
+
+func (s Stack[T]) Len() int { return len(s.items) }
+
+func Map[T, U any](xs []T, f func(T) U) []U { return nil }
+`,
+	"use.go": `package app
+
+func use() {
+	var st Stack[int]
+	st.Push(1)
+	_ = Map[int, string]([]int{1}, func(int) string { return "" })
+	var s Server
+	s.Start()
+	platform()
+}
+`,
+	"os_linux.go": "//go:build linux\n\npackage app\n\nfunc platform() string { return \"linux\" }\n",
+	"os_windows.go": `package app
+
+func platform() string { use(); return windowsName() }
+
+func windowsName() string { return "windows" }
+`,
+	"gen.go": "//go:build ignore\n\npackage main\n\nfunc main() { use() }\n",
+}
+
+func TestGoAnalyzer(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range goTree {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := GoAnalyzer{}.Analyze(context.Background(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPath := make(map[string]models.File)
+	for _, f := range files {
+		rel, _ := filepath.Rel(root, f.Path)
+		if f.Package != "example.com/app" {
+			t.Errorf("%s: package %q, want example.com/app", rel, f.Package)
+		}
+		byPath[rel] = f
+	}
+
+	tests := []struct {
+		name  string
+		file  string
+		fn    string
+		class string
+		// resolved lists the calls bound to a function as "file class.name", and calls
+		// the calls kept by name.
+		resolved []string
+		calls    []string
+	}{
+		{name: "pointer receiver", file: "server.go", fn: "Start", class: "Server", resolved: []string{"server.go Server.log"}},
+		{name: "value receiver", file: "server.go", fn: "Addr", class: "Server"},
+		{name: "pointer receiver of a generic type", file: "stack.go", fn: "Push", class: "Stack"},
+		{name: "value receiver of a generic type", file: "stack.go", fn: "Len", class: "Stack"},
+		{name: "generic function", file: "stack.go", fn: "Map"},
+		{
+			name: "calls through instantiations and to the built variant", file: "use.go", fn: "use",
+			resolved: []string{"stack.go Stack.Push", "stack.go .Map", "server.go Server.Start", "os_linux.go .platform"},
+		},
+		{
+			name: "file for another platform", file: "os_windows.go", fn: "platform",
+			resolved: []string{"os_windows.go .windowsName"}, calls: []string{"use"},
+		},
+		{name: "build-ignored file", file: "gen.go", fn: "main", calls: []string{"use"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fn *models.Function
+			for i, f := range byPath[tt.file].Functions {
+				if f.Name == tt.fn {
+					fn = &byPath[tt.file].Functions[i]
+				}
+			}
+			if fn == nil {
+				t.Fatalf("%s: no function %s", tt.file, tt.fn)
+			}
+			if fn.IsMethodOf != tt.class {
+				t.Errorf("IsMethodOf = %q, want %q", fn.IsMethodOf, tt.class)
+			}
+			var resolved []string
+			for _, c := range fn.ResolvedCalls {
+				rel, _ := filepath.Rel(root, c.File)
+				resolved = append(resolved, rel+" "+c.Class+"."+c.Name)
+			}
+			if !reflect.DeepEqual(resolved, tt.resolved) {
+				t.Errorf("ResolvedCalls = %v, want %v", resolved, tt.resolved)
+			}
+			if !reflect.DeepEqual(fn.Calls, tt.calls) {
+				t.Errorf("Calls = %v, want %v", fn.Calls, tt.calls)
+			}
+		})
+	}
+
+	// Methods are listed on the type they are declared with, whatever their receiver.
+	for file, want := range map[string]string{"server.go": "Server: Start Addr log", "stack.go": "Stack: Push Len"} {
+		var got []string
+		for _, c := range byPath[file].Classes {
+			got = append(got, c.Name+": "+strings.Join(c.Methods, " "))
+		}
+		if !reflect.DeepEqual(got, []string{want}) {
+			t.Errorf("%s: classes %v, want [%s]", file, got, want)
+		}
+	}
+}
+
+func TestReadModulePath(t *testing.T) {
+	tests := []struct {
+		name  string
+		goMod string
+		want  string
+	}{
+		{"plain", "module example.com/app\n\ngo 1.22\n", "example.com/app"},
+		{"after comments", "// The app.\n\nmodule example.com/app\n", "example.com/app"},
+		{"quoted", "module \"example.com/app\"\n", "example.com/app"},
+		{"trailing comment", "module example.com/app // deprecated\n", "example.com/app"},
+		{"tab separated", "module\texample.com/app\n", "example.com/app"},
+		{"bare module line", "module\n", ""},
+		{"empty quotes", "module \"\"\n", ""},
+		{"other directive", "modules example.com/app\n", ""},
+		{"no module line", "go 1.22\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			goMod := filepath.Join(t.TempDir(), "go.mod")
+			if err := os.WriteFile(goMod, []byte(tt.goMod), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if got := ReadModulePath(goMod); got != tt.want {
+				t.Errorf("ReadModulePath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if got := ReadModulePath(filepath.Join(t.TempDir(), "go.mod")); got != "" {
+		t.Errorf("ReadModulePath() of a missing file = %q, want \"\"", got)
+	}
+}
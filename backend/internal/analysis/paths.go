@@ -0,0 +1,40 @@
+package analysis
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/1107-adishjain/codemap/internal/models"
+)
+
+// relativeTo rewrites the paths of a file, and of the calls it resolved, relative to
+// root with forward slashes. The analysers report paths under the directory the archive
+// was extracted to, which is a throwaway temp directory; the graph only ever stores
+// repo-relative paths.
+func relativeTo(root string, file *models.File) {
+	file.Path = relativePath(root, file.Path)
+	for i := range file.Functions {
+		calls := file.Functions[i].ResolvedCalls
+		for j := range calls {
+			calls[j].File = relativePath(root, calls[j].File)
+		}
+	}
+}
+
+// diagnosticsRelativeTo does the same for the files named by diagnostics.
+func diagnosticsRelativeTo(root string, diagnostics []models.Diagnostic) {
+	for i := range diagnostics {
+		if diagnostics[i].File != "" {
+			diagnostics[i].File = relativePath(root, diagnostics[i].File)
+		}
+	}
+}
+
+// relativePath returns path relative to root. Paths outside root are returned unchanged.
+func relativePath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
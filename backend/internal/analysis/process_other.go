@@ -0,0 +1,9 @@
+//go:build !unix
+
+package analysis
+
+import "os/exec"
+
+// setProcessGroup is a no-op where process groups are unavailable; cancellation
+// falls back to killing the Node.js process itself.
+func setProcessGroup(cmd *exec.Cmd) {}
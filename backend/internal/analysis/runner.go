@@ -2,37 +2,137 @@ package analysis
 
 import (
 	"github.com/1107-adishjain/codemap/internal/models"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os/exec"
+	"strings"
+	"time"
 )
 
+// ErrTimeout is returned when the analysis tool exceeds Options.Timeout.
+var ErrTimeout = errors.New("analysis tool timed out")
+
+// waitDelay is how long Wait keeps reading output after the tool was killed,
+// in case a grandchild still holds the pipes open.
+const waitDelay = 5 * time.Second
+
+// Options configures a single run of the Node.js analysis tool.
+type Options struct {
+	// ToolsPath is the directory containing the analyser's main.js.
+	ToolsPath string
+	// TargetDir is the source tree to analyse.
+	TargetDir string
+	// Timeout bounds the run. Zero means no deadline beyond the caller's context.
+	Timeout time.Duration
+	// SkipExtensions lists file extensions (e.g. ".go") the tool should leave out,
+	// typically because a native Analyzer already covered them.
+	SkipExtensions []string
+}
+
+// RunError is returned when the analysis tool fails. It carries whatever the
+// tool reported on stderr before it stopped.
+type RunError struct {
+	Err         error
+	Diagnostics []models.Diagnostic
+}
+
+func (e *RunError) Error() string {
+	msg := e.Err.Error()
+	// Surface the last error the tool reported; the full list stays on Diagnostics.
+	for i := len(e.Diagnostics) - 1; i >= 0; i-- {
+		if e.Diagnostics[i].Level == levelError {
+			return msg + ": " + e.Diagnostics[i].Message
+		}
+	}
+	return msg
+}
+
+func (e *RunError) Unwrap() error { return e.Err }
+
 // Run executes the Node.js analysis tool and returns the parsed data.
 func Run(toolsPath string, targetDir string) (*models.Analysis, error) {
-	// The command and its directory are now configured externally.
-	cmd := exec.Command("node", "main.js", targetDir)
-	cmd.Dir = toolsPath
+	return RunContext(context.Background(), Options{ToolsPath: toolsPath, TargetDir: targetDir})
+}
 
-	fmt.Printf("🔧 ANALYSIS: Running in: %s\n", toolsPath)
-	fmt.Printf("🔧 ANALYSIS: Target directory: %s\n", targetDir)
-	fmt.Printf("🔧 ANALYSIS: Command: %v\n", cmd.Args)
+// RunContext executes the Node.js analysis tool and returns the parsed data.
+// Stdout carries the JSON result; stderr is parsed into diagnostics attached to it.
+// File paths are made relative to opts.TargetDir.
+// When ctx is cancelled or the timeout expires the tool's whole process group is killed.
+func RunContext(ctx context.Context, opts Options) (*models.Analysis, error) {
+	ctx, cancel := opts.withTimeout(ctx)
+	defer cancel()
+
+	cmd, stderr := opts.command(ctx)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
 
-	output, err := cmd.CombinedOutput()
+	err := cmd.Run()
+	diagnostics := stderr.Diagnostics()
+	diagnosticsRelativeTo(opts.TargetDir, diagnostics)
+	if ctx.Err() != nil {
+		return nil, opts.stopError(ctx, diagnostics)
+	}
 	if err != nil {
-		fmt.Printf("❌ ANALYSIS FAILED: %v\n", err)
-		fmt.Printf("❌ OUTPUT: %s\n", string(output))
-		return nil, fmt.Errorf("failed to run analysis tool : %w\nOutput : %s", err, string(output))
+		fmt.Printf("❌ ANALYSIS FAILED: %v (%d diagnostics)\n", err, len(diagnostics))
+		return nil, &RunError{Err: fmt.Errorf("failed to run analysis tool: %w", err), Diagnostics: diagnostics}
 	}
 
-	fmt.Printf("✅ ANALYSIS OUTPUT LENGTH: %d bytes\n", len(output))
+	fmt.Printf("✅ ANALYSIS OUTPUT LENGTH: %d bytes\n", stdout.Len())
 
 	var analysisResult models.Analysis
-	err = json.Unmarshal(output, &analysisResult)
+	err = json.Unmarshal(stdout.Bytes(), &analysisResult)
 	if err != nil {
 		fmt.Printf("❌ JSON UNMARSHAL FAILED: %v\n", err)
-		return nil, fmt.Errorf("failed to unmarshal analysis result: %w", err)
+		return nil, &RunError{Err: fmt.Errorf("failed to unmarshal analysis result: %w", err), Diagnostics: diagnostics}
 	}
+	for i := range analysisResult.Files {
+		relativeTo(opts.TargetDir, &analysisResult.Files[i])
+	}
+	analysisResult.Diagnostics = append(analysisResult.Diagnostics, diagnostics...)
 
-	fmt.Printf("✅ ANALYSIS SUCCESS: Found %d files\n", len(analysisResult.Files))
+	fmt.Printf("✅ ANALYSIS SUCCESS: Found %d files, %d diagnostics\n", len(analysisResult.Files), len(analysisResult.Diagnostics))
 	return &analysisResult, nil
 }
+
+// withTimeout applies opts.Timeout to ctx, if set.
+func (opts Options) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if opts.Timeout > 0 {
+		return context.WithTimeoutCause(ctx, opts.Timeout, ErrTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// command builds the analyser invocation. Stderr is wired to a diagnosticWriter;
+// the caller sets up stdout.
+func (opts Options) command(ctx context.Context, args ...string) (*exec.Cmd, *diagnosticWriter) {
+	args = append([]string{"main.js"}, args...)
+	if len(opts.SkipExtensions) > 0 {
+		args = append(args, "--skip-ext="+strings.Join(opts.SkipExtensions, ","))
+	}
+	// The command and its directory are now configured externally.
+	cmd := exec.CommandContext(ctx, "node", append(args, opts.TargetDir)...)
+	cmd.Dir = opts.ToolsPath
+	cmd.WaitDelay = waitDelay
+	setProcessGroup(cmd)
+
+	stderr := &diagnosticWriter{}
+	cmd.Stderr = stderr
+
+	fmt.Printf("🔧 ANALYSIS: Running in: %s\n", opts.ToolsPath)
+	fmt.Printf("🔧 ANALYSIS: Target directory: %s\n", opts.TargetDir)
+	fmt.Printf("🔧 ANALYSIS: Command: %v\n", cmd.Args)
+	return cmd, stderr
+}
+
+// stopError describes a run that ended because ctx was cancelled or timed out.
+func (opts Options) stopError(ctx context.Context, diagnostics []models.Diagnostic) error {
+	cause := context.Cause(ctx)
+	if errors.Is(cause, ErrTimeout) {
+		cause = fmt.Errorf("%w after %s", ErrTimeout, opts.Timeout)
+	}
+	fmt.Printf("❌ ANALYSIS STOPPED: %v\n", cause)
+	return &RunError{Err: fmt.Errorf("analysis tool stopped: %w", cause), Diagnostics: diagnostics}
+}
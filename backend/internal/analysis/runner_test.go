@@ -0,0 +1,149 @@
+//go:build unix
+
+package analysis
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTool writes script as the main.js of a tools directory and returns the directory.
+// The script sees the target directory as its last argument.
+func fakeTool(t *testing.T, script string) string {
+	t.Helper()
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("node is not installed")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.js"), []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestRunContext(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		files  int
+		// err is a substring of the error, "" for none.
+		err string
+	}{
+		{
+			name:   "result",
+			script: `console.log(JSON.stringify({files: [{path: "a.js", language: "javascript"}, {path: "b.py", language: "python"}]}));`,
+			files:  2,
+		},
+		{
+			name:   "failure reported on stderr",
+			script: `console.error("NODE.JS ANALYZER ERROR: boom"); process.exit(1);`,
+			err:    "failed to run analysis tool: exit status 1: NODE.JS ANALYZER ERROR: boom",
+		},
+		{
+			name:   "malformed output",
+			script: `console.log("not json");`,
+			err:    "failed to unmarshal analysis result",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tools := fakeTool(t, tt.script)
+			result, err := RunContext(context.Background(), Options{ToolsPath: tools, TargetDir: t.TempDir()})
+			if tt.err != "" {
+				var runErr *RunError
+				if !errors.As(err, &runErr) || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("RunContext() error = %v, want a RunError containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RunContext() error = %v", err)
+			}
+			if len(result.Files) != tt.files {
+				t.Errorf("RunContext() returned %d files, want %d", len(result.Files), tt.files)
+			}
+		})
+	}
+}
+
+// hangingTool starts a grandchild that shares the tool's output pipes, records its pid
+// in the target directory and then never exits.
+const hangingTool = `
+const { spawn } = require('child_process');
+const fs = require('fs');
+const path = require('path');
+const child = spawn(process.execPath, ['-e', 'setInterval(() => {}, 1000)'], { stdio: 'inherit' });
+fs.writeFileSync(path.join(process.argv[process.argv.length - 1], 'child.pid'), String(child.pid));
+setInterval(() => {}, 1000);
+`
+
+func TestRunContextStops(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		cancel  time.Duration
+		want    error
+	}{
+		{name: "timeout", timeout: 500 * time.Millisecond, want: ErrTimeout},
+		{name: "cancelled", cancel: 500 * time.Millisecond, want: context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tools := fakeTool(t, hangingTool)
+			target := t.TempDir()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel > 0 {
+				time.AfterFunc(tt.cancel, cancel)
+			}
+
+			start := time.Now()
+			_, err := RunContext(ctx, Options{ToolsPath: tools, TargetDir: target, Timeout: tt.timeout})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("RunContext() error = %v, want %v", err, tt.want)
+			}
+			if tt.want != ErrTimeout && errors.Is(err, ErrTimeout) {
+				t.Errorf("RunContext() error = %v, want no timeout", err)
+			}
+			// Had only node itself been killed, the grandchild would hold the pipes
+			// open until waitDelay.
+			if elapsed := time.Since(start); elapsed >= waitDelay {
+				t.Errorf("RunContext() returned after %s, want the process group killed at once", elapsed)
+			}
+
+			data, err := os.ReadFile(filepath.Join(target, "child.pid"))
+			if err != nil {
+				t.Fatalf("the tool did not record its child: %v", err)
+			}
+			pid, err := strconv.Atoi(string(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			deadline := time.Now().Add(2 * time.Second)
+			for running(pid) {
+				if time.Now().After(deadline) {
+					t.Fatalf("grandchild %d still running", pid)
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+		})
+	}
+}
+
+// running reports whether the process pid exists and is not a zombie.
+func running(pid int) bool {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return false
+	}
+	// The state follows the parenthesised command name.
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
@@ -0,0 +1,117 @@
+package analysis
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/1107-adishjain/codemap/internal/models"
+)
+
+// Record kinds of the analyser's --ndjson output. Each stdout line is one record:
+//
+//	{"kind":"file","file":{...models.File...}}
+//	{"kind":"end","files":<count>}
+//
+// The end record is written last; without it the output is treated as truncated.
+// Unknown kinds are ignored so the analyser can add records without breaking the backend.
+const (
+	recordFile = "file"
+	recordEnd  = "end"
+)
+
+type record struct {
+	Kind  string       `json:"kind"`
+	File  *models.File `json:"file,omitempty"`
+	Files int          `json:"files,omitempty"`
+}
+
+// StreamResult summarises a streamed analysis run.
+type StreamResult struct {
+	Files       int
+	Diagnostics []models.Diagnostic
+}
+
+// Stream runs the analysis tool in NDJSON mode and calls onFile for every file as soon
+// as it is decoded, so memory use does not grow with the size of the repository.
+// File paths are made relative to opts.TargetDir.
+// If onFile returns an error the tool is killed and that error is returned as is.
+func Stream(ctx context.Context, opts Options, onFile func(models.File) error) (*StreamResult, error) {
+	ctx, cancel := opts.withTimeout(ctx)
+	defer cancel()
+	// streamCtx lets us kill the tool when the consumer fails without touching ctx,
+	// which is reserved for caller cancellation and the timeout.
+	streamCtx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+
+	cmd, stderr := opts.command(streamCtx, "--ndjson")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open analysis output: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start analysis tool: %w", err)
+	}
+
+	emit := func(file models.File) error {
+		relativeTo(opts.TargetDir, &file)
+		return onFile(file)
+	}
+	files, ended, consumerErr, decodeErr := decodeRecords(bufio.NewReader(stdout), emit)
+	if consumerErr != nil || decodeErr != nil {
+		stop(errors.New("analysis stream aborted"))
+	}
+	waitErr := cmd.Wait()
+	diagnostics := stderr.Diagnostics()
+	diagnosticsRelativeTo(opts.TargetDir, diagnostics)
+
+	switch {
+	case ctx.Err() != nil:
+		return nil, opts.stopError(ctx, diagnostics)
+	case consumerErr != nil:
+		return nil, consumerErr
+	case decodeErr != nil:
+		return nil, &RunError{Err: decodeErr, Diagnostics: diagnostics}
+	case waitErr != nil:
+		fmt.Printf("❌ ANALYSIS FAILED: %v (%d diagnostics)\n", waitErr, len(diagnostics))
+		return nil, &RunError{Err: fmt.Errorf("failed to run analysis tool: %w", waitErr), Diagnostics: diagnostics}
+	case !ended:
+		return nil, &RunError{Err: fmt.Errorf("analysis output truncated after %d files", files), Diagnostics: diagnostics}
+	}
+
+	fmt.Printf("✅ ANALYSIS SUCCESS: Streamed %d files, %d diagnostics\n", files, len(diagnostics))
+	return &StreamResult{Files: files, Diagnostics: diagnostics}, nil
+}
+
+// decodeRecords reads records until EOF or the first error. Consumer errors and
+// malformed output are reported separately so Stream can tell them apart.
+func decodeRecords(r io.Reader, onFile func(models.File) error) (files int, ended bool, consumerErr, decodeErr error) {
+	dec := json.NewDecoder(r)
+	for {
+		var rec record
+		if err := dec.Decode(&rec); err != nil {
+			if errors.Is(err, io.EOF) {
+				return files, ended, nil, nil
+			}
+			return files, ended, nil, fmt.Errorf("failed to decode analysis record %d: %w", files+1, err)
+		}
+		switch rec.Kind {
+		case recordFile:
+			if rec.File == nil {
+				return files, ended, nil, fmt.Errorf("analysis record %d has no file", files+1)
+			}
+			if err := onFile(*rec.File); err != nil {
+				return files, ended, err, nil
+			}
+			files++
+		case recordEnd:
+			if rec.Files != files {
+				return files, ended, nil, fmt.Errorf("analysis tool reported %d files but streamed %d", rec.Files, files)
+			}
+			ended = true
+		}
+	}
+}
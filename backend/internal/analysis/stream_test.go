@@ -0,0 +1,110 @@
+package analysis
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/1107-adishjain/codemap/internal/models"
+)
+
+func TestDecodeRecords(t *testing.T) {
+	errConsumer := errors.New("consumer failed")
+	tests := []struct {
+		name  string
+		input string
+		// failOn makes the consumer fail on the file with that path.
+		failOn string
+		paths  []string
+		// files is how many files decodeRecords reports as consumed.
+		files int
+		ended bool
+		// consumerErr and decodeErr tell which error is expected, if any.
+		consumerErr bool
+		decodeErr   string
+	}{
+		{
+			name:  "files then end",
+			input: `{"kind":"file","file":{"path":"a.go"}}` + "\n" + `{"kind":"file","file":{"path":"b.go"}}` + "\n" + `{"kind":"end","files":2}` + "\n",
+			paths: []string{"a.go", "b.go"},
+			files: 2,
+			ended: true,
+		},
+		{
+			name:  "no files",
+			input: `{"kind":"end","files":0}`,
+			ended: true,
+		},
+		{
+			name:  "truncated",
+			input: `{"kind":"file","file":{"path":"a.go"}}` + "\n",
+			paths: []string{"a.go"},
+			files: 1,
+		},
+		{
+			name:  "unknown kinds are ignored",
+			input: `{"kind":"progress","files":1}` + "\n" + `{"kind":"file","file":{"path":"a.go"}}` + "\n" + `{"kind":"end","files":1}`,
+			paths: []string{"a.go"},
+			files: 1,
+			ended: true,
+		},
+		{
+			name:      "count mismatch",
+			input:     `{"kind":"file","file":{"path":"a.go"}}` + "\n" + `{"kind":"end","files":3}`,
+			paths:     []string{"a.go"},
+			files:     1,
+			decodeErr: "analysis tool reported 3 files but streamed 1",
+		},
+		{
+			name:      "malformed record",
+			input:     `{"kind":"file","file":{"path":"a.go"}}` + "\n" + `{"kind":"file",` + "\n",
+			paths:     []string{"a.go"},
+			files:     1,
+			decodeErr: "failed to decode analysis record 2",
+		},
+		{
+			name:      "file record without a file",
+			input:     `{"kind":"file"}`,
+			decodeErr: "analysis record 1 has no file",
+		},
+		{
+			name:        "consumer error stops decoding",
+			input:       `{"kind":"file","file":{"path":"a.go"}}` + "\n" + `{"kind":"file","file":{"path":"b.go"}}` + "\n" + `{"kind":"file","file":{"path":"c.go"}}`,
+			failOn:      "b.go",
+			paths:       []string{"a.go", "b.go"},
+			files:       1,
+			consumerErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var paths []string
+			files, ended, consumerErr, decodeErr := decodeRecords(strings.NewReader(tt.input), func(f models.File) error {
+				paths = append(paths, f.Path)
+				if f.Path == tt.failOn {
+					return errConsumer
+				}
+				return nil
+			})
+			if !reflect.DeepEqual(paths, tt.paths) {
+				t.Errorf("consumer saw %v, want %v", paths, tt.paths)
+			}
+			if files != tt.files {
+				t.Errorf("files = %d, want %d", files, tt.files)
+			}
+			if ended != tt.ended {
+				t.Errorf("ended = %v, want %v", ended, tt.ended)
+			}
+			if tt.consumerErr != errors.Is(consumerErr, errConsumer) {
+				t.Errorf("consumerErr = %v, want consumer error %v", consumerErr, tt.consumerErr)
+			}
+			switch {
+			case tt.decodeErr == "" && decodeErr != nil:
+				t.Errorf("decodeErr = %v, want none", decodeErr)
+			case tt.decodeErr != "" && (decodeErr == nil || !strings.Contains(decodeErr.Error(), tt.decodeErr)):
+				t.Errorf("decodeErr = %v, want %q", decodeErr, tt.decodeErr)
+			}
+		})
+	}
+}
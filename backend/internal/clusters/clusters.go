@@ -0,0 +1,195 @@
+// Package clusters partitions a project's files and functions into communities of code
+// that depend on one another, so large graphs can be shown one module at a time.
+package clusters
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/1107-adishjain/codemap/internal/database"
+	"github.com/1107-adishjain/codemap/internal/graphalg"
+)
+
+// Edge weights of the graph the communities are found in. A file is tied to each of its
+// functions more tightly than calls and imports tie code together, so functions leave
+// their file only when most of their calls go elsewhere. Files are also tied, loosely,
+// to a node standing for their directory, which draws the files of a directory together
+// unless their dependencies say otherwise.
+const (
+	importWeight    = 1.0
+	callWeight      = 1.0
+	containsWeight  = 2.0
+	directoryWeight = 0.5
+)
+
+// Stats describes a clustering run.
+type Stats struct {
+	Clusters  int
+	Files     int
+	Functions int
+	Duration  time.Duration
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%d files and %d functions in %d clusters in %s",
+		s.Files, s.Functions, s.Clusters, s.Duration.Round(time.Millisecond))
+}
+
+// member is a file or function being clustered.
+type member struct {
+	id       string
+	function bool
+	// path is the file's path, or the path of the file containing the function.
+	path string
+}
+
+// Compute clusters the project's files and functions with the Louvain method over their
+// IMPORTS, CALLS, MAY_CALL and CONTAINS relationships and the directory prior, and
+// replaces the project's Cluster nodes with the result. A MAY_CALL counts as much as its
+// confidence, the share of a call it stands for.
+func Compute(ctx context.Context, db *database.DB, projectID string) (Stats, error) {
+	started := time.Now()
+	records, err := db.ProjectQuery(ctx, projectID, `
+		MATCH (n:File|Function {project_id: $projectId})
+		RETURN elementId(n) AS id, n:Function AS function, coalesce(n.path, split(n.id, '#')[0]) AS path
+		ORDER BY path, id
+	`, nil)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to load cluster members: %w", err)
+	}
+	var members []member
+	index := map[string]int{}
+	var stats Stats
+	for _, record := range records {
+		m := member{}
+		m.id, _ = record["id"].(string)
+		m.function, _ = record["function"].(bool)
+		m.path, _ = record["path"].(string)
+		index[m.id] = len(members)
+		members = append(members, m)
+		if m.function {
+			stats.Functions++
+		} else {
+			stats.Files++
+		}
+	}
+
+	// Directory nodes follow the members in the graph.
+	dirs := map[string]int{}
+	for _, m := range members {
+		if m.function {
+			continue
+		}
+		if dir := path.Dir(m.path); dirs[dir] == 0 {
+			dirs[dir] = len(members) + len(dirs)
+		}
+	}
+	g := graphalg.NewWeighted(len(members) + len(dirs))
+	for i, m := range members {
+		if !m.function {
+			g.AddWeight(i, dirs[path.Dir(m.path)], directoryWeight)
+		}
+	}
+
+	records, err = db.ProjectQuery(ctx, projectID, `
+		MATCH (a:File|Function {project_id: $projectId})-[r:IMPORTS|CALLS|MAY_CALL|CONTAINS]->(b:File|Function {project_id: $projectId})
+		WHERE a <> b
+		RETURN elementId(a) AS source, elementId(b) AS target, type(r) AS type,
+			sum(CASE WHEN r:MAY_CALL THEN coalesce(r.confidence, 0.0) ELSE 1.0 END) AS weight
+	`, nil)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to load cluster edges: %w", err)
+	}
+	weights := map[string]float64{"IMPORTS": importWeight, "CALLS": callWeight, "MAY_CALL": callWeight, "CONTAINS": containsWeight}
+	for _, record := range records {
+		source, ok1 := index[stringValue(record["source"])]
+		target, ok2 := index[stringValue(record["target"])]
+		if !ok1 || !ok2 {
+			continue
+		}
+		weight, _ := record["weight"].(float64)
+		g.AddWeight(source, target, weights[stringValue(record["type"])]*weight)
+	}
+	if err := ctx.Err(); err != nil {
+		return Stats{}, err
+	}
+
+	communities := g.Louvain()
+	clusters := build(members, communities)
+	if err := db.ReplaceClusters(ctx, projectID, clusters); err != nil {
+		return Stats{}, err
+	}
+	stats.Clusters = len(clusters)
+	stats.Duration = time.Since(started)
+	return stats, nil
+}
+
+// build turns the community of each member into clusters, largest first, named after the
+// directory most of their files (or, failing files, functions) are in.
+func build(members []member, communities []int) []database.Cluster {
+	type group struct {
+		members          []string
+		files, functions int
+		dirs             map[string]int
+	}
+	groups := map[int]*group{}
+	var order []int
+	for i, m := range members {
+		c := communities[i]
+		grp, ok := groups[c]
+		if !ok {
+			grp = &group{dirs: map[string]int{}}
+			groups[c] = grp
+			order = append(order, c)
+		}
+		grp.members = append(grp.members, m.id)
+		// Files count for the name far more than functions.
+		if m.function {
+			grp.functions++
+			grp.dirs[path.Dir(m.path)]++
+		} else {
+			grp.files++
+			grp.dirs[path.Dir(m.path)] += 1000
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(groups[order[i]].members) > len(groups[order[j]].members)
+	})
+
+	clusters := make([]database.Cluster, 0, len(order))
+	names := map[string]int{}
+	for i, c := range order {
+		grp := groups[c]
+		dir, best := "", -1
+		for d, n := range grp.dirs {
+			if n > best || (n == best && d < dir) {
+				dir, best = d, n
+			}
+		}
+		name := dir
+		if names[dir]++; names[dir] > 1 {
+			name = fmt.Sprintf("%s (%d)", dir, names[dir])
+		}
+		clusters = append(clusters, database.Cluster{
+			ID:      fmt.Sprintf("c%d", i+1),
+			Name:    name,
+			Members: grp.members,
+			Props: map[string]any{
+				"directory": dir,
+				"size":      len(grp.members),
+				"files":     grp.files,
+				"functions": grp.functions,
+			},
+		})
+	}
+	return clusters
+}
+
+// stringValue returns v if it is a string, or "".
+func stringValue(v any) string {
+	s, _ := v.(string)
+	return s
+}
@@ -2,6 +2,9 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"strconv"
+	"time"
 )
 
 type AppConfig struct {
@@ -16,6 +19,26 @@ type AppConfig struct {
 	AWSAccessKey string
 	AWSSecretKey string
 	PostgresUrl  string
+	// Neo4jDatabase is the Neo4j database every read and write session uses.
+	Neo4jDatabase string
+	// AnalysisWorkers bounds how many analysis jobs run concurrently.
+	AnalysisWorkers int
+	// AnalysisTimeout bounds a single run of the Node.js analyser.
+	AnalysisTimeout time.Duration
+	// ImportBatchSize caps the rows sent to Neo4j in one UNWIND statement.
+	ImportBatchSize int
+	// ImportCommitSize is how many files the importer writes per transaction.
+	ImportCommitSize int
+	// SourceCacheDir holds extracted project archives served by the source endpoint.
+	SourceCacheDir string
+	// SourceCacheEntries bounds how many extracted archives are kept on disk.
+	SourceCacheEntries int
+	// QueryPageSize is the default page size of the query and graph endpoints.
+	QueryPageSize int
+	// QueryMaxPageSize caps the page size a client may ask for.
+	QueryMaxPageSize int
+	// QueryStreamMaxRows caps the records a single NDJSON stream may return.
+	QueryStreamMaxRows int
 }
 
 // getEnv reads an environment variable or returns a default value.
@@ -26,19 +49,49 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+// getEnvInt reads an integer environment variable or returns a default value.
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+// getEnvDuration reads a duration environment variable (e.g. "30m") or returns a default value.
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
+
 // Load loads configuration from environment variables or uses defaults.
 func Load() *AppConfig {
 	return &AppConfig{
-		Port:         getEnv("PORT", "8080"),
-		Neo4jURI:     getEnv("NEO4J_URI", "path"),
-		Neo4jUser:    getEnv("NEO4J_USERNAME", "neo4j"),
-		Neo4jPass:    getEnv("NEO4J_PASSWORD", "your_neo4j_password"),
-		ToolsPath:    getEnv("TOOLS_PATH", "../tools"),
-		TempUploads:  getEnv("TEMP_UPLOADS", os.TempDir()),
-		S3Bucket:     getEnv("S3_BUCKET", "your-bucket-name"),
-		S3Region:     getEnv("S3_REGION", "your-region"),
-		AWSAccessKey: getEnv("AWS_ACCESS_KEY", ""),
-		AWSSecretKey: getEnv("AWS_SECRET_KEY", ""),
-		PostgresUrl:  getEnv("POSTGRES_URL", ""),
+		Port:               getEnv("PORT", "8080"),
+		Neo4jURI:           getEnv("NEO4J_URI", "path"),
+		Neo4jUser:          getEnv("NEO4J_USERNAME", "neo4j"),
+		Neo4jPass:          getEnv("NEO4J_PASSWORD", "your_neo4j_password"),
+		Neo4jDatabase:      getEnv("NEO4J_DATABASE", "neo4j"),
+		ToolsPath:          getEnv("TOOLS_PATH", "../tools"),
+		TempUploads:        getEnv("TEMP_UPLOADS", os.TempDir()),
+		S3Bucket:           getEnv("S3_BUCKET", "your-bucket-name"),
+		S3Region:           getEnv("S3_REGION", "your-region"),
+		AWSAccessKey:       getEnv("AWS_ACCESS_KEY", ""),
+		AWSSecretKey:       getEnv("AWS_SECRET_KEY", ""),
+		PostgresUrl:        getEnv("POSTGRES_URL", ""),
+		AnalysisWorkers:    getEnvInt("ANALYSIS_WORKERS", 2),
+		AnalysisTimeout:    getEnvDuration("ANALYSIS_TIMEOUT", 30*time.Minute),
+		ImportBatchSize:    getEnvInt("IMPORT_BATCH_SIZE", 1000),
+		ImportCommitSize:   getEnvInt("IMPORT_COMMIT_SIZE", 200),
+		SourceCacheDir:     getEnv("SOURCE_CACHE_DIR", filepath.Join(os.TempDir(), "codemap-source")),
+		SourceCacheEntries: getEnvInt("SOURCE_CACHE_ENTRIES", 20),
+		QueryPageSize:      getEnvInt("QUERY_PAGE_SIZE", 100),
+		QueryMaxPageSize:   getEnvInt("QUERY_MAX_PAGE_SIZE", 1000),
+		QueryStreamMaxRows: getEnvInt("QUERY_STREAM_MAX_ROWS", 50000),
 	}
 }
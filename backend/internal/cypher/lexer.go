@@ -0,0 +1,162 @@
+package cypher
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	// tokQuoted is a backtick-quoted identifier; it is never a keyword.
+	tokQuoted
+	tokString
+	tokNumber
+	tokParam
+	tokPunct
+)
+
+// token is a lexeme of a query with its byte offsets, so rewrites can splice text
+// into the original query without reformatting it.
+type token struct {
+	kind       tokenKind
+	text       string
+	start, end int
+}
+
+// is reports whether t is the unquoted keyword kw (upper case), in any case.
+func (t token) is(kw string) bool {
+	return t.kind == tokIdent && strings.EqualFold(t.text, kw)
+}
+
+// isPunct reports whether t is the punctuation character p.
+func (t token) isPunct(p string) bool {
+	return t.kind == tokPunct && t.text == p
+}
+
+// name returns the identifier t names, without backticks.
+func (t token) name() string {
+	if t.kind == tokQuoted {
+		return strings.ReplaceAll(t.text[1:len(t.text)-1], "``", "`")
+	}
+	return t.text
+}
+
+// lex splits a query into tokens, dropping whitespace and comments.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated comment", ErrRejected)
+			}
+			i += end + 4
+		case c == '\'' || c == '"':
+			end, err := scanString(src, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{tokString, src[i:end], i, end})
+			i = end
+		case c == '`':
+			end := i + 1
+			for {
+				next := strings.IndexByte(src[end:], '`')
+				if next < 0 {
+					return nil, fmt.Errorf("%w: unterminated quoted identifier", ErrRejected)
+				}
+				end += next + 1
+				// A doubled backtick is an escaped backtick inside the name.
+				if end < len(src) && src[end] == '`' {
+					end++
+					continue
+				}
+				break
+			}
+			tokens = append(tokens, token{tokQuoted, src[i:end], i, end})
+			i = end
+		case c == '$':
+			end := i + 1
+			if end < len(src) && src[end] == '`' {
+				next := strings.IndexByte(src[end+1:], '`')
+				if next < 0 {
+					return nil, fmt.Errorf("%w: unterminated quoted parameter", ErrRejected)
+				}
+				end += next + 2
+			} else {
+				end = scanWord(src, end)
+			}
+			tokens = append(tokens, token{tokParam, src[i:end], i, end})
+			i = end
+		case c >= '0' && c <= '9':
+			end := scanNumber(src, i)
+			tokens = append(tokens, token{tokNumber, src[i:end], i, end})
+			i = end
+		default:
+			r, size := utf8.DecodeRuneInString(src[i:])
+			if r == '_' || unicode.IsLetter(r) {
+				end := scanWord(src, i)
+				tokens = append(tokens, token{tokIdent, src[i:end], i, end})
+				i = end
+				continue
+			}
+			// Cypher reads Unicode dashes and arrowheads as relationship punctuation,
+			// which the scoper only knows in ASCII, so any other character is rejected.
+			if r >= utf8.RuneSelf {
+				return nil, fmt.Errorf("%w: unsupported character %q", ErrRejected, r)
+			}
+			tokens = append(tokens, token{tokPunct, src[i : i+size], i, i + size})
+			i += size
+		}
+	}
+	return tokens, nil
+}
+
+// scanString returns the end of the string literal starting at i.
+func scanString(src string, i int) (int, error) {
+	quote := src[i]
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unterminated string", ErrRejected)
+}
+
+// scanWord returns the end of the identifier characters starting at i.
+func scanWord(src string, i int) int {
+	for i < len(src) {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// scanNumber returns the end of the number starting at i. A '.' only continues the
+// number when a digit follows, so ranges such as *1..3 lex as three tokens.
+func scanNumber(src string, i int) int {
+	i = scanWord(src, i)
+	if i+1 < len(src) && src[i] == '.' && src[i+1] >= '0' && src[i+1] <= '9' {
+		i = scanWord(src, i+1)
+	}
+	return i
+}
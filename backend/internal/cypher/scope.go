@@ -0,0 +1,467 @@
+// Package cypher confines user-supplied read queries to a single project's graph, and
+// compiles structured Searches into queries that are scoped from the start.
+//
+// Every code node of a project, and the Project node itself, carries a project_id
+// property. Scope rewrites each node pattern of a query, wherever it appears (MATCH
+// clauses, pattern expressions and comprehensions, subqueries, shortestPath, ...), to
+// require project_id = $projectId. Parentheses outside patterns, as in RETURN (n), are
+// expressions and are left alone. Relationships are only ever created between nodes of
+// the same project, so scoping the nodes scopes the whole query. Queries that write,
+// call procedures, switch databases or call functions outside a fixed allowlist are
+// rejected, and so is anything the lexer cannot make sense of: Scope fails closed.
+package cypher
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrRejected is wrapped by every error describing why a query may not run.
+var ErrRejected = errors.New("query rejected")
+
+// ProjectParam names the parameter the injected predicate refers to. Callers must bind
+// it to the project ID, overriding any value supplied by the client.
+const ProjectParam = "projectId"
+
+// projectProperty is the property every node of a project carries.
+const projectProperty = "project_id"
+
+// forbidden are clauses that write, call procedures or reach beyond the project.
+var forbidden = keywordSet(
+	"CREATE", "MERGE", "DELETE", "DETACH", "SET", "REMOVE", "DROP", "FOREACH", "LOAD",
+	"CALL", "USE", "SHOW", "TERMINATE", "GRANT", "DENY", "REVOKE", "ALTER", "RENAME",
+	"START", "STOP", "ENABLE", "DISABLE", "FINISH",
+)
+
+// keywords may directly precede a parenthesis without making it a function call.
+var keywords = keywordSet(
+	"MATCH", "OPTIONAL", "WHERE", "NOT", "AND", "OR", "XOR", "RETURN", "WITH", "UNWIND",
+	"IN", "DISTINCT", "ORDER", "BY", "SKIP", "OFFSET", "LIMIT", "UNION", "ALL", "ANY",
+	"CASE", "WHEN", "THEN", "ELSE", "END", "AS", "IS", "EXISTS", "CONTAINS", "STARTS",
+	"ENDS", "ASC", "ASCENDING", "DESC", "DESCENDING", "SHORTEST", "GROUP", "GROUPS",
+	"PATH", "PATHS", "REPEATABLE", "DIFFERENT", "ELEMENT", "ELEMENTS", "RELATIONSHIP",
+	"RELATIONSHIPS", "NEXT", "FILTER", "LET", "YIELD",
+)
+
+// selectors are the words that may come between MATCH and its first pattern, or before a
+// path pattern: path selectors (ANY SHORTEST 2, ALL PATHS, ...) and match modes.
+var selectors = keywordSet(
+	"ANY", "ALL", "SHORTEST", "PATH", "PATHS", "GROUP", "GROUPS", "REPEATABLE", "DIFFERENT",
+	"ELEMENT", "ELEMENTS", "RELATIONSHIP", "RELATIONSHIPS",
+)
+
+// pathFunctions take a path pattern as their argument.
+var pathFunctions = keywordSet("SHORTESTPATH", "ALLSHORTESTPATHS")
+
+// subqueries are the expressions whose braces may hold a bare pattern, as in
+// EXISTS { (n)-->(m) } or COUNT { (n:File) }.
+var subqueries = keywordSet("EXISTS", "COUNT", "COLLECT")
+
+// typePredicates follow IS in predicates (IS NULL, IS NOT NULL, IS TYPED ...) rather
+// than in label expressions.
+var typePredicates = keywordSet("NULL", "NOT", "TYPED", "NORMALIZED", "NFC", "NFD", "NFKC", "NFKD")
+
+// literals can never be the variable of a node pattern.
+var literals = keywordSet("NULL", "TRUE", "FALSE", "NAN", "INF", "INFINITY")
+
+// functions are the built-in functions a query may call, lower case. Functions only
+// see the values passed to them, so none of them can reach another project's nodes.
+var functions = map[string]bool{}
+
+// patternFunctions are names that are both functions and keywords, e.g. any(...) and
+// MATCH ANY (a)-->(b); their parentheses are still checked for a node pattern.
+var patternFunctions = map[string]bool{"any": true, "all": true, "exists": true}
+
+func init() {
+	for _, name := range strings.Fields(`
+		abs acos all allshortestpaths any asin atan atan2 avg btrim ceil char_length
+		character_length coalesce collect cos cosh cot coth count date datetime degrees
+		duration e elementid endnode exists exp floor haversin head id isempty isnan
+		keys labels last left length localdatetime localtime log log10 lower ltrim max
+		min nodes none normalize nullif percentilecont percentiledisc pi point properties
+		radians rand randomuuid range reduce relationships replace reverse right round
+		rtrim shortestpath sign sin single sinh size split sqrt startnode stdev stdevp
+		substring sum tail tan tanh time timestamp toboolean tobooleanlist
+		tobooleanornull tofloat tofloatlist tofloatornull tointeger tointegerlist
+		tointegerornull tolower tostring tostringlist tostringornull toupper trim type
+		upper valuetype
+		date.realtime date.statement date.transaction date.truncate
+		datetime.fromepoch datetime.fromepochmillis datetime.realtime datetime.statement
+		datetime.transaction datetime.truncate
+		localdatetime.realtime localdatetime.statement localdatetime.transaction localdatetime.truncate
+		localtime.realtime localtime.statement localtime.transaction localtime.truncate
+		time.realtime time.statement time.transaction time.truncate
+		duration.between duration.indays duration.inmonths duration.inseconds
+		point.distance point.withinbbox
+		vector.similarity.cosine vector.similarity.euclidean
+	`) {
+		functions[name] = true
+	}
+}
+
+func keywordSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
+
+func isKeyword(t token, set map[string]bool) bool {
+	return t.kind == tokIdent && set[strings.ToUpper(t.text)]
+}
+
+// insertion is text to splice into the query at byte offset pos.
+type insertion struct {
+	pos  int
+	text string
+}
+
+// Scope validates a read query and rewrites it so every node it matches belongs to the
+// project bound to $projectId. Errors wrap ErrRejected.
+func Scope(query string) (string, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return "", err
+	}
+	if len(tokens) == 0 {
+		return "", fmt.Errorf("%w: query is empty", ErrRejected)
+	}
+	if err := validate(tokens); err != nil {
+		return "", err
+	}
+	closing, err := matchBrackets(tokens)
+	if err != nil {
+		return "", err
+	}
+
+	// Node patterns are found where patterns are: after MATCH, in the braces of EXISTS and
+	// COUNT subqueries, and in pattern expressions, which always have a relationship.
+	nodes := make(map[int]bool)
+	for i, t := range tokens {
+		switch {
+		case t.is("MATCH") && !propertyName(tokens, i):
+			if err := patternNodes(tokens, closing, i+1, nodes); err != nil {
+				return "", err
+			}
+		case t.isPunct("{") && i > 0 && isKeyword(tokens[i-1], subqueries):
+			if err := patternNodes(tokens, closing, i+1, nodes); err != nil {
+				return "", err
+			}
+		}
+	}
+	for i, t := range tokens {
+		if !t.isPunct("(") {
+			continue
+		}
+		if i > 0 {
+			isCall, err := functionCall(tokens, i)
+			if err != nil {
+				return "", err
+			}
+			if isCall {
+				continue
+			}
+		}
+		if !nodes[i] {
+			if err := chainNodes(tokens, closing, i, nodes); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	var inserts []insertion
+	for i := range nodes {
+		ins, _, err := nodePattern(tokens, closing, i)
+		if err != nil {
+			return "", err
+		}
+		inserts = append(inserts, ins...)
+	}
+
+	sort.SliceStable(inserts, func(a, b int) bool { return inserts[a].pos < inserts[b].pos })
+	var out strings.Builder
+	last := 0
+	for _, ins := range inserts {
+		out.WriteString(query[last:ins.pos])
+		out.WriteString(ins.text)
+		last = ins.pos
+	}
+	out.WriteString(query[last:])
+	return out.String(), nil
+}
+
+// validate rejects forbidden clauses and multiple statements.
+func validate(tokens []token) error {
+	for i, t := range tokens {
+		if t.isPunct(";") && i != len(tokens)-1 {
+			return fmt.Errorf("%w: only a single statement is allowed", ErrRejected)
+		}
+		if !isKeyword(t, forbidden) {
+			continue
+		}
+		if propertyName(tokens, i) {
+			continue
+		}
+		return fmt.Errorf("%w: %s is not allowed; queries are read-only", ErrRejected, strings.ToUpper(t.text))
+	}
+	return nil
+}
+
+// propertyName reports whether the keyword at i is used as a name rather than a clause:
+// property names (n.set), labels (n:Set) and map keys ({set: 1}) are not clauses.
+func propertyName(tokens []token, i int) bool {
+	if i > 0 && (tokens[i-1].isPunct(".") || tokens[i-1].isPunct(":")) {
+		return true
+	}
+	return i+1 < len(tokens) && tokens[i+1].isPunct(":")
+}
+
+// matchBrackets maps the index of every opening bracket to that of its closing one.
+func matchBrackets(tokens []token) (map[int]int, error) {
+	pairs := map[string]string{")": "(", "]": "[", "}": "{"}
+	closing := make(map[int]int)
+	var stack []int
+	for i, t := range tokens {
+		if t.kind != tokPunct {
+			continue
+		}
+		switch t.text {
+		case "(", "[", "{":
+			stack = append(stack, i)
+		case ")", "]", "}":
+			if len(stack) == 0 || tokens[stack[len(stack)-1]].text != pairs[t.text] {
+				return nil, fmt.Errorf("%w: unbalanced %q", ErrRejected, t.text)
+			}
+			closing[stack[len(stack)-1]] = i
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%w: unbalanced %q", ErrRejected, tokens[stack[len(stack)-1]].text)
+	}
+	return closing, nil
+}
+
+// functionCall reports whether the parenthesis at i opens the arguments of a function
+// whose arguments cannot be a node pattern. Unknown functions are rejected, which also
+// keeps out user-defined functions such as apoc.* that can run arbitrary queries.
+func functionCall(tokens []token, i int) (bool, error) {
+	prev := tokens[i-1]
+	if prev.kind != tokIdent && prev.kind != tokQuoted {
+		return false, nil
+	}
+	if prev.kind == tokIdent && isKeyword(prev, keywords) && !functions[strings.ToLower(prev.text)] {
+		return false, nil
+	}
+	// Collect a namespaced name such as vector.similarity.cosine.
+	parts := []string{prev.name()}
+	for j := i - 2; j >= 1 && tokens[j].isPunct(".") && (tokens[j-1].kind == tokIdent || tokens[j-1].kind == tokQuoted); j -= 2 {
+		parts = append([]string{tokens[j-1].name()}, parts...)
+	}
+	name := strings.ToLower(strings.Join(parts, "."))
+	if !functions[name] {
+		return false, fmt.Errorf("%w: function %s is not allowed", ErrRejected, strings.Join(parts, "."))
+	}
+	return !patternFunctions[name], nil
+}
+
+// nodePattern checks whether the parentheses opened at open hold a node pattern,
+// (var :Labels {props}) or (var :Labels WHERE predicate) with every part optional, and
+// if so returns the insertions that add the project predicate to it.
+func nodePattern(tokens []token, closing map[int]int, open int) ([]insertion, bool, error) {
+	end := closing[open]
+	p := open + 1
+
+	variable := ""
+	if p < end && (tokens[p].kind == tokQuoted || tokens[p].kind == tokIdent && !isKeyword(tokens[p], literals)) {
+		// A keyword is only a variable when something pattern-like follows it; (NOT x)
+		// must stay an expression.
+		if tokens[p].kind == tokQuoted || !isKeyword(tokens[p], keywords) || p+1 == end || startsNodeRest(tokens, p+1) {
+			variable = tokens[p].text
+			p++
+		}
+	}
+	if p < end && (tokens[p].isPunct(":") || tokens[p].is("IS") && p+1 < end && labelStart(tokens[p+1])) {
+		p++
+		// Label expressions (:A|B, :!A, :%, :(A&B), :$(param)) run up to the
+		// property map, the WHERE or the end of the pattern.
+		for p < end && !tokens[p].isPunct("{") && !tokens[p].is("WHERE") {
+			if c, ok := closing[p]; ok {
+				p = c
+			}
+			p++
+		}
+	}
+
+	property := projectProperty + ": $" + ProjectParam
+	if p < end && tokens[p].isPunct("{") {
+		mapEnd := closing[p]
+		if err := checkMapKeys(tokens, closing, p, mapEnd); err != nil {
+			return nil, false, err
+		}
+		if mapEnd+1 == end {
+			if mapEnd == p+1 {
+				return []insertion{{tokens[mapEnd].start, property}}, true, nil
+			}
+			return []insertion{{tokens[mapEnd-1].end, ", " + property}}, true, nil
+		}
+		// Should a map ever be followed by a WHERE, scope the WHERE rather than guess.
+		p = mapEnd + 1
+	}
+	switch {
+	case p < end && tokens[p].is("WHERE"):
+		// A pattern takes either a property map or a WHERE, so the predicate joins the
+		// WHERE, which needs the variable.
+		if variable == "" {
+			return nil, false, fmt.Errorf("%w: a node pattern with WHERE needs a variable", ErrRejected)
+		}
+		return []insertion{
+			{tokens[p].end, " " + variable + "." + projectProperty + " = $" + ProjectParam + " AND ("},
+			{tokens[end-1].end, ")"},
+		}, true, nil
+	case p != end:
+		return nil, false, nil
+	case end == open+1:
+		return []insertion{{tokens[end].start, "{" + property + "}"}}, true, nil
+	default:
+		return []insertion{{tokens[end-1].end, " {" + property + "}"}}, true, nil
+	}
+}
+
+// patternNodes marks the node patterns of the patterns starting at tokens[p]: the rest of
+// a MATCH clause or the body of an EXISTS or COUNT subquery. Parenthesised path patterns,
+// as in quantified paths, and the arguments of shortestPath are searched in turn. It
+// stops at the first word that can't be part of a pattern, such as WHERE or RETURN, or at
+// the end of the enclosing brackets.
+func patternNodes(tokens []token, closing map[int]int, p int, nodes map[int]bool) error {
+	for ; p < len(tokens); p++ {
+		t := tokens[p]
+		switch {
+		case t.isPunct("("):
+			if p > 0 && isKeyword(tokens[p-1], pathFunctions) {
+				if err := patternNodes(tokens, closing, p+1, nodes); err != nil {
+					return err
+				}
+			} else if _, ok, err := nodePattern(tokens, closing, p); err != nil {
+				return err
+			} else if ok {
+				nodes[p] = true
+			} else if err := patternNodes(tokens, closing, p+1, nodes); err != nil {
+				return err
+			}
+			p = closing[p]
+		case t.isPunct("[") || t.isPunct("{"):
+			// Relationship details and quantifiers; patterns in their predicates are
+			// pattern expressions.
+			p = closing[p]
+		case t.isPunct(")") || t.isPunct("]") || t.isPunct("}") || t.isPunct(";"):
+			return nil
+		case t.kind == tokIdent || t.kind == tokQuoted:
+			switch {
+			case p+1 < len(tokens) && tokens[p+1].isPunct("="):
+				// A path variable.
+				p++
+			case isKeyword(t, selectors) || isKeyword(t, pathFunctions):
+			default:
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// chainNodes marks the node patterns of the pattern expression starting at the parenthesis
+// at open, if it starts one: a node pattern followed by a relationship. A relationship
+// that is not followed by a node pattern is rejected.
+func chainNodes(tokens []token, closing map[int]int, open int, nodes map[int]bool) error {
+	if _, ok, err := nodePattern(tokens, closing, open); err != nil || !ok {
+		return err
+	}
+	var chain []int
+	for next := open; next >= 0; next = relationship(tokens, closing, closing[next]+1) {
+		if !tokens[next].isPunct("(") {
+			return fmt.Errorf("%w: a relationship must be followed by a node pattern", ErrRejected)
+		}
+		if _, ok, err := nodePattern(tokens, closing, next); err != nil {
+			return err
+		} else if !ok {
+			return fmt.Errorf("%w: a relationship must be followed by a node pattern", ErrRejected)
+		}
+		chain = append(chain, next)
+	}
+	if len(chain) > 1 {
+		for _, i := range chain {
+			nodes[i] = true
+		}
+	}
+	return nil
+}
+
+// relationship returns the index of the token following the relationship pattern at p,
+// (-[...]->, <-[...]-, -->, <--, --), or -1 if there is none.
+func relationship(tokens []token, closing map[int]int, p int) int {
+	at := func(i int, punct string) bool { return i < len(tokens) && tokens[i].isPunct(punct) }
+	left := at(p, "<")
+	if left {
+		p++
+	}
+	if !at(p, "-") {
+		return -1
+	}
+	p++
+	if at(p, "[") {
+		p = closing[p] + 1
+	}
+	if !at(p, "-") {
+		return -1
+	}
+	p++
+	if at(p, ">") {
+		p++
+	}
+	if p >= len(tokens) {
+		return -1
+	}
+	return p
+}
+
+// startsNodeRest reports whether the token at p can follow the variable of a node pattern.
+func startsNodeRest(tokens []token, p int) bool {
+	t := tokens[p]
+	return t.isPunct(":") || t.isPunct("{") || t.isPunct(")") || t.is("WHERE") ||
+		t.is("IS") && p+1 < len(tokens) && labelStart(tokens[p+1])
+}
+
+// labelStart reports whether t can start the label expression after IS, as opposed to
+// a predicate such as IS NULL or IS :: INTEGER.
+func labelStart(t token) bool {
+	switch t.kind {
+	case tokQuoted, tokParam:
+		return true
+	case tokIdent:
+		return !isKeyword(t, typePredicates)
+	case tokPunct:
+		return t.text == "%" || t.text == "!" || t.text == "("
+	}
+	return false
+}
+
+// checkMapKeys rejects a property map that sets project_id itself, which would
+// otherwise fight the injected predicate.
+func checkMapKeys(tokens []token, closing map[int]int, open, end int) error {
+	for p := open + 1; p < end; p++ {
+		if c, ok := closing[p]; ok {
+			p = c
+			continue
+		}
+		t := tokens[p]
+		if (t.kind == tokIdent || t.kind == tokQuoted) && p+1 < end && tokens[p+1].isPunct(":") &&
+			(tokens[p-1].isPunct("{") || tokens[p-1].isPunct(",")) && t.name() == projectProperty {
+			return fmt.Errorf("%w: %s is set by the server and may not be matched on", ErrRejected, projectProperty)
+		}
+	}
+	return nil
+}
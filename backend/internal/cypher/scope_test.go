@@ -0,0 +1,177 @@
+package cypher
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestScope(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "single node",
+			query: "MATCH (n) RETURN n",
+			want:  "MATCH (n {project_id: $projectId}) RETURN n",
+		},
+		{
+			name:  "anonymous node",
+			query: "MATCH () RETURN count(*)",
+			want:  "MATCH ({project_id: $projectId}) RETURN count(*)",
+		},
+		{
+			name:  "labels and properties",
+			query: "MATCH (f:File {path: 'a.go'}) RETURN f",
+			want:  "MATCH (f:File {path: 'a.go', project_id: $projectId}) RETURN f",
+		},
+		{
+			name:  "empty property map",
+			query: "MATCH (f:File {}) RETURN f",
+			want:  "MATCH (f:File {project_id: $projectId}) RETURN f",
+		},
+		{
+			name:  "inline WHERE",
+			query: "MATCH (f:File WHERE f.size > 1) RETURN f",
+			want:  "MATCH (f:File WHERE f.project_id = $projectId AND ( f.size > 1)) RETURN f",
+		},
+		{
+			name:  "relationship chain",
+			query: "MATCH (a)-[:CALLS]->(b)<-[r]-(c)--(d) RETURN a",
+			want:  "MATCH (a {project_id: $projectId})-[:CALLS]->(b {project_id: $projectId})<-[r]-(c {project_id: $projectId})--(d {project_id: $projectId}) RETURN a",
+		},
+		{
+			name:  "multiple MATCH and OPTIONAL MATCH clauses",
+			query: "MATCH (a:File), (b:File) OPTIONAL MATCH (a)-->(c) MATCH (d) RETURN a, b, c, d",
+			want:  "MATCH (a:File {project_id: $projectId}), (b:File {project_id: $projectId}) OPTIONAL MATCH (a {project_id: $projectId})-->(c {project_id: $projectId}) MATCH (d {project_id: $projectId}) RETURN a, b, c, d",
+		},
+		{
+			name:  "pattern comprehension",
+			query: "MATCH (f:File) RETURN [(f)-[:CONTAINS]->(fn:Function) | fn.name] AS names",
+			want:  "MATCH (f:File {project_id: $projectId}) RETURN [(f {project_id: $projectId})-[:CONTAINS]->(fn:Function {project_id: $projectId}) | fn.name] AS names",
+		},
+		{
+			name:  "pattern predicate",
+			query: "MATCH (f) WHERE NOT (f)<-[:IMPORTS]-() RETURN f",
+			want:  "MATCH (f {project_id: $projectId}) WHERE NOT (f {project_id: $projectId})<-[:IMPORTS]-({project_id: $projectId}) RETURN f",
+		},
+		{
+			name:  "EXISTS subquery with a bare pattern",
+			query: "MATCH (f) WHERE EXISTS { (f)-[:IMPORTS]->(:File) } RETURN f",
+			want:  "MATCH (f {project_id: $projectId}) WHERE EXISTS { (f {project_id: $projectId})-[:IMPORTS]->(:File {project_id: $projectId}) } RETURN f",
+		},
+		{
+			name:  "COUNT subquery with a single node",
+			query: "RETURN COUNT { (n:Function) } AS functions",
+			want:  "RETURN COUNT { (n:Function {project_id: $projectId}) } AS functions",
+		},
+		{
+			name:  "EXISTS subquery with MATCH",
+			query: "MATCH (f) WHERE EXISTS { MATCH (f)-->(g) WHERE g.name = 'x' } RETURN f",
+			want:  "MATCH (f {project_id: $projectId}) WHERE EXISTS { MATCH (f {project_id: $projectId})-->(g {project_id: $projectId}) WHERE g.name = 'x' } RETURN f",
+		},
+		{
+			name:  "shortestPath",
+			query: "MATCH p = shortestPath((a:File)-[*..5]-(b:File)) RETURN p",
+			want:  "MATCH p = shortestPath((a:File {project_id: $projectId})-[*..5]-(b:File {project_id: $projectId})) RETURN p",
+		},
+		{
+			name:  "variable-length relationship",
+			query: "MATCH (a)-[:CALLS*1..3]->(b) RETURN b",
+			want:  "MATCH (a {project_id: $projectId})-[:CALLS*1..3]->(b {project_id: $projectId}) RETURN b",
+		},
+		{
+			name:  "quantified path pattern",
+			query: "MATCH (a) ((x)-[:CALLS]->(y)){1,3} (b) RETURN b",
+			want:  "MATCH (a {project_id: $projectId}) ((x {project_id: $projectId})-[:CALLS]->(y {project_id: $projectId})){1,3} (b {project_id: $projectId}) RETURN b",
+		},
+		{
+			name:  "quantified relationship and path selector",
+			query: "MATCH p = ANY SHORTEST (a)-[:CALLS]->+(b) RETURN p",
+			want:  "MATCH p = ANY SHORTEST (a {project_id: $projectId})-[:CALLS]->+(b {project_id: $projectId}) RETURN p",
+		},
+		{
+			name:  "parenthesised expression",
+			query: "MATCH (n) RETURN (n), (n.size + 1) * 2",
+			want:  "MATCH (n {project_id: $projectId}) RETURN (n), (n.size + 1) * 2",
+		},
+		{
+			name:  "parenthesised predicate",
+			query: "MATCH (n) WHERE (n:File) OR (n.name = 'x') RETURN n",
+			want:  "MATCH (n {project_id: $projectId}) WHERE (n:File) OR (n.name = 'x') RETURN n",
+		},
+		{
+			name:  "list predicate",
+			query: "MATCH (n) WHERE any(x IN n.tags WHERE x = 'a') RETURN n",
+			want:  "MATCH (n {project_id: $projectId}) WHERE any(x IN n.tags WHERE x = 'a') RETURN n",
+		},
+		{
+			name:  "comments and strings containing parentheses",
+			query: "// (a)-->(b)\nMATCH (n) /* (m) */ WHERE n.name = '(x)-->(y)' RETURN n",
+			want:  "// (a)-->(b)\nMATCH (n {project_id: $projectId}) /* (m) */ WHERE n.name = '(x)-->(y)' RETURN n",
+		},
+		{
+			name:  "non-ASCII text in strings, comments and names",
+			query: "MATCH (n) // (a)−−>(b)\nWHERE n.name = '–>' AND n.`größe` > 1 RETURN n",
+			want:  "MATCH (n {project_id: $projectId}) // (a)−−>(b)\nWHERE n.name = '–>' AND n.`größe` > 1 RETURN n",
+		},
+		{
+			name:  "keyword as property",
+			query: "MATCH (n) WHERE n.match = 1 RETURN n",
+			want:  "MATCH (n {project_id: $projectId}) WHERE n.match = 1 RETURN n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Scope(tt.query)
+			if err != nil {
+				t.Fatalf("Scope(%q) error: %v", tt.query, err)
+			}
+			if got != tt.want {
+				t.Errorf("Scope(%q)\n got %q\nwant %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopeRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"project_id override", "MATCH (n {project_id: 'other'}) RETURN n"},
+		{"quoted project_id override", "MATCH (n {`project_id`: 'other'}) RETURN n"},
+		{"project_id override in a pattern expression", "MATCH (n) WHERE (n)-->({project_id: 'other'}) RETURN n"},
+		{"CALL procedure", "CALL db.labels()"},
+		{"CALL subquery", "CALL { MATCH (n) RETURN n } RETURN n"},
+		{"apoc function", "RETURN apoc.cypher.runFirstColumnSingle('MATCH (n) RETURN n', {})"},
+		{"unknown function", "MATCH (n) RETURN myFunc(n)"},
+		{"LOAD CSV", "LOAD CSV FROM 'file:///x.csv' AS row RETURN row"},
+		{"USE", "USE other MATCH (n) RETURN n"},
+		{"CREATE", "CREATE (n:File) RETURN n"},
+		{"MERGE", "MERGE (n:File {path: 'a'}) RETURN n"},
+		{"SET", "MATCH (n) SET n.name = 'x'"},
+		{"DELETE", "MATCH (n) DETACH DELETE n"},
+		{"REMOVE", "MATCH (n) REMOVE n.name"},
+		{"FOREACH", "MATCH (n) FOREACH (x IN [1] | SET n.a = x)"},
+		{"multiple statements", "MATCH (n) RETURN n; MATCH (m) RETURN m"},
+		{"unterminated string", "MATCH (n) WHERE n.name = 'x RETURN n"},
+		{"unterminated comment", "MATCH (n) /* RETURN n"},
+		{"unbalanced brackets", "MATCH (n RETURN n"},
+		{"empty", "  // nothing\n"},
+		{"WHERE without a variable", "MATCH (:File WHERE true) RETURN 1"},
+		{"Unicode minus relationship", "RETURN [(x:File)−−>(y) | x.path]"},
+		{"Unicode minus incoming relationship", "RETURN size([(x:File)<−−(y) | y])"},
+		{"en dash relationship", "RETURN [(x:File)–[r]–>(y) | x.path]"},
+		{"Unicode arrowhead", "MATCH (x:File)-->(y) RETURN [(x)--〉(z) | z]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Scope(tt.query)
+			if !errors.Is(err, ErrRejected) {
+				t.Errorf("Scope(%q) = %q, %v; want ErrRejected", tt.query, got, err)
+			}
+		})
+	}
+}
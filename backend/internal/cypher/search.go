@@ -0,0 +1,203 @@
+package cypher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidSearch is wrapped by every error describing why a Search cannot be compiled.
+var ErrInvalidSearch = errors.New("invalid search")
+
+// Limits of a Search.
+const (
+	DefaultSearchLimit = 50
+	MaxSearchLimit     = 500
+	MaxSearchDepth     = 5
+	// MaxSearchPaths caps the paths a traversal returns, whatever the limit.
+	MaxSearchPaths = 2000
+)
+
+// NodeKinds are the node labels a Search can match.
+var NodeKinds = []string{"File", "Class", "Function", "Property", "Parameter", "Import", "ExternalDependency", "ReturnType", "Cluster", "Directory", "Package", "Module", "UnresolvedCall"}
+
+// RelationshipTypes are the relationship types a Search can traverse.
+var RelationshipTypes = []string{
+	"CONTAINS", "HAS_METHOD", "OWNS_METHOD", "HAS_PROPERTY", "HAS_PARAMETER", "HAS_IMPORT",
+	"IMPORTS", "DEPENDS_ON", "CALLS", "MAY_CALL", "RETURNS", "IN_CLUSTER",
+}
+
+// Search is a structured graph query. It matches nodes of the given kinds whose name and
+// path pass the filters and, when Depth is positive, follows relationships of the given
+// types from them.
+type Search struct {
+	// Kinds are the labels of the matched nodes; empty means any of NodeKinds.
+	Kinds []string `json:"kinds"`
+	// Name filters on the node name; Files are named by their path.
+	Name *TextFilter `json:"name"`
+	// Path filters on the repo-relative path of the file a node belongs to.
+	Path *TextFilter `json:"path"`
+	// Relationships are the types to traverse; empty means any of RelationshipTypes.
+	Relationships []string `json:"relationships"`
+	// Direction is "out" (default), "in" or "both".
+	Direction string `json:"direction"`
+	// Depth is how many relationships to follow, up to MaxSearchDepth. It defaults to 1
+	// when Relationships are given and to 0, the matched nodes alone, otherwise.
+	Depth *int `json:"depth"`
+	// TargetKinds restricts the labels of the nodes reached; empty means any.
+	TargetKinds []string `json:"target_kinds"`
+	// Limit caps the matched nodes, DefaultSearchLimit by default.
+	Limit int `json:"limit"`
+}
+
+// TextFilter compares a string property with Value.
+type TextFilter struct {
+	// Op is "equals" (default), "contains", "starts_with" or "ends_with".
+	Op         string `json:"op"`
+	Value      string `json:"value"`
+	IgnoreCase bool   `json:"ignore_case"`
+}
+
+var textOps = map[string]string{
+	"":            "=",
+	"equals":      "=",
+	"contains":    "CONTAINS",
+	"starts_with": "STARTS WITH",
+	"ends_with":   "ENDS WITH",
+}
+
+// Compile turns the search into a parameterized Cypher query scoped to $projectId. Labels
+// and relationship types cannot be parameters, so they are checked against NodeKinds and
+// RelationshipTypes before being written into the query; every other value is passed
+// as a parameter. The query returns the matched node as n and, for traversals, each
+// path found from it as path.
+func (s Search) Compile() (string, map[string]any, error) {
+	kinds, err := labelExpression(s.Kinds, NodeKinds, "kind")
+	if err != nil {
+		return "", nil, err
+	}
+	if kinds == "" {
+		kinds = strings.Join(NodeKinds, "|")
+	}
+	limit := s.Limit
+	switch {
+	case limit == 0:
+		limit = DefaultSearchLimit
+	case limit < 0 || limit > MaxSearchLimit:
+		return "", nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidSearch, MaxSearchLimit)
+	}
+	params := map[string]any{"limit": limit}
+
+	var where []string
+	if s.Name != nil {
+		cond, err := s.Name.condition("coalesce(n.name, n.path)", "name", params)
+		if err != nil {
+			return "", nil, err
+		}
+		where = append(where, cond)
+	}
+	if s.Path != nil {
+		// Files carry their path, Imports the file they are in, and the other symbols
+		// an ID that starts with the path of their file.
+		cond, err := s.Path.condition("coalesce(n.path, n.from_file, split(n.id, '#')[0])", "path", params)
+		if err != nil {
+			return "", nil, err
+		}
+		where = append(where, cond)
+	}
+
+	var q strings.Builder
+	fmt.Fprintf(&q, "MATCH (n:%s {project_id: $projectId})\n", kinds)
+	if len(where) > 0 {
+		fmt.Fprintf(&q, "WHERE %s\n", strings.Join(where, " AND "))
+	}
+	q.WriteString("WITH n ORDER BY coalesce(n.path, n.id, n.name) LIMIT $limit\n")
+
+	depth := 0
+	if len(s.Relationships) > 0 || len(s.TargetKinds) > 0 {
+		depth = 1
+	}
+	if s.Depth != nil {
+		depth = *s.Depth
+	}
+	if depth < 0 || depth > MaxSearchDepth {
+		return "", nil, fmt.Errorf("%w: depth must be between 0 and %d", ErrInvalidSearch, MaxSearchDepth)
+	}
+	if depth == 0 {
+		if len(s.Relationships) > 0 || len(s.TargetKinds) > 0 {
+			return "", nil, fmt.Errorf("%w: relationships and target_kinds need a depth of at least 1", ErrInvalidSearch)
+		}
+		q.WriteString("RETURN n")
+		return q.String(), params, nil
+	}
+
+	types, err := labelExpression(s.Relationships, RelationshipTypes, "relationship")
+	if err != nil {
+		return "", nil, err
+	}
+	if types == "" {
+		types = strings.Join(RelationshipTypes, "|")
+	}
+	targets, err := labelExpression(s.TargetKinds, NodeKinds, "target kind")
+	if err != nil {
+		return "", nil, err
+	}
+	if targets != "" {
+		targets = ":" + targets
+	}
+	var left, right string
+	switch s.Direction {
+	case "", "out":
+		right = ">"
+	case "in":
+		left = "<"
+	case "both":
+	default:
+		return "", nil, fmt.Errorf("%w: direction must be out, in or both", ErrInvalidSearch)
+	}
+	params["maxPaths"] = MaxSearchPaths
+	fmt.Fprintf(&q, "OPTIONAL MATCH path = (n)%s-[:%s*1..%d]-%s(m%s {project_id: $projectId})\n", left, types, depth, right, targets)
+	q.WriteString("RETURN n, path LIMIT $maxPaths")
+	return q.String(), params, nil
+}
+
+// RelationshipPattern checks types against RelationshipTypes and joins them into the
+// type alternation of a relationship pattern, e.g. "CALLS|IMPORTS".
+func RelationshipPattern(types []string) (string, error) {
+	return labelExpression(types, RelationshipTypes, "relationship")
+}
+
+// condition returns the predicate comparing expr with the filter value, stored in
+// params under name.
+func (f *TextFilter) condition(expr, name string, params map[string]any) (string, error) {
+	op, ok := textOps[f.Op]
+	if !ok {
+		return "", fmt.Errorf("%w: %s op must be equals, contains, starts_with or ends_with", ErrInvalidSearch, name)
+	}
+	if f.Value == "" {
+		return "", fmt.Errorf("%w: %s value is required", ErrInvalidSearch, name)
+	}
+	params[name] = f.Value
+	if f.IgnoreCase {
+		return fmt.Sprintf("toLower(%s) %s toLower($%s)", expr, op, name), nil
+	}
+	return fmt.Sprintf("%s %s $%s", expr, op, name), nil
+}
+
+// labelExpression joins names into a label or type alternation after checking each
+// against allowed. It returns "" for no names.
+func labelExpression(names, allowed []string, what string) (string, error) {
+	for _, name := range names {
+		found := false
+		for _, a := range allowed {
+			if name == a {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("%w: unknown %s %q (expected one of %s)", ErrInvalidSearch, what, name, strings.Join(allowed, ", "))
+		}
+	}
+	return strings.Join(names, "|"), nil
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// clusterBatchSize bounds how many IN_CLUSTER relationships ReplaceClusters creates per
+// transaction.
+const clusterBatchSize = 5000
+
+// Cluster is a community of a project's files and functions.
+type Cluster struct {
+	// ID is unique within the project.
+	ID   string
+	Name string
+	// Members are the element IDs of the nodes in the cluster.
+	Members []string
+	// Props are stored on the Cluster node next to id and name.
+	Props map[string]any
+}
+
+// ReplaceClusters removes the project's Cluster nodes and writes clusters in their
+// place, each linked from its members by an IN_CLUSTER relationship, then records on
+// the Project node when they were computed. Members that no longer belong to the
+// project are skipped.
+func (db *DB) ReplaceClusters(ctx context.Context, projectID string, clusters []Cluster) error {
+	session := db.session(ctx, neo4j.AccessModeWrite)
+	defer session.Close(ctx)
+
+	write := func(query string, params map[string]any) (int64, error) {
+		result, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+			res, err := tx.Run(ctx, query, projectParams(projectID, params))
+			if err != nil {
+				return nil, err
+			}
+			record, err := res.Single(ctx)
+			if err != nil {
+				return nil, err
+			}
+			n, _ := record.Get("n")
+			return n, nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		return result.(int64), nil
+	}
+
+	for {
+		deleted, err := write(`
+            MATCH (c:Cluster {project_id: $projectId})
+            WITH c LIMIT $limit
+            DETACH DELETE c
+            RETURN count(*) AS n
+        `, map[string]any{"limit": deleteBatchSize})
+		if err != nil {
+			return fmt.Errorf("failed to delete old clusters: %w", err)
+		}
+		if deleted < deleteBatchSize {
+			break
+		}
+	}
+
+	rows := make([]map[string]any, 0, len(clusters))
+	var members []map[string]any
+	for _, c := range clusters {
+		rows = append(rows, map[string]any{"id": c.ID, "name": c.Name, "props": c.Props})
+		for _, m := range c.Members {
+			members = append(members, map[string]any{"cluster": c.ID, "member": m})
+		}
+	}
+	if _, err := write(`
+        UNWIND $rows AS row
+        CREATE (c:Cluster {project_id: $projectId, id: row.id, name: row.name})
+        SET c += row.props
+        RETURN count(*) AS n
+    `, map[string]any{"rows": rows}); err != nil {
+		return fmt.Errorf("failed to create clusters: %w", err)
+	}
+	for start := 0; start < len(members); start += clusterBatchSize {
+		end := min(start+clusterBatchSize, len(members))
+		_, err := write(`
+            UNWIND $rows AS row
+            MATCH (c:Cluster {project_id: $projectId, id: row.cluster})
+            MATCH (n {project_id: $projectId}) WHERE elementId(n) = row.member
+            CREATE (n)-[:IN_CLUSTER]->(c)
+            RETURN count(*) AS n
+        `, map[string]any{"rows": members[start:end]})
+		if err != nil {
+			return fmt.Errorf("failed to link cluster members: %w", err)
+		}
+	}
+
+	_, err := write("MATCH (p:Project {id: $projectId}) SET p.clusters_computed_at = $at RETURN count(*) AS n", map[string]any{"at": time.Now().UTC()})
+	if err != nil {
+		return fmt.Errorf("failed to stamp project clusters: %w", err)
+	}
+	return nil
+}
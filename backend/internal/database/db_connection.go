@@ -10,6 +10,8 @@ import (
 type DB struct {
 	SQL    *sql.DB
 	Driver neo4j.DriverWithContext
+	// Neo4jDatabase is the Neo4j database sessions are opened on; see session.
+	Neo4jDatabase string
 }
 
 func DBinit(url string) (*sql.DB, error) {
@@ -0,0 +1,358 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/1107-adishjain/codemap/internal/helper"
+	"github.com/1107-adishjain/codemap/internal/imports"
+	"github.com/1107-adishjain/codemap/internal/models"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Defaults for ImportOptions.
+const (
+	DefaultImportBatchSize  = 1000
+	DefaultImportCommitSize = 200
+)
+
+// cleanupTimeout bounds the removal of an abandoned import, whose own context may already be done.
+const cleanupTimeout = 2 * time.Minute
+
+// swapTimeout bounds replacing a project's graph with a committed import. The swap runs
+// even if the import's context is cancelled, since stopping halfway would leave neither
+// graph whole.
+const swapTimeout = 10 * time.Minute
+
+// ImportOptions tunes how an Importer writes to Neo4j.
+type ImportOptions struct {
+	// BatchSize caps the rows sent in a single UNWIND statement.
+	BatchSize int
+	// CommitSize is how many files are written per transaction.
+	CommitSize int
+	// Progress is called as files are written; it may be nil.
+	Progress ImportProgressFunc
+	// SourceDir is the analysed tree, read for the go.mod and tsconfig.json files imports
+	// are resolved with. Without it imports are resolved from the files alone.
+	SourceDir string
+	// Logger receives warnings the import cannot return, such as a failed cleanup.
+	// It defaults to log.Default().
+	Logger *log.Logger
+}
+
+// ImportStats summarises a finished import.
+type ImportStats struct {
+	Files         int
+	Nodes         int
+	Relationships int
+	Statements    int
+	Transactions  int
+	Duration      time.Duration
+}
+
+// FilesPerSecond is the import throughput in files.
+func (s ImportStats) FilesPerSecond() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.Files) / s.Duration.Seconds()
+}
+
+// EntitiesPerSecond is the import throughput in nodes and relationships created.
+func (s ImportStats) EntitiesPerSecond() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.Nodes+s.Relationships) / s.Duration.Seconds()
+}
+
+func (s ImportStats) String() string {
+	return fmt.Sprintf("%d files, %d nodes, %d relationships in %s (%d statements, %d transactions; %.1f files/s, %.0f entities/s)",
+		s.Files, s.Nodes, s.Relationships, s.Duration.Round(time.Millisecond), s.Statements, s.Transactions,
+		s.FilesPerSecond(), s.EntitiesPerSecond())
+}
+
+// Importer writes an analysis into Neo4j one file at a time, so the caller never has to
+// hold the whole analysis in memory. Files are buffered until CommitSize of them are
+// pending, then their nodes are written with batched UNWIND statements in a transaction
+// of their own. Files are also spooled to a temporary NDJSON file and replayed in the same
+// chunks for the relationship pass, since relationships can only be resolved once every
+// node exists. Each file's imports are resolved to the files they refer to just before
+// its relationships are written, when every file of the tree is known.
+//
+// Every node is keyed by the project ID together with its repo-relative path or ID, so
+// projects never share nodes. Chunks are committed as they go, so they are written under
+// a staging ID of the project that no query sees, and the project's current graph stays
+// in place until Commit swaps the staged graph in. A failed or cancelled import therefore
+// leaves the last complete graph untouched: Close removes only the staged nodes, and the
+// importer first clears whatever an earlier, interrupted import left in staging.
+type Importer struct {
+	ctx       context.Context
+	db        *DB
+	session   neo4j.SessionWithContext
+	projectID string
+	// stagingID is the project ID the nodes are written under until Commit.
+	stagingID string
+	opts      ImportOptions
+
+	batch    []models.File
+	resolver *imports.Resolver
+	spool    *os.File
+	enc      *json.Encoder
+	files    int
+	done     bool
+
+	started time.Time
+	counts  helper.WriteCounts
+	txs     int
+}
+
+// NewImporter clears the project's staging graph and creates its staging Project node.
+// The caller must Close the importer.
+func (db *DB) NewImporter(ctx context.Context, projectID, projectName string, opts ImportOptions) (*Importer, error) {
+	if opts.BatchSize < 1 {
+		opts.BatchSize = DefaultImportBatchSize
+	}
+	if opts.CommitSize < 1 {
+		opts.CommitSize = DefaultImportCommitSize
+	}
+	if opts.Progress == nil {
+		opts.Progress = func(string, int, int) {}
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
+	}
+	spool, err := os.CreateTemp("", "codemap-import-*.ndjson")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import spool: %w", err)
+	}
+
+	im := &Importer{
+		ctx:       ctx,
+		db:        db,
+		session:   db.session(ctx, neo4j.AccessModeWrite),
+		projectID: projectID,
+		stagingID: stagingProjectID(projectID),
+		opts:      opts,
+		batch:     make([]models.File, 0, opts.CommitSize),
+		resolver:  imports.NewResolver(opts.SourceDir),
+		spool:     spool,
+		enc:       json.NewEncoder(spool),
+		started:   time.Now(),
+	}
+
+	if err := db.DeleteProjectGraph(ctx, im.stagingID); err != nil {
+		im.Close()
+		return nil, fmt.Errorf("failed to clear previous import: %w", err)
+	}
+	// Create Project node
+	err = im.write(func(tx neo4j.ManagedTransaction) (helper.WriteCounts, error) {
+		_, err := tx.Run(ctx,
+			"MERGE (p:Project {id: $id}) ON CREATE SET p.created_at = datetime() SET p.name = $name, p.project_id = $id",
+			map[string]any{"id": im.stagingID, "name": projectName},
+		)
+		return helper.WriteCounts{Nodes: 1, Statements: 1}, err
+	})
+	if err != nil {
+		im.Close()
+		return nil, err
+	}
+	return im, nil
+}
+
+// Add queues a file for import, writing the pending chunk once it is full.
+func (im *Importer) Add(file models.File) error {
+	if im.done {
+		return errors.New("importer already committed")
+	}
+	if err := im.enc.Encode(file); err != nil {
+		return fmt.Errorf("failed to spool file %s: %w", file.Path, err)
+	}
+	im.resolver.Add(file)
+	im.batch = append(im.batch, file)
+	if len(im.batch) >= im.opts.CommitSize {
+		return im.flushNodes()
+	}
+	return nil
+}
+
+// flushNodes writes and commits the nodes of the pending chunk.
+func (im *Importer) flushNodes() error {
+	if len(im.batch) == 0 {
+		return nil
+	}
+	err := im.write(func(tx neo4j.ManagedTransaction) (helper.WriteCounts, error) {
+		return helper.CreateNodes(im.ctx, tx, im.stagingID, im.batch, im.opts.BatchSize)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create nodes for %d files: %w", len(im.batch), err)
+	}
+	im.files += len(im.batch)
+	im.opts.Progress(ImportPhaseNodes, im.files, 0)
+	im.batch = im.batch[:0]
+	return nil
+}
+
+// Commit writes the remaining nodes, then creates all relationships by replaying the
+// spool in chunks, and finally replaces the project's graph with the staged one. The
+// import counts as complete only once Commit returns nil.
+func (im *Importer) Commit() error {
+	if im.done {
+		return errors.New("importer already committed")
+	}
+	if err := im.flushNodes(); err != nil {
+		return err
+	}
+
+	if _, err := im.spool.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind import spool: %w", err)
+	}
+	dec := json.NewDecoder(bufio.NewReader(im.spool))
+	chunk := make([]models.File, 0, im.opts.CommitSize)
+	processed := 0
+	flush := func() error {
+		err := im.write(func(tx neo4j.ManagedTransaction) (helper.WriteCounts, error) {
+			return helper.CreateRelationships(im.ctx, tx, im.stagingID, chunk, im.opts.BatchSize)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create relationships for %d files: %w", len(chunk), err)
+		}
+		processed += len(chunk)
+		im.opts.Progress(ImportPhaseRelationships, processed, im.files)
+		chunk = chunk[:0]
+		return nil
+	}
+	for {
+		var file models.File
+		if err := dec.Decode(&file); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("failed to read import spool: %w", err)
+		}
+		im.resolver.ResolveFile(&file)
+		chunk = append(chunk, file)
+		if len(chunk) >= im.opts.CommitSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if len(chunk) > 0 {
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+
+	if err := im.swap(); err != nil {
+		return err
+	}
+	im.done = true
+	return nil
+}
+
+// write runs fn in a managed write transaction, which the driver retries on transient
+// errors, and records what it created. The statements are MERGEs, so a retry is harmless.
+func (im *Importer) write(fn func(tx neo4j.ManagedTransaction) (helper.WriteCounts, error)) error {
+	result, err := im.session.ExecuteWrite(im.ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		return fn(tx)
+	})
+	if err != nil {
+		return err
+	}
+	im.counts.Add(result.(helper.WriteCounts))
+	im.txs++
+	return nil
+}
+
+// swap deletes the project's current graph and moves the staged nodes to the project
+// in batches, the Project node last. Queries see an incomplete graph only while it runs.
+func (im *Importer) swap() error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(im.ctx), swapTimeout)
+	defer cancel()
+	if err := im.db.DeleteProjectGraph(ctx, im.projectID); err != nil {
+		return fmt.Errorf("failed to delete the previous graph: %w", err)
+	}
+
+	params := map[string]any{"stagingId": im.stagingID, "projectId": im.projectID, "limit": deleteBatchSize}
+	move := func(cypher string) (int64, error) {
+		result, err := im.session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+			res, err := tx.Run(ctx, cypher, params)
+			if err != nil {
+				return nil, err
+			}
+			record, err := res.Single(ctx)
+			if err != nil {
+				return nil, err
+			}
+			moved, _ := record.Get("moved")
+			return moved, nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		return result.(int64), nil
+	}
+	for _, label := range ProjectNodeLabels {
+		cypher := fmt.Sprintf("MATCH (n:%s {project_id: $stagingId}) WITH n LIMIT $limit SET n.project_id = $projectId RETURN count(*) AS moved", label)
+		for {
+			moved, err := move(cypher)
+			if err != nil {
+				return fmt.Errorf("failed to move staged %s nodes: %w", label, err)
+			}
+			if moved < deleteBatchSize {
+				break
+			}
+		}
+	}
+	if _, err := move("MATCH (p:Project {id: $stagingId}) SET p.id = $projectId, p.project_id = $projectId RETURN count(*) AS moved"); err != nil {
+		return fmt.Errorf("failed to move staged project node: %w", err)
+	}
+	return nil
+}
+
+// stagingProjectID returns the project ID an import of projectID writes its nodes under
+// until it is committed.
+func stagingProjectID(projectID string) string {
+	return projectID + ":staging"
+}
+
+// Files returns how many files have had their nodes written so far.
+func (im *Importer) Files() int {
+	return im.files
+}
+
+// Stats reports what the import has written so far and how fast, timed from NewImporter.
+func (im *Importer) Stats() ImportStats {
+	return ImportStats{
+		Files:         im.files,
+		Nodes:         im.counts.Nodes,
+		Relationships: im.counts.Relationships,
+		Statements:    im.counts.Statements,
+		Transactions:  im.txs,
+		Duration:      time.Since(im.started),
+	}
+}
+
+// Close removes the staged graph unless Commit succeeded, and releases the session and
+// spool. It is safe to call after Commit.
+func (im *Importer) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+	if !im.done && im.txs > 0 {
+		if err := im.db.DeleteProjectGraph(ctx, im.stagingID); err != nil {
+			im.opts.Logger.Printf("Warning: could not clean up partial import of project %s: %v", im.projectID, err)
+		}
+	}
+	im.session.Close(ctx)
+	im.spool.Close()
+	os.Remove(im.spool.Name())
+}
@@ -0,0 +1,247 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Pipeline stages an analysis job moves through.
+const (
+	StageQueued     = "queued"
+	StageUploading  = "uploading"
+	StageExtracting = "extracting"
+	StageAnalyzing  = "analyzing"
+	StageImporting  = "importing"
+	StageScoring    = "scoring"
+	StageClustering = "clustering"
+	StageCompleted  = "completed"
+	StageFailed     = "failed"
+	StageCancelled  = "cancelled"
+)
+
+// Job source types.
+const (
+	SourceGitHub = "github"
+	// SourceS3 runs a project from the archive stored under its s3_key: uploads, which
+	// are stored before they are queued, retries and reanalyses.
+	SourceS3 = "s3"
+)
+
+// Terminal reports whether a stage ends a job.
+func Terminal(stage string) bool {
+	return stage == StageCompleted || stage == StageFailed || stage == StageCancelled
+}
+
+type Job struct {
+	ID         string     `json:"id"`
+	ProjectID  string     `json:"project_id"`
+	SourceType string     `json:"source_type"`
+	Source     string     `json:"-"`
+	Stage      string     `json:"stage"`
+	Error      string     `json:"error,omitempty"`
+	Attempts   int        `json:"attempts"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+const jobColumns = "id, project_id, source_type, source, stage, error, attempts, created_at, started_at, finished_at, updated_at"
+
+func scanJob(row interface{ Scan(...any) error }) (*Job, error) {
+	var j Job
+	var startedAt, finishedAt sql.NullTime
+	if err := row.Scan(&j.ID, &j.ProjectID, &j.SourceType, &j.Source, &j.Stage, &j.Error, &j.Attempts,
+		&j.CreatedAt, &startedAt, &finishedAt, &j.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if startedAt.Valid {
+		j.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		j.FinishedAt = &finishedAt.Time
+	}
+	return &j, nil
+}
+
+// CreateJob enqueues a new analysis job for a project.
+func (db *DB) CreateJob(projectID, sourceType, source string) (string, error) {
+	jobID := uuid.New().String()
+	_, err := db.SQL.Exec(
+		"INSERT INTO analysis_jobs (id, project_id, source_type, source, stage, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $6)",
+		jobID, projectID, sourceType, source, StageQueued, time.Now(),
+	)
+	return jobID, err
+}
+
+// ClaimNextJob atomically takes the oldest queued job and moves it to its first stage:
+// uploading for new sources, extracting for archives already in S3.
+// It returns nil when the queue is empty. SKIP LOCKED lets several workers poll concurrently.
+func (db *DB) ClaimNextJob() (*Job, error) {
+	row := db.SQL.QueryRow(`
+		UPDATE analysis_jobs
+		SET stage = CASE WHEN source_type = $3 THEN $4::text ELSE $1::text END,
+			error = '', attempts = attempts + 1, started_at = NOW(), finished_at = NULL, updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM analysis_jobs
+			WHERE stage = $2
+			ORDER BY created_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING `+jobColumns, StageUploading, StageQueued, SourceS3, StageExtracting)
+	job, err := scanJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return job, err
+}
+
+// UpdateJobStage records the stage a running job has reached on its attempt. Like
+// FinishJob and RequeueJob, it leaves the job alone once another attempt has claimed it,
+// and it does not undo a cancellation requested through CancelRunningJob.
+func (db *DB) UpdateJobStage(jobID string, attempt int, stage string) error {
+	_, err := db.SQL.Exec(
+		"UPDATE analysis_jobs SET stage = $1, updated_at = NOW() WHERE id = $2 AND attempts = $3 AND stage <> $4",
+		stage, jobID, attempt, StageCancelled,
+	)
+	return err
+}
+
+// FinishJob moves a job to a terminal stage on its attempt, storing the failure message
+// if any. It reports false, changing nothing, if the job has been cancelled or has moved
+// to another attempt; see FinishCancelledJob.
+func (db *DB) FinishJob(jobID string, attempt int, stage, errMsg string) (bool, error) {
+	res, err := db.SQL.Exec(
+		"UPDATE analysis_jobs SET stage = $1, error = $2, finished_at = NOW(), updated_at = NOW() WHERE id = $3 AND attempts = $4 AND stage <> $5",
+		stage, errMsg, jobID, attempt, StageCancelled,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// FinishCancelledJob records that the worker of a job cancelled on its attempt has
+// stopped. It reports false if the job was not cancelled on that attempt.
+func (db *DB) FinishCancelledJob(jobID string, attempt int) (bool, error) {
+	res, err := db.SQL.Exec(
+		"UPDATE analysis_jobs SET finished_at = NOW(), updated_at = NOW() WHERE id = $1 AND attempts = $2 AND stage = $3",
+		jobID, attempt, StageCancelled,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// RequeueJob puts a job back on the queue, e.g. when the server shuts down mid-run.
+// It reports false, changing nothing, if the job has been cancelled or has moved to
+// another attempt.
+func (db *DB) RequeueJob(jobID string, attempt int) (bool, error) {
+	res, err := db.SQL.Exec(
+		"UPDATE analysis_jobs SET stage = $1, updated_at = NOW() WHERE id = $2 AND attempts = $3 AND stage <> $4",
+		StageQueued, jobID, attempt, StageCancelled,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// CancelQueuedJob cancels a job that no worker has claimed yet.
+// It reports false if the job had already left the queue.
+func (db *DB) CancelQueuedJob(jobID string) (bool, error) {
+	res, err := db.SQL.Exec(
+		"UPDATE analysis_jobs SET stage = $1, finished_at = NOW(), updated_at = NOW() WHERE id = $2 AND stage = $3",
+		StageCancelled, jobID, StageQueued,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// CancelRunningJob cancels a job a worker has claimed, which may be running in another
+// process. The job's stage becomes cancelled at once, but finished_at is only set once
+// its worker, which learns of the cancellation through HeartbeatJob, has stopped; see
+// JobStopped. It reports false if the job was not running.
+func (db *DB) CancelRunningJob(jobID string) (bool, error) {
+	res, err := db.SQL.Exec(
+		"UPDATE analysis_jobs SET stage = $1, updated_at = NOW() WHERE id = $2 AND stage NOT IN ($1, $3, $4, $5)",
+		StageCancelled, jobID, StageQueued, StageCompleted, StageFailed,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// JobStopped reports whether no worker is running the job any more: it has finished, or
+// its lease has not been renewed for lease, so its process died.
+func (db *DB) JobStopped(jobID string, lease time.Duration) (bool, error) {
+	var stopped bool
+	err := db.SQL.QueryRow(
+		"SELECT finished_at IS NOT NULL OR updated_at < NOW() - $2 * INTERVAL '1 second' FROM analysis_jobs WHERE id = $1",
+		jobID, lease.Seconds(),
+	).Scan(&stopped)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+	return stopped, err
+}
+
+// HeartbeatJob renews the lease of a running job on its attempt and returns the job's
+// stage: cancelled when its cancellation was requested, or "" when the job is no longer
+// on that attempt, e.g. because it was requeued as stale and claimed by another worker,
+// which now owns it. Leases are not renewed for jobs in the queue or a terminal stage.
+func (db *DB) HeartbeatJob(jobID string, attempt int) (string, error) {
+	var stage string
+	err := db.SQL.QueryRow(`
+		UPDATE analysis_jobs
+		SET updated_at = CASE WHEN stage NOT IN ($3, $4, $5, $6) THEN NOW() ELSE updated_at END
+		WHERE id = $1 AND attempts = $2
+		RETURNING stage`,
+		jobID, attempt, StageQueued, StageCompleted, StageFailed, StageCancelled,
+	).Scan(&stage)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return stage, err
+}
+
+// RequeueStaleJobs returns running jobs whose lease has not been renewed for lease, whose
+// process must have died, to the queue. Jobs other processes are still running renew
+// their lease through HeartbeatJob and are left alone.
+func (db *DB) RequeueStaleJobs(lease time.Duration) (int64, error) {
+	res, err := db.SQL.Exec(
+		`UPDATE analysis_jobs SET stage = $1, updated_at = NOW()
+		WHERE stage NOT IN ($1, $2, $3, $4) AND updated_at < NOW() - $5 * INTERVAL '1 second'`,
+		StageQueued, StageCompleted, StageFailed, StageCancelled, lease.Seconds(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetLatestJobByProject returns the most recent job for a project, or nil if none exists.
+func (db *DB) GetLatestJobByProject(projectID string) (*Job, error) {
+	row := db.SQL.QueryRow(
+		"SELECT "+jobColumns+" FROM analysis_jobs WHERE project_id = $1 ORDER BY created_at DESC LIMIT 1",
+		projectID,
+	)
+	job, err := scanJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return job, err
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// testJobsDB connects to the Postgres database TEST_POSTGRES_URL names and applies the
+// migrations in a schema of their own, dropped when the test ends. The test is skipped
+// when the variable is unset.
+func testJobsDB(t *testing.T) *DB {
+	t.Helper()
+	url := os.Getenv("TEST_POSTGRES_URL")
+	if url == "" {
+		t.Skip("TEST_POSTGRES_URL is not set")
+	}
+	conn, err := DBinit(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One connection, so the search path set below holds for every statement.
+	conn.SetMaxOpenConns(1)
+	schema := "codemap_test_" + uuid.New().String()[:8]
+	t.Cleanup(func() {
+		conn.Exec(fmt.Sprintf("DROP SCHEMA %s CASCADE", schema))
+		conn.Close()
+	})
+	if _, err := conn.Exec(fmt.Sprintf("CREATE SCHEMA %s; SET search_path TO %s", schema, schema)); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"sample_sql_file.sql", "analysis_jobs.sql"} {
+		migration, err := os.ReadFile(filepath.Join("..", "..", "migrations", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Exec(string(migration)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	return &DB{SQL: conn}
+}
+
+// claimJob enqueues a job for a new project and claims it.
+func claimJob(t *testing.T, db *DB) *Job {
+	t.Helper()
+	projectID := uuid.New().String()
+	if _, err := db.SQL.Exec("INSERT INTO projects (id, name) VALUES ($1, 'test')", projectID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateJob(projectID, SourceGitHub, "https://example.com/repo.git"); err != nil {
+		t.Fatal(err)
+	}
+	job, err := db.ClaimNextJob()
+	if err != nil || job == nil {
+		t.Fatalf("ClaimNextJob() = %v, %v", job, err)
+	}
+	return job
+}
+
+// jobState returns a job's stage and whether it has finished.
+func jobState(t *testing.T, db *DB, jobID string) (string, bool) {
+	t.Helper()
+	var stage string
+	var finishedAt sql.NullTime
+	if err := db.SQL.QueryRow("SELECT stage, finished_at FROM analysis_jobs WHERE id = $1", jobID).Scan(&stage, &finishedAt); err != nil {
+		t.Fatal(err)
+	}
+	return stage, finishedAt.Valid
+}
+
+func TestJobFencing(t *testing.T) {
+	db := testJobsDB(t)
+
+	t.Run("finish on the current attempt", func(t *testing.T) {
+		job := claimJob(t, db)
+		if ok, err := db.FinishJob(job.ID, job.Attempts, StageCompleted, ""); !ok || err != nil {
+			t.Fatalf("FinishJob() = %v, %v; want true", ok, err)
+		}
+		if stage, finished := jobState(t, db, job.ID); stage != StageCompleted || !finished {
+			t.Errorf("job is %s, finished %v; want completed and finished", stage, finished)
+		}
+	})
+
+	t.Run("cancelled while running", func(t *testing.T) {
+		job := claimJob(t, db)
+		if ok, err := db.CancelRunningJob(job.ID); !ok || err != nil {
+			t.Fatalf("CancelRunningJob() = %v, %v; want true", ok, err)
+		}
+		if err := db.UpdateJobStage(job.ID, job.Attempts, StageImporting); err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := db.RequeueJob(job.ID, job.Attempts); ok || err != nil {
+			t.Errorf("RequeueJob() = %v, %v; want false", ok, err)
+		}
+		if ok, err := db.FinishJob(job.ID, job.Attempts, StageCompleted, ""); ok || err != nil {
+			t.Errorf("FinishJob() = %v, %v; want false", ok, err)
+		}
+		if stage, finished := jobState(t, db, job.ID); stage != StageCancelled || finished {
+			t.Errorf("job is %s, finished %v; want cancelled and running", stage, finished)
+		}
+		if ok, err := db.FinishCancelledJob(job.ID, job.Attempts); !ok || err != nil {
+			t.Errorf("FinishCancelledJob() = %v, %v; want true", ok, err)
+		}
+		if stage, finished := jobState(t, db, job.ID); stage != StageCancelled || !finished {
+			t.Errorf("job is %s, finished %v; want cancelled and finished", stage, finished)
+		}
+	})
+
+	t.Run("taken over by another attempt", func(t *testing.T) {
+		stale := claimJob(t, db)
+		if ok, err := db.RequeueJob(stale.ID, stale.Attempts); !ok || err != nil {
+			t.Fatalf("RequeueJob() = %v, %v; want true", ok, err)
+		}
+		job, err := db.ClaimNextJob()
+		if err != nil || job == nil || job.ID != stale.ID || job.Attempts != stale.Attempts+1 {
+			t.Fatalf("ClaimNextJob() = %+v, %v; want attempt %d of job %s", job, err, stale.Attempts+1, stale.ID)
+		}
+		if stage, err := db.HeartbeatJob(stale.ID, stale.Attempts); stage != "" || err != nil {
+			t.Errorf("HeartbeatJob() on the old attempt = %q, %v; want \"\"", stage, err)
+		}
+		if ok, err := db.RequeueJob(stale.ID, stale.Attempts); ok || err != nil {
+			t.Errorf("RequeueJob() on the old attempt = %v, %v; want false", ok, err)
+		}
+		if ok, err := db.FinishJob(stale.ID, stale.Attempts, StageFailed, "stale"); ok || err != nil {
+			t.Errorf("FinishJob() on the old attempt = %v, %v; want false", ok, err)
+		}
+		if ok, err := db.FinishCancelledJob(stale.ID, stale.Attempts); ok || err != nil {
+			t.Errorf("FinishCancelledJob() on the old attempt = %v, %v; want false", ok, err)
+		}
+		if stage, finished := jobState(t, db, job.ID); stage != StageUploading || finished {
+			t.Errorf("job is %s, finished %v; want uploading and running", stage, finished)
+		}
+	})
+
+	t.Run("stale lease", func(t *testing.T) {
+		job := claimJob(t, db)
+		if _, err := db.SQL.Exec("UPDATE analysis_jobs SET updated_at = NOW() - INTERVAL '1 hour' WHERE id = $1", job.ID); err != nil {
+			t.Fatal(err)
+		}
+		if stopped, err := db.JobStopped(job.ID, time.Minute); !stopped || err != nil {
+			t.Errorf("JobStopped() = %v, %v; want true", stopped, err)
+		}
+		if n, err := db.RequeueStaleJobs(time.Minute); n != 1 || err != nil {
+			t.Errorf("RequeueStaleJobs() = %d, %v; want 1", n, err)
+		}
+		if stage, _ := jobState(t, db, job.ID); stage != StageQueued {
+			t.Errorf("job is %s, want queued", stage)
+		}
+	})
+}
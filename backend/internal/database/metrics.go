@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// metricsBatchSize bounds how many nodes WriteNodeMetrics updates per transaction.
+const metricsBatchSize = 5000
+
+// NodeMetrics are the scores computed for one node of a project graph.
+type NodeMetrics struct {
+	// ElementID identifies the node.
+	ElementID string
+	// Values are stored as node properties, replacing earlier values of the same name.
+	Values map[string]any
+}
+
+// WriteNodeMetrics stores computed scores as properties of the project's nodes, in
+// batches, then records on the Project node when they were computed. Nodes that no
+// longer belong to the project are skipped.
+func (db *DB) WriteNodeMetrics(ctx context.Context, projectID string, nodes []NodeMetrics) error {
+	session := db.session(ctx, neo4j.AccessModeWrite)
+	defer session.Close(ctx)
+
+	write := func(query string, params map[string]any) error {
+		_, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+			res, err := tx.Run(ctx, query, projectParams(projectID, params))
+			if err != nil {
+				return nil, err
+			}
+			return res.Consume(ctx)
+		})
+		return err
+	}
+
+	for start := 0; start < len(nodes); start += metricsBatchSize {
+		end := min(start+metricsBatchSize, len(nodes))
+		rows := make([]map[string]any, 0, end-start)
+		for _, n := range nodes[start:end] {
+			rows = append(rows, map[string]any{"id": n.ElementID, "values": n.Values})
+		}
+		err := write(`
+            UNWIND $rows AS row
+            MATCH (n {project_id: $projectId}) WHERE elementId(n) = row.id
+            SET n += row.values
+        `, map[string]any{"rows": rows})
+		if err != nil {
+			return fmt.Errorf("failed to write node metrics: %w", err)
+		}
+	}
+	err := write("MATCH (p:Project {id: $projectId}) SET p.metrics_computed_at = $at", map[string]any{"at": time.Now().UTC()})
+	if err != nil {
+		return fmt.Errorf("failed to stamp project metrics: %w", err)
+	}
+	return nil
+}
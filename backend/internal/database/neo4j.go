@@ -1,27 +1,28 @@
 package database
 
 import (
-	"github.com/1107-adishjain/codemap/internal/helper"
-	"github.com/1107-adishjain/codemap/internal/models"
+	"github.com/1107-adishjain/codemap/internal/cypher"
 	"context"
 	"fmt"
-	"regexp"
 
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 )
 
-// Query executes a read-only Cypher query and returns the results as a slice of maps, filtered by projectID if provided.
-func (db *DB) Query(ctx context.Context, cypher string, params map[string]any) ([]map[string]any, error) {
-	session := db.Driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
-	defer session.Close(ctx)
+// ProjectQuery executes a read-only Cypher query against one project's graph. The query
+// must scope itself with the $projectId parameter, which always holds projectID. Client
+// queries must be passed through cypher.Scope first. All records are held in memory, so
+// the query must bound its own result; see ProjectQueryPage and ProjectQueryEach.
+func (db *DB) ProjectQuery(ctx context.Context, projectID, query string, params map[string]any) ([]map[string]any, error) {
+	return db.read(ctx, query, projectParams(projectID, params))
+}
 
-	// If projectID is present in params, inject project filtering into the Cypher query
-	if projectID, ok := params["projectId"]; ok && projectID != nil && projectID != "" {
-		cypher = wrapCypherWithProjectFilter(cypher)
-	}
+// read runs query in a read transaction and collects the records as maps.
+func (db *DB) read(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	session := db.session(ctx, neo4j.AccessModeRead)
+	defer session.Close(ctx)
 
 	result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
-		res, err := tx.Run(ctx, cypher, params)
+		res, err := tx.Run(ctx, query, params)
 		if err != nil {
 			return nil, err
 		}
@@ -46,116 +47,159 @@ func (db *DB) Query(ctx context.Context, cypher string, params map[string]any) (
 	return result.([]map[string]any), nil
 }
 
-// wrapCypherWithProjectFilter injects project filtering into the Cypher query for modularity.
-func wrapCypherWithProjectFilter(cypher string) string {
-	// First, handle the original MATCH (n) and MATCH (n: cases for backward compatibility
-	if idx := findMatchClauseIndex(cypher, "MATCH (n)"); idx != -1 {
-		return cypher[:idx] + "MATCH (p:Project {id: $projectId})<-[:BELONGS_TO]-(n)" + cypher[idx+len("MATCH (n)"):]
+// ProjectQueryPage runs a project query like ProjectQuery but returns only the records
+// in [offset, offset+limit). Records are read as Neo4j yields them, so the ones before and
+// after the page are never held in memory, though Neo4j still produces every record up to
+// the end of the page. more reports whether records follow the page.
+func (db *DB) ProjectQueryPage(ctx context.Context, projectID, query string, params map[string]any, offset, limit int) ([]map[string]any, bool, error) {
+	params = projectParams(projectID, params)
+	session := db.session(ctx, neo4j.AccessModeRead)
+	defer session.Close(ctx)
+
+	type page struct {
+		records []map[string]any
+		more    bool
 	}
-	if idx := findMatchClauseIndex(cypher, "MATCH (n:"); idx != -1 {
-		return cypher[:idx] + "MATCH (p:Project {id: $projectId})<-[:BELONGS_TO]-(n:" + cypher[idx+len("MATCH (n:"):]
+	result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		res, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+		p := page{records: []map[string]any{}}
+		for i := 0; res.Next(ctx); i++ {
+			if i < offset {
+				continue
+			}
+			if len(p.records) == limit {
+				p.more = true
+				break
+			}
+			p.records = append(p.records, res.Record().AsMap())
+		}
+		return p, res.Err()
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed during query execution: %w", err)
 	}
+	p := result.(page)
+	return p.records, p.more, nil
+}
 
-	// Now, handle any MATCH (x:Label) pattern (excluding n)
-	re := regexp.MustCompile(`MATCH \((\w+):(\w+)\)`)
-	loc := re.FindStringSubmatchIndex(cypher)
-	if loc != nil {
-		varName := cypher[loc[2]:loc[3]]
-		label := cypher[loc[4]:loc[5]]
-		// Don't replace if varName is 'n' (already handled above)
-		if varName != "n" {
-			replacement := "MATCH (p:Project {id: $projectId})<-[:BELONGS_TO]-(" + varName + ":" + label + ")"
-			return cypher[:loc[0]] + replacement + cypher[loc[1]:]
+// ProjectQueryEach runs a project query and hands each record to fn as Neo4j yields it,
+// stopping after maxRows records. It reports how many records fn received and whether
+// more were available. The query runs in an auto-commit transaction, which the driver
+// does not retry, so fn never sees a record twice.
+func (db *DB) ProjectQueryEach(ctx context.Context, projectID, query string, params map[string]any, maxRows int, fn func(record map[string]any) error) (int, bool, error) {
+	params = projectParams(projectID, params)
+	session := db.session(ctx, neo4j.AccessModeRead)
+	defer session.Close(ctx)
+
+	res, err := session.Run(ctx, query, params)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed during query execution: %w", err)
+	}
+	rows := 0
+	for res.Next(ctx) {
+		if rows == maxRows {
+			return rows, true, nil
+		}
+		if err := fn(res.Record().AsMap()); err != nil {
+			return rows, false, err
 		}
+		rows++
+	}
+	if err := res.Err(); err != nil {
+		return rows, false, fmt.Errorf("failed during query execution: %w", err)
 	}
-	// If not found, return as is
-	return cypher
+	return rows, false, nil
 }
 
-// findMatchClauseIndex returns the index of the first occurrence of the clause, or -1 if not found.
-func findMatchClauseIndex(s, clause string) int {
-	for i := 0; i+len(clause) <= len(s); i++ {
-		if s[i:i+len(clause)] == clause {
-			return i
-		}
+// projectParams binds $projectId to projectID, overriding any value in params.
+func projectParams(projectID string, params map[string]any) map[string]any {
+	if params == nil {
+		params = make(map[string]any)
 	}
-	return -1
+	params[cypher.ProjectParam] = projectID
+	return params
 }
 
-// ImportAnalysis imports the entire analysis result into Neo4j within a single transaction.
-func (db *DB) ImportAnalysis(ctx context.Context, analysisData *models.Analysis, projectID, projectName string) error {
-	session := db.Driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: "neo4j"})
-	defer session.Close(ctx)
+// Import phases reported through ImportProgressFunc.
+const (
+	ImportPhaseNodes         = "nodes"
+	ImportPhaseRelationships = "relationships"
+)
 
-	fmt.Printf("🔍 IMPORTING ANALYSIS: %d files found\n", len(analysisData.Files))
-	for i, file := range analysisData.Files {
-		fmt.Printf("  File %d: %s (%s) - %d classes, %d functions\n",
-			i+1, file.Path, file.Language, len(file.Classes), len(file.Functions))
-		if file.Error != "" {
-			fmt.Printf("    ERROR: %s\n", file.Error)
-		}
-	}
+// ImportProgressFunc is called after each file of an import phase is written. total is
+// the number of files of the phase, which the nodes phase does not know: it writes files
+// as the analyser streams them, so it always reports 0.
+type ImportProgressFunc func(phase string, processed, total int)
 
-	_, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
-		// Create Project node
-		_, err := tx.Run(ctx,
-			"MERGE (p:Project {id: $id, name: $name, created_at: datetime()})",
-			map[string]any{"id": projectID, "name": projectName},
-		)
-		if err != nil {
-			return nil, err
-		}
+// ProjectNodeLabels lists the labels of the nodes a project owns, i.e. that carry its project_id.
+var ProjectNodeLabels = []string{"File", "Class", "Function", "Property", "Parameter", "Import", "ExternalDependency", "ReturnType", "Cluster", "Directory", "Package", "Module", "UnresolvedCall"}
 
-		// Create all nodes and link to Project
-		for _, file := range analysisData.Files {
-			if err := helper.CreateNodesForFile(ctx, tx, file); err != nil {
-				return nil, fmt.Errorf("failed to create nodes for file %s: %w", file.Path, err)
-			}
-			// Link File node to Project
-			_, err := tx.Run(ctx,
-				"MATCH (f:File {path: $path}), (p:Project {id: $id}) MERGE (f)-[:BELONGS_TO]->(p)",
-				map[string]any{"path": file.Path, "id": projectID},
-			)
+// deleteBatchSize bounds how many nodes DeleteProjectGraph removes per transaction.
+const deleteBatchSize = 10000
+
+// DeleteProjectGraph removes a project's nodes, their relationships and the Project node.
+// Nodes are deleted in batches so large projects don't need one huge transaction.
+// Deleting a graph that is already gone is not an error.
+func (db *DB) DeleteProjectGraph(ctx context.Context, projectID string) error {
+	session := db.session(ctx, neo4j.AccessModeWrite)
+	defer session.Close(ctx)
+
+	deleteBatch := func(cypher string) (int64, error) {
+		result, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+			res, err := tx.Run(ctx, cypher, map[string]any{"projectId": projectID, "limit": deleteBatchSize})
 			if err != nil {
 				return nil, err
 			}
-			// Link Class nodes to Project
-			for _, class := range file.Classes {
-				classId := fmt.Sprintf("%s#%s", file.Path, class.Name)
-				_, err := tx.Run(ctx,
-					"MATCH (c:Class {id: $classId}), (p:Project {id: $id}) MERGE (c)-[:BELONGS_TO]->(p)",
-					map[string]any{"classId": classId, "id": projectID},
-				)
-				if err != nil {
-					return nil, err
-				}
-			}
-			// Link Function nodes to Project
-			for _, function := range file.Functions {
-				funcId := fmt.Sprintf("%s#%s", file.Path, function.Name)
-				_, err := tx.Run(ctx,
-					"MATCH (fn:Function {id: $funcId}), (p:Project {id: $id}) MERGE (fn)-[:BELONGS_TO]->(p)",
-					map[string]any{"funcId": funcId, "id": projectID},
-				)
-				if err != nil {
-					return nil, err
-				}
+			record, err := res.Single(ctx)
+			if err != nil {
+				return nil, err
 			}
+			deleted, _ := record.Get("deleted")
+			return deleted, nil
+		})
+		if err != nil {
+			return 0, err
 		}
+		return result.(int64), nil
+	}
 
-		// Create all relationships
-		for _, file := range analysisData.Files {
-			if err := helper.CreateRelationshipsForFile(ctx, tx, file); err != nil {
-				return nil, fmt.Errorf("failed to create relationships for file %s: %w", file.Path, err)
+	for _, label := range ProjectNodeLabels {
+		cypher := fmt.Sprintf("MATCH (n:%s {project_id: $projectId}) WITH n LIMIT $limit DETACH DELETE n RETURN count(*) AS deleted", label)
+		for {
+			deleted, err := deleteBatch(cypher)
+			if err != nil {
+				return fmt.Errorf("failed to delete %s nodes: %w", label, err)
+			}
+			if deleted < deleteBatchSize {
+				break
 			}
 		}
-		return nil, nil
-	})
-
-	if err != nil {
-		return fmt.Errorf("failed to execute import transaction: %w", err)
 	}
-
-	fmt.Println("Successfully imported analysis into Neo4j and linked to project.")
+	// Graphs imported before nodes carried project_id are only reachable through the
+	// BELONGS_TO edges of their Files, Classes and Functions; the Parameters, Properties
+	// and Imports hanging off those go with them.
+	for {
+		deleted, err := deleteBatch(`
+            MATCH (:Project {id: $projectId})<-[:BELONGS_TO]-(n)
+            WITH n LIMIT $limit
+            OPTIONAL MATCH (n)-[:HAS_PARAMETER|HAS_PROPERTY|HAS_IMPORT]->(child)
+            WITH n, collect(child) AS children
+            FOREACH (c IN children | DETACH DELETE c)
+            DETACH DELETE n
+            RETURN count(*) AS deleted
+        `)
+		if err != nil {
+			return fmt.Errorf("failed to delete legacy project nodes: %w", err)
+		}
+		if deleted < deleteBatchSize {
+			break
+		}
+	}
+	if _, err := deleteBatch("MATCH (p:Project {id: $projectId}) DETACH DELETE p RETURN count(*) AS deleted"); err != nil {
+		return fmt.Errorf("failed to delete project node: %w", err)
+	}
 	return nil
 }
@@ -24,7 +24,13 @@ func NewDB(cfg *config.AppConfig) (*DB, error) {
 	}
 
 	fmt.Println("Successfully connected to Neo4j.")
-	return &DB{Driver: driver}, nil
+	return &DB{Driver: driver, Neo4jDatabase: cfg.Neo4jDatabase}, nil
+}
+
+// session opens a Neo4j session on the configured database. Every session goes through
+// here so reads and writes never address different databases.
+func (db *DB) session(ctx context.Context, mode neo4j.AccessMode) neo4j.SessionWithContext {
+	return db.Driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: mode, DatabaseName: db.Neo4jDatabase})
 }
 
 // Close gracefully closes the database driver.
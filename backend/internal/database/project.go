@@ -1,6 +1,8 @@
 package database
 
 import (
+	"database/sql"
+	"errors"
 	"github.com/google/uuid"
 	"time"
 )
@@ -45,4 +47,31 @@ func (db *DB) GetProjectsByUser(userID string) ([]Project, error) {
     }
     return projects, nil
 }
+
+// GetProjectByID returns a single project, or nil if it does not exist.
+func (db *DB) GetProjectByID(projectID string) (*Project, error) {
+	var p Project
+	err := db.SQL.QueryRow("SELECT id, user_id, name, s3_key, status, created_at FROM projects WHERE id = $1", projectID).
+		Scan(&p.ID, &p.UserID, &p.Name, &p.S3Key, &p.Status, &p.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// UpdateProjectS3Key records where the project's archive was stored once the upload finishes.
+func (db *DB) UpdateProjectS3Key(projectID, s3Key string) error {
+	_, err := db.SQL.Exec("UPDATE projects SET s3_key = $1 WHERE id = $2", s3Key, projectID)
+	return err
+}
+
+// DeleteProject removes the project row; its analysis jobs go with it (ON DELETE CASCADE).
+// Deleting a project that no longer exists is not an error.
+func (db *DB) DeleteProject(projectID string) error {
+	_, err := db.SQL.Exec("DELETE FROM projects WHERE id = $1", projectID)
+	return err
+}
 // ...existing code...
\ No newline at end of file
@@ -0,0 +1,232 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// ErrSchemaTooNew is returned when the graph was migrated by a newer version of the API.
+var ErrSchemaTooNew = errors.New("graph schema is newer than this build supports")
+
+// schemaMigration is one step of the graph schema. Statements must be idempotent
+// (IF NOT EXISTS), since a crash between a statement and the version update re-runs them.
+type schemaMigration struct {
+	version     int
+	description string
+	statements  []string
+}
+
+// schemaMigrations are applied in order. Append new versions; never edit released ones.
+var schemaMigrations = []schemaMigration{
+	{
+		version:     1,
+		description: "project-scoped node identity",
+		// Every code node is unique per project, keyed by its repo-relative path (File),
+		// its name (ExternalDependency, ReturnType) or its ID. The constraints also back
+		// the MERGE and MATCH lookups of the importer.
+		statements: []string{
+			"CREATE CONSTRAINT project_id IF NOT EXISTS FOR (p:Project) REQUIRE p.id IS UNIQUE",
+			"CREATE CONSTRAINT file_identity IF NOT EXISTS FOR (n:File) REQUIRE (n.project_id, n.path) IS UNIQUE",
+			"CREATE CONSTRAINT class_identity IF NOT EXISTS FOR (n:Class) REQUIRE (n.project_id, n.id) IS UNIQUE",
+			"CREATE CONSTRAINT function_identity IF NOT EXISTS FOR (n:Function) REQUIRE (n.project_id, n.id) IS UNIQUE",
+			"CREATE CONSTRAINT property_identity IF NOT EXISTS FOR (n:Property) REQUIRE (n.project_id, n.id) IS UNIQUE",
+			"CREATE CONSTRAINT parameter_identity IF NOT EXISTS FOR (n:Parameter) REQUIRE (n.project_id, n.id) IS UNIQUE",
+			"CREATE CONSTRAINT import_identity IF NOT EXISTS FOR (n:Import) REQUIRE (n.project_id, n.id) IS UNIQUE",
+			"CREATE CONSTRAINT external_dependency_identity IF NOT EXISTS FOR (n:ExternalDependency) REQUIRE (n.project_id, n.name) IS UNIQUE",
+			"CREATE CONSTRAINT return_type_identity IF NOT EXISTS FOR (n:ReturnType) REQUIRE (n.project_id, n.name) IS UNIQUE",
+		},
+	},
+	{
+		version:     2,
+		description: "lookup indexes",
+		// The CALLS resolver looks callees up by name, and the graph endpoints filter
+		// by name, method owner and language.
+		statements: []string{
+			"CREATE INDEX function_name IF NOT EXISTS FOR (n:Function) ON (n.project_id, n.name)",
+			"CREATE INDEX function_method_of IF NOT EXISTS FOR (n:Function) ON (n.project_id, n.is_method_of)",
+			"CREATE INDEX class_name IF NOT EXISTS FOR (n:Class) ON (n.project_id, n.name)",
+			"CREATE INDEX file_language IF NOT EXISTS FOR (n:File) ON (n.project_id, n.language)",
+			"CREATE INDEX import_source IF NOT EXISTS FOR (n:Import) ON (n.project_id, n.source)",
+		},
+	},
+	{
+		version:     3,
+		description: "full-text search indexes",
+		statements: []string{
+			"CREATE FULLTEXT INDEX symbol_search IF NOT EXISTS FOR (n:Function|Class) ON EACH [n.name, n.id]",
+			"CREATE FULLTEXT INDEX file_search IF NOT EXISTS FOR (n:File) ON EACH [n.path]",
+		},
+	},
+	{
+		version:     4,
+		description: "project_id on Project nodes",
+		// Client queries are scoped by requiring project_id on every node they match
+		// (see package cypher), so the Project node carries its own ID there too.
+		statements: []string{
+			"MATCH (p:Project) WHERE p.project_id IS NULL SET p.project_id = p.id",
+		},
+	},
+	{
+		version:     5,
+		description: "cluster identity",
+		// Clusters are recomputed as a whole and looked up by ID when expanded.
+		statements: []string{
+			"CREATE CONSTRAINT cluster_identity IF NOT EXISTS FOR (n:Cluster) REQUIRE (n.project_id, n.id) IS UNIQUE",
+		},
+	},
+	{
+		version:     6,
+		description: "directory, package and module identity",
+		// Directories are merged by path and packages and modules by ID on every import.
+		statements: []string{
+			"CREATE CONSTRAINT directory_identity IF NOT EXISTS FOR (n:Directory) REQUIRE (n.project_id, n.path) IS UNIQUE",
+			"CREATE CONSTRAINT package_identity IF NOT EXISTS FOR (n:Package) REQUIRE (n.project_id, n.id) IS UNIQUE",
+			"CREATE CONSTRAINT module_identity IF NOT EXISTS FOR (n:Module) REQUIRE (n.project_id, n.id) IS UNIQUE",
+		},
+	},
+	{
+		version:     7,
+		description: "unresolved call identity",
+		// Unresolved calls are shared by every function making the same call.
+		statements: []string{
+			"CREATE CONSTRAINT unresolved_call_identity IF NOT EXISTS FOR (n:UnresolvedCall) REQUIRE (n.project_id, n.name) IS UNIQUE",
+		},
+	},
+	{
+		version:     8,
+		description: "project_id on legacy graphs",
+		// Graphs imported before nodes carried project_id tie their Files, Classes and
+		// Functions to the Project through BELONGS_TO and the rest hangs off those. Nodes
+		// of a single project take its ID, so scoped endpoints see them again; nodes that
+		// were merged across projects stay unscoped. Either way the graph keeps the paths
+		// and IDs of the old importer, so every such project is flagged for reanalysis;
+		// see ClaimLegacyProjects.
+		statements: []string{
+			`MATCH (n:File|Class|Function)-[:BELONGS_TO]->(p:Project)
+			WHERE n.project_id IS NULL
+			WITH n, collect(DISTINCT p.id) AS owners
+			WHERE size(owners) = 1
+			SET n.project_id = owners[0]`,
+			`MATCH (owner:File|Class|Function)-[:CONTAINS|HAS_PROPERTY|HAS_PARAMETER|HAS_IMPORT|DEPENDS_ON|RETURNS]->(n)
+			WHERE owner.project_id IS NOT NULL AND n.project_id IS NULL
+				AND (n:Class OR n:Function OR n:Property OR n:Parameter OR n:Import OR n:ExternalDependency OR n:ReturnType)
+			WITH n, collect(DISTINCT owner.project_id) AS owners
+			WHERE size(owners) = 1
+			SET n.project_id = owners[0]`,
+			`MATCH (p:Project)<-[:BELONGS_TO]-()
+			WITH DISTINCT p
+			SET p.reanalyse = true`,
+		},
+	},
+}
+
+// SchemaVersion is the graph schema version this build expects.
+func SchemaVersion() int {
+	return schemaMigrations[len(schemaMigrations)-1].version
+}
+
+// EnsureSchema brings the graph schema up to SchemaVersion, applying pending migrations
+// in order and recording each applied version on a singleton :SchemaVersion node.
+// It returns ErrSchemaTooNew, without touching the graph, when the recorded version
+// is newer than this build knows.
+func (db *DB) EnsureSchema(ctx context.Context) error {
+	session := db.session(ctx, neo4j.AccessModeWrite)
+	defer session.Close(ctx)
+
+	current, err := currentSchemaVersion(ctx, session)
+	if err != nil {
+		return fmt.Errorf("failed to read graph schema version: %w", err)
+	}
+	if current > SchemaVersion() {
+		return fmt.Errorf("%w: graph is at version %d, this build supports up to %d", ErrSchemaTooNew, current, SchemaVersion())
+	}
+
+	for _, m := range schemaMigrations {
+		if m.version <= current {
+			continue
+		}
+		// Schema statements can't share a transaction with each other or with data
+		// writes, so each runs in its own auto-commit transaction.
+		for _, stmt := range m.statements {
+			if err := runAutoCommit(ctx, session, stmt, nil); err != nil {
+				return fmt.Errorf("failed to apply schema version %d (%s): %w", m.version, m.description, err)
+			}
+		}
+		err := runAutoCommit(ctx, session, `
+            MERGE (s:SchemaVersion {id: 'codemap'})
+            SET s.version = $version, s.description = $description, s.applied_at = datetime()
+        `, map[string]any{"version": m.version, "description": m.description})
+		if err != nil {
+			return fmt.Errorf("failed to record schema version %d: %w", m.version, err)
+		}
+		fmt.Printf("🗂️ SCHEMA: Applied version %d (%s)\n", m.version, m.description)
+	}
+	return nil
+}
+
+// ClaimLegacyProjects returns the projects migrations flagged for reanalysis, clearing
+// the flag so that, with several API instances starting at once, each project is
+// returned to one of them only.
+func (db *DB) ClaimLegacyProjects(ctx context.Context) ([]string, error) {
+	session := db.session(ctx, neo4j.AccessModeWrite)
+	defer session.Close(ctx)
+
+	result, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		res, err := tx.Run(ctx, "MATCH (p:Project {reanalyse: true}) REMOVE p.reanalyse RETURN p.id AS id", nil)
+		if err != nil {
+			return nil, err
+		}
+		records, err := res.Collect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var ids []string
+		for _, record := range records {
+			id, _ := record.Get("id")
+			if s, ok := id.(string); ok {
+				ids = append(ids, s)
+			}
+		}
+		return ids, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]string), nil
+}
+
+// currentSchemaVersion returns the recorded schema version, or 0 for an unversioned graph.
+func currentSchemaVersion(ctx context.Context, session neo4j.SessionWithContext) (int, error) {
+	result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		res, err := tx.Run(ctx, "MATCH (s:SchemaVersion {id: 'codemap'}) RETURN s.version AS version", nil)
+		if err != nil {
+			return nil, err
+		}
+		records, err := res.Collect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return int64(0), nil
+		}
+		version, _ := records[0].Get("version")
+		n, _ := version.(int64)
+		return n, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(result.(int64)), nil
+}
+
+func runAutoCommit(ctx context.Context, session neo4j.SessionWithContext, cypher string, params map[string]any) error {
+	result, err := session.Run(ctx, cypher, params)
+	if err != nil {
+		return err
+	}
+	_, err = result.Consume(ctx)
+	return err
+}
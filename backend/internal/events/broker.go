@@ -0,0 +1,210 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types published while a project is analysed.
+const (
+	TypeStage     = "stage"
+	TypeProgress  = "progress"
+	TypeCompleted = "completed"
+	TypeFailed    = "failed"
+	TypeCancelled = "cancelled"
+)
+
+const (
+	// historySize is how many events are kept per project for Last-Event-ID replay.
+	historySize = 500
+	// historyTTL is how long a finished project's history is kept before it is pruned.
+	historyTTL = time.Hour
+	// subscriberBuffer is how far a subscriber may fall behind before it is dropped.
+	subscriberBuffer = 64
+)
+
+// Event is a single pipeline update for a project. Progress events count the files of
+// an import phase in Processed and Total; Total is omitted while the number of files is
+// unknown, as it is for the nodes phase.
+type Event struct {
+	// ID numbers the event within its job attempt, starting at 1.
+	ID        int64     `json:"id"`
+	ProjectID string    `json:"project_id"`
+	JobID     string    `json:"job_id,omitempty"`
+	Attempt   int       `json:"attempt,omitempty"`
+	Type      string    `json:"type"`
+	Stage     string    `json:"stage,omitempty"`
+	Phase     string    `json:"phase,omitempty"`
+	Processed int       `json:"processed,omitempty"`
+	Total     int       `json:"total,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// Terminal reports whether the event ends the job.
+func (e Event) Terminal() bool {
+	return e.Type == TypeCompleted || e.Type == TypeFailed || e.Type == TypeCancelled
+}
+
+// Cursor is the position of an event in a project's stream. It is sent as the SSE id, so
+// a client that reconnects, even to a restarted server, names the job attempt it was
+// following as well as the last event it saw.
+type Cursor struct {
+	JobID   string
+	Attempt int
+	ID      int64
+}
+
+// Cursor returns the position of the event.
+func (e Event) Cursor() Cursor {
+	return Cursor{JobID: e.JobID, Attempt: e.Attempt, ID: e.ID}
+}
+
+// String formats the cursor as "<job id>:<attempt>:<id>".
+func (c Cursor) String() string {
+	return fmt.Sprintf("%s:%d:%d", c.JobID, c.Attempt, c.ID)
+}
+
+// ParseCursor parses a cursor formatted by Cursor.String.
+func ParseCursor(s string) (Cursor, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 || parts[0] == "" {
+		return Cursor{}, errors.New("malformed event cursor")
+	}
+	attempt, err := strconv.Atoi(parts[1])
+	if err != nil || attempt < 0 {
+		return Cursor{}, errors.New("malformed event cursor")
+	}
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || id < 0 {
+		return Cursor{}, errors.New("malformed event cursor")
+	}
+	return Cursor{JobID: parts[0], Attempt: attempt, ID: id}, nil
+}
+
+// projectLog holds the events of the job attempt a project is running, or ran last.
+type projectLog struct {
+	jobID    string
+	attempt  int
+	nextID   int64
+	events   []Event
+	updated  time.Time
+	finished bool
+}
+
+// Broker fans pipeline events out to subscribers and keeps a short per-project
+// history so reconnecting clients can resume from the last event they saw. Only the
+// latest job attempt of a project is kept: a retry, or a new job, starts a new history.
+type Broker struct {
+	mu      sync.Mutex
+	history map[string]*projectLog
+	subs    map[string]map[chan Event]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		history: make(map[string]*projectLog),
+		subs:    make(map[string]map[chan Event]struct{}),
+	}
+}
+
+// Publish assigns the event an ID, records it and delivers it to the project's subscribers.
+// Subscribers that cannot keep up are disconnected; they resume via Last-Event-ID. Events
+// of an attempt older than the one the project's history holds are dropped.
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	log, ok := b.history[e.ProjectID]
+	switch {
+	case !ok || log.jobID != e.JobID:
+		log = &projectLog{jobID: e.JobID, attempt: e.Attempt}
+		b.history[e.ProjectID] = log
+	case e.Attempt < log.attempt:
+		return
+	case e.Attempt > log.attempt:
+		*log = projectLog{jobID: e.JobID, attempt: e.Attempt}
+	}
+
+	log.nextID++
+	e.ID = log.nextID
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	log.events = append(log.events, e)
+	if len(log.events) > historySize {
+		log.events = log.events[len(log.events)-historySize:]
+	}
+	log.updated = e.Time
+	log.finished = e.Terminal()
+
+	for ch := range b.subs[e.ProjectID] {
+		select {
+		case ch <- e:
+		default:
+			b.removeLocked(e.ProjectID, ch)
+		}
+	}
+
+	b.pruneLocked(e.Time)
+}
+
+// Subscribe returns the buffered events after last and a channel for live events. When
+// last belongs to another job attempt than the buffered events, or is the zero Cursor,
+// every buffered event is returned. The returned cancel function must be called when the
+// subscriber goes away.
+func (b *Broker) Subscribe(projectID string, last Cursor) ([]Event, <-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var backlog []Event
+	if log, ok := b.history[projectID]; ok {
+		after := int64(0)
+		if last.JobID == log.jobID && last.Attempt == log.attempt {
+			after = last.ID
+		}
+		for _, e := range log.events {
+			if e.ID > after {
+				backlog = append(backlog, e)
+			}
+		}
+	}
+
+	ch := make(chan Event, subscriberBuffer)
+	if b.subs[projectID] == nil {
+		b.subs[projectID] = make(map[chan Event]struct{})
+	}
+	b.subs[projectID][ch] = struct{}{}
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.removeLocked(projectID, ch)
+	}
+	return backlog, ch, cancel
+}
+
+func (b *Broker) removeLocked(projectID string, ch chan Event) {
+	subs := b.subs[projectID]
+	if _, ok := subs[ch]; !ok {
+		return
+	}
+	delete(subs, ch)
+	close(ch)
+	if len(subs) == 0 {
+		delete(b.subs, projectID)
+	}
+}
+
+// pruneLocked drops histories of finished projects nobody is watching.
+func (b *Broker) pruneLocked(now time.Time) {
+	for projectID, log := range b.history {
+		if log.finished && now.Sub(log.updated) > historyTTL && len(b.subs[projectID]) == 0 {
+			delete(b.history, projectID)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package events
+
+import "testing"
+
+func TestParseCursor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Cursor
+		ok   bool
+	}{
+		{"job-1:2:17", Cursor{JobID: "job-1", Attempt: 2, ID: 17}, true},
+		{"job-1:0:0", Cursor{JobID: "job-1"}, true},
+		{"17", Cursor{}, false},
+		{":1:2", Cursor{}, false},
+		{"job-1:x:2", Cursor{}, false},
+		{"job-1:1:-2", Cursor{}, false},
+		{"job-1:1:2:3", Cursor{}, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseCursor(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseCursor(%q) = %+v, %v; want %+v, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+		if tt.ok && got.String() != tt.in {
+			t.Errorf("ParseCursor(%q).String() = %q", tt.in, got.String())
+		}
+	}
+}
+
+func TestBrokerHistory(t *testing.T) {
+	type publish struct {
+		jobID   string
+		attempt int
+		typ     string
+	}
+	tests := []struct {
+		name      string
+		published []publish
+		last      Cursor
+		// want lists the cursors of the events Subscribe returns.
+		want []Cursor
+	}{
+		{
+			name:      "from the start",
+			published: []publish{{"a", 1, TypeStage}, {"a", 1, TypeProgress}},
+			want:      []Cursor{{"a", 1, 1}, {"a", 1, 2}},
+		},
+		{
+			name:      "resume within the attempt",
+			published: []publish{{"a", 1, TypeStage}, {"a", 1, TypeProgress}, {"a", 1, TypeCompleted}},
+			last:      Cursor{"a", 1, 1},
+			want:      []Cursor{{"a", 1, 2}, {"a", 1, 3}},
+		},
+		{
+			name:      "retry drops the earlier attempt",
+			published: []publish{{"a", 1, TypeStage}, {"a", 1, TypeFailed}, {"a", 2, TypeStage}},
+			want:      []Cursor{{"a", 2, 1}},
+		},
+		{
+			name:      "cursor of an earlier attempt replays the current one",
+			published: []publish{{"a", 1, TypeStage}, {"a", 2, TypeStage}, {"a", 2, TypeProgress}},
+			last:      Cursor{"a", 1, 5},
+			want:      []Cursor{{"a", 2, 1}, {"a", 2, 2}},
+		},
+		{
+			// A client that followed an earlier server process knows higher IDs than
+			// the restarted one has handed out.
+			name:      "cursor from before a restart",
+			published: []publish{{"a", 3, TypeStage}},
+			last:      Cursor{"a", 2, 40},
+			want:      []Cursor{{"a", 3, 1}},
+		},
+		{
+			name:      "late event of an earlier attempt",
+			published: []publish{{"a", 2, TypeStage}, {"a", 1, TypeFailed}},
+			want:      []Cursor{{"a", 2, 1}},
+		},
+		{
+			name:      "new job",
+			published: []publish{{"a", 1, TypeCompleted}, {"b", 1, TypeStage}},
+			last:      Cursor{"a", 1, 1},
+			want:      []Cursor{{"b", 1, 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBroker()
+			for _, p := range tt.published {
+				b.Publish(Event{ProjectID: "p", JobID: p.jobID, Attempt: p.attempt, Type: p.typ})
+			}
+			backlog, _, cancel := b.Subscribe("p", tt.last)
+			defer cancel()
+			if len(backlog) != len(tt.want) {
+				t.Fatalf("Subscribe returned %d events, want %d: %+v", len(backlog), len(tt.want), backlog)
+			}
+			for i, e := range backlog {
+				if e.Cursor() != tt.want[i] {
+					t.Errorf("event %d = %+v, want %+v", i, e.Cursor(), tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package graphalg
+
+import "math"
+
+// Degrees returns the in-degree (fan-in) and out-degree (fan-out) of every node, indexed
+// like Nodes.
+func (g *Graph) Degrees() (in, out []int) {
+	in = make([]int, len(g.names))
+	out = make([]int, len(g.names))
+	for v, succ := range g.succ {
+		out[v] = len(succ)
+		for _, w := range succ {
+			in[w]++
+		}
+	}
+	return in, out
+}
+
+// PageRank returns the PageRank of every node, indexed like Nodes, with the given damping
+// factor. The ranks sum to 1; nodes without edges out spread their rank over every node.
+// Iteration stops after maxIterations or once no rank moves by more than tolerance in
+// total.
+func (g *Graph) PageRank(damping float64, maxIterations int, tolerance float64) []float64 {
+	n := len(g.names)
+	if n == 0 {
+		return nil
+	}
+	rank := make([]float64, n)
+	next := make([]float64, n)
+	for i := range rank {
+		rank[i] = 1 / float64(n)
+	}
+	for iter := 0; iter < maxIterations; iter++ {
+		dangling := 0.0
+		for v := range rank {
+			if len(g.succ[v]) == 0 {
+				dangling += rank[v]
+			}
+		}
+		base := (1-damping)/float64(n) + damping*dangling/float64(n)
+		for i := range next {
+			next[i] = base
+		}
+		for v, succ := range g.succ {
+			if len(succ) == 0 {
+				continue
+			}
+			share := damping * rank[v] / float64(len(succ))
+			for _, w := range succ {
+				next[w] += share
+			}
+		}
+		delta := 0.0
+		for i := range rank {
+			delta += math.Abs(next[i] - rank[i])
+		}
+		rank, next = next, rank
+		if delta < tolerance {
+			break
+		}
+	}
+	return rank
+}
+
+// Betweenness returns the betweenness centrality of every node, indexed like Nodes: the
+// share of shortest paths between other nodes that pass through it, normalised to [0, 1]
+// by the (n-1)(n-2) ordered pairs. Brandes' algorithm costs O(nodes × edges), so when
+// samples is positive and smaller than the graph, only that many evenly spread source
+// nodes are used and the result is an estimate.
+func (g *Graph) Betweenness(samples int) []float64 {
+	n := len(g.names)
+	score := make([]float64, n)
+	if n < 3 {
+		return score
+	}
+	step := 1
+	if samples > 0 && samples < n {
+		step = n / samples
+	}
+
+	sigma := make([]float64, n)
+	dist := make([]int, n)
+	delta := make([]float64, n)
+	preds := make([][]int, n)
+	order := make([]int, 0, n)
+	queue := make([]int, 0, n)
+	sources := 0
+	for s := 0; s < n; s += step {
+		sources++
+		for i := range sigma {
+			sigma[i], dist[i], delta[i] = 0, -1, 0
+			preds[i] = preds[i][:0]
+		}
+		sigma[s], dist[s] = 1, 0
+		order = order[:0]
+		queue = append(queue[:0], s)
+		for head := 0; head < len(queue); head++ {
+			v := queue[head]
+			order = append(order, v)
+			for _, w := range g.succ[v] {
+				if dist[w] < 0 {
+					dist[w] = dist[v] + 1
+					queue = append(queue, w)
+				}
+				if dist[w] == dist[v]+1 {
+					sigma[w] += sigma[v]
+					preds[w] = append(preds[w], v)
+				}
+			}
+		}
+		// Accumulate dependencies from the farthest nodes back.
+		for i := len(order) - 1; i >= 0; i-- {
+			w := order[i]
+			for _, v := range preds[w] {
+				delta[v] += sigma[v] / sigma[w] * (1 + delta[w])
+			}
+			if w != s {
+				score[w] += delta[w]
+			}
+		}
+	}
+
+	scale := float64(n) / float64(sources) / float64((n-1)*(n-2))
+	for i := range score {
+		score[i] *= scale
+	}
+	return score
+}
@@ -0,0 +1,95 @@
+package graphalg
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// closeTo reports whether got matches want to within 1e-6, element by element.
+func closeTo(got, want []float64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if math.Abs(got[i]-want[i]) > 1e-6 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDegrees(t *testing.T) {
+	g := graphOf("a->b", "a->c", "b->c", "a->b")
+	in, out := g.Degrees()
+	if want := []int{0, 1, 2}; !reflect.DeepEqual(in, want) {
+		t.Errorf("in = %v, want %v", in, want)
+	}
+	if want := []int{2, 1, 0}; !reflect.DeepEqual(out, want) {
+		t.Errorf("out = %v, want %v", out, want)
+	}
+}
+
+func TestPageRank(t *testing.T) {
+	tests := []struct {
+		name  string
+		edges []string
+		// want is indexed like Nodes, i.e. in the order the edges name the nodes.
+		want []float64
+	}{
+		{"two-node cycle", []string{"a->b", "b->a"}, []float64{0.5, 0.5}},
+		{"three-node cycle", []string{"a->b", "b->c", "c->a"}, []float64{1.0 / 3, 1.0 / 3, 1.0 / 3}},
+		// b and c link to a, which links nowhere and so spreads its rank over every node:
+		// b = c = 0.05 + 0.85a/3 and a = b + 0.85(b+c), which solves to the values below.
+		{"dangling sink", []string{"b->a", "c->a"}, []float64{0.05 / 0.235, 2.7 * 0.05 / 0.235, 0.05 / 0.235}},
+		// a passes half its rank to each of b and c; c passes all of its rank back to a.
+		{"uneven out-degrees", []string{"a->b", "a->c", "b->c", "c->a"}, []float64{0.38778971, 0.21481063, 0.39739966}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := graphOf(tt.edges...).PageRank(0.85, 1000, 1e-12)
+			if !closeTo(got, tt.want) {
+				t.Errorf("PageRank() = %v, want %v", got, tt.want)
+			}
+			sum := 0.0
+			for _, r := range got {
+				sum += r
+			}
+			if math.Abs(sum-1) > 1e-9 {
+				t.Errorf("ranks sum to %v, want 1", sum)
+			}
+		})
+	}
+	if got := New().PageRank(0.85, 100, 1e-9); got != nil {
+		t.Errorf("PageRank() of an empty graph = %v, want nil", got)
+	}
+}
+
+func TestBetweenness(t *testing.T) {
+	tests := []struct {
+		name  string
+		edges []string
+		want  []float64
+	}{
+		{"too small", []string{"a->b"}, []float64{0, 0}},
+		// Of the two ordered pairs of other nodes, only a→c passes through b.
+		{"path", []string{"a->b", "b->c"}, []float64{0, 0.5, 0}},
+		// All 6 ordered pairs of leaves pass through the hub.
+		{"star", []string{"h->x", "x->h", "h->y", "y->h", "h->z", "z->h"}, []float64{1, 0, 0, 0}},
+		// a→d has two shortest paths, so b and c each carry half of it.
+		{"diamond", []string{"a->b", "a->c", "b->d", "c->d"}, []float64{0, 0.5 / 6, 0.5 / 6, 0}},
+		{"cycle", []string{"a->b", "b->c", "c->a"}, []float64{0.5, 0.5, 0.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := graphOf(tt.edges...)
+			if got := g.Betweenness(0); !closeTo(got, tt.want) {
+				t.Errorf("Betweenness(0) = %v, want %v", got, tt.want)
+			}
+			// Sampling every node is the exact computation.
+			if got := g.Betweenness(g.Len()); !closeTo(got, tt.want) {
+				t.Errorf("Betweenness(%d) = %v, want %v", g.Len(), got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+// Package graphalg holds the graph algorithms behind the reports: the project graph is
+// loaded from Neo4j once and analysed in memory, where the algorithms need no plugin and
+// run in linear time.
+package graphalg
+
+import "sort"
+
+// Graph is a directed graph over named nodes. Parallel edges are kept once.
+type Graph struct {
+	index map[string]int
+	names []string
+	succ  [][]int
+	edges map[[2]int]bool
+}
+
+// New returns an empty graph.
+func New() *Graph {
+	return &Graph{index: map[string]int{}, edges: map[[2]int]bool{}}
+}
+
+// AddNode adds a node, if it is not in the graph yet, and returns its index.
+func (g *Graph) AddNode(name string) int {
+	if i, ok := g.index[name]; ok {
+		return i
+	}
+	i := len(g.names)
+	g.index[name] = i
+	g.names = append(g.names, name)
+	g.succ = append(g.succ, nil)
+	return i
+}
+
+// AddEdge adds an edge from one node to another, adding the nodes as needed.
+func (g *Graph) AddEdge(from, to string) {
+	f, t := g.AddNode(from), g.AddNode(to)
+	if g.edges[[2]int{f, t}] {
+		return
+	}
+	g.edges[[2]int{f, t}] = true
+	g.succ[f] = append(g.succ[f], t)
+}
+
+// Len returns the number of nodes.
+func (g *Graph) Len() int {
+	return len(g.names)
+}
+
+// Nodes returns the node names in the order they were added.
+func (g *Graph) Nodes() []string {
+	return append([]string(nil), g.names...)
+}
+
+// HasEdge reports whether the graph has an edge from one node to another.
+func (g *Graph) HasEdge(from, to string) bool {
+	f, ok := g.index[from]
+	if !ok {
+		return false
+	}
+	t, ok := g.index[to]
+	return ok && g.edges[[2]int{f, t}]
+}
+
+// Successors returns the nodes the named node has edges to.
+func (g *Graph) Successors(name string) []string {
+	i, ok := g.index[name]
+	if !ok {
+		return nil
+	}
+	out := make([]string, len(g.succ[i]))
+	for j, t := range g.succ[i] {
+		out[j] = g.names[t]
+	}
+	return out
+}
+
+// StronglyConnectedComponents returns the strongly connected components with more than
+// one node, or a single node with an edge to itself: the sets of nodes that can all
+// reach one another, i.e. the cycles of the graph. Each component's members are sorted
+// and the components are ordered by size, largest first, then by their first member.
+func (g *Graph) StronglyConnectedComponents() [][]string {
+	// Tarjan's algorithm, iterative so deep graphs cannot exhaust the stack.
+	n := len(g.names)
+	index := make([]int, n)
+	low := make([]int, n)
+	onStack := make([]bool, n)
+	for i := range index {
+		index[i] = -1
+	}
+	var stack []int
+	var components [][]string
+	next := 0
+
+	type frame struct{ node, edge int }
+	for root := 0; root < n; root++ {
+		if index[root] != -1 {
+			continue
+		}
+		calls := []frame{{node: root}}
+		index[root], low[root] = next, next
+		next++
+		stack = append(stack, root)
+		onStack[root] = true
+
+		for len(calls) > 0 {
+			top := &calls[len(calls)-1]
+			v := top.node
+			if top.edge < len(g.succ[v]) {
+				w := g.succ[v][top.edge]
+				top.edge++
+				switch {
+				case index[w] == -1:
+					index[w], low[w] = next, next
+					next++
+					stack = append(stack, w)
+					onStack[w] = true
+					calls = append(calls, frame{node: w})
+				case onStack[w]:
+					low[v] = min(low[v], index[w])
+				}
+				continue
+			}
+
+			calls = calls[:len(calls)-1]
+			if len(calls) > 0 {
+				parent := calls[len(calls)-1].node
+				low[parent] = min(low[parent], low[v])
+			}
+			if low[v] != index[v] {
+				continue
+			}
+			var members []string
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				members = append(members, g.names[w])
+				if w == v {
+					break
+				}
+			}
+			if len(members) > 1 || g.edges[[2]int{v, v}] {
+				sort.Strings(members)
+				components = append(components, members)
+			}
+		}
+	}
+
+	sort.Slice(components, func(i, j int) bool {
+		if len(components[i]) != len(components[j]) {
+			return len(components[i]) > len(components[j])
+		}
+		return components[i][0] < components[j][0]
+	})
+	return components
+}
+
+// ShortestCycle returns a shortest cycle through start that stays within members, as the
+// nodes along it with start at both ends, or nil if there is none.
+func (g *Graph) ShortestCycle(start string, members []string) []string {
+	s, ok := g.index[start]
+	if !ok {
+		return nil
+	}
+	within := make(map[int]bool, len(members))
+	for _, m := range members {
+		if i, ok := g.index[m]; ok {
+			within[i] = true
+		}
+	}
+
+	// Breadth first from start until an edge leads back to it.
+	prev := map[int]int{s: -1}
+	queue := []int{s}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, w := range g.succ[v] {
+			if w == s {
+				cycle := []string{start}
+				for u := v; u != -1; u = prev[u] {
+					cycle = append(cycle, g.names[u])
+				}
+				// The walk back from v ends at start; reverse it into edge order.
+				for i, j := 0, len(cycle)-1; i < j; i, j = i+1, j-1 {
+					cycle[i], cycle[j] = cycle[j], cycle[i]
+				}
+				return cycle
+			}
+			if _, seen := prev[w]; !seen && within[w] {
+				prev[w] = v
+				queue = append(queue, w)
+			}
+		}
+	}
+	return nil
+}
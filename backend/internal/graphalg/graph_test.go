@@ -0,0 +1,108 @@
+package graphalg
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// graphOf builds a graph from "from->to" edges.
+func graphOf(edges ...string) *Graph {
+	g := New()
+	for _, e := range edges {
+		from, to, _ := strings.Cut(e, "->")
+		g.AddEdge(from, to)
+	}
+	return g
+}
+
+func TestStronglyConnectedComponents(t *testing.T) {
+	tests := []struct {
+		name  string
+		edges []string
+		want  [][]string
+	}{
+		{"empty", nil, nil},
+		{"acyclic", []string{"a->b", "b->c", "a->c"}, nil},
+		{"self-loop", []string{"a->a", "a->b"}, [][]string{{"a"}}},
+		{"two-node cycle", []string{"a->b", "b->a"}, [][]string{{"a", "b"}}},
+		{"self-loop inside a cycle", []string{"a->b", "b->a", "b->b"}, [][]string{{"a", "b"}}},
+		{
+			name:  "nested cycles form one component",
+			edges: []string{"a->b", "b->c", "c->a", "b->d", "d->b", "c->e"},
+			want:  [][]string{{"a", "b", "c", "d"}},
+		},
+		{
+			name:  "cycle inside a cycle through a chain",
+			edges: []string{"a->b", "b->c", "c->d", "d->a", "b->x", "x->y", "y->b", "d->z"},
+			want:  [][]string{{"a", "b", "c", "d", "x", "y"}},
+		},
+		{
+			name:  "largest first, then by first member",
+			edges: []string{"z->z", "x->y", "y->x", "c->d", "d->c", "p->q", "q->r", "r->p", "x->p"},
+			want:  [][]string{{"p", "q", "r"}, {"c", "d"}, {"x", "y"}, {"z"}},
+		},
+		{"cycles joined one way stay apart", []string{"a->b", "b->a", "b->c", "c->d", "d->c"}, [][]string{{"a", "b"}, {"c", "d"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := graphOf(tt.edges...).StronglyConnectedComponents()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StronglyConnectedComponents() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStronglyConnectedComponentsDeep(t *testing.T) {
+	// A ring this long would overflow a recursive implementation's stack.
+	const n = 200000
+	g := New()
+	for i := 0; i < n; i++ {
+		g.AddEdge(fmt.Sprint(i), fmt.Sprint((i+1)%n))
+	}
+	components := g.StronglyConnectedComponents()
+	if len(components) != 1 || len(components[0]) != n {
+		t.Fatalf("got %d components, want one of %d nodes", len(components), n)
+	}
+}
+
+func TestShortestCycle(t *testing.T) {
+	tests := []struct {
+		name    string
+		edges   []string
+		start   string
+		members []string
+		want    []string
+	}{
+		{"self-loop", []string{"a->a", "a->b", "b->a"}, "a", []string{"a", "b"}, []string{"a", "a"}},
+		{"in edge order", []string{"a->b", "b->c", "c->a"}, "a", []string{"a", "b", "c"}, []string{"a", "b", "c", "a"}},
+		{"from another start", []string{"a->b", "b->c", "c->a"}, "b", []string{"a", "b", "c"}, []string{"b", "c", "a", "b"}},
+		{
+			name:    "shortest of several",
+			edges:   []string{"a->b", "b->c", "c->d", "d->a", "a->x", "x->a"},
+			start:   "a",
+			members: []string{"a", "b", "c", "d", "x"},
+			want:    []string{"a", "x", "a"},
+		},
+		{
+			name:    "stays within members",
+			edges:   []string{"a->x", "x->a", "a->b", "b->c", "c->a"},
+			start:   "a",
+			members: []string{"a", "b", "c"},
+			want:    []string{"a", "b", "c", "a"},
+		},
+		{"no cycle", []string{"a->b", "b->c"}, "a", []string{"a", "b", "c"}, nil},
+		{"cycle not through start", []string{"a->b", "b->c", "c->b"}, "a", []string{"a", "b", "c"}, nil},
+		{"unknown start", []string{"a->a"}, "z", []string{"z"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := graphOf(tt.edges...).ShortestCycle(tt.start, tt.members)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ShortestCycle(%q) = %v, want %v", tt.start, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package graphalg
+
+import "sort"
+
+const (
+	// louvainMaxLevels caps how many times communities are merged into super-nodes.
+	louvainMaxLevels = 20
+	// louvainMaxPasses caps the sweeps over the nodes of one level.
+	louvainMaxPasses = 50
+	// louvainMinGain is the smallest modularity gain worth moving a node for; it keeps
+	// floating-point noise from moving nodes back and forth.
+	louvainMinGain = 1e-12
+)
+
+// WeightedGraph is an undirected graph with weighted edges over the nodes 0..n-1.
+type WeightedGraph struct {
+	adj  []map[int]float64
+	self []float64
+}
+
+// NewWeighted returns a weighted graph of n nodes without edges.
+func NewWeighted(n int) *WeightedGraph {
+	g := &WeightedGraph{adj: make([]map[int]float64, n), self: make([]float64, n)}
+	for i := range g.adj {
+		g.adj[i] = map[int]float64{}
+	}
+	return g
+}
+
+// Len returns the number of nodes.
+func (g *WeightedGraph) Len() int {
+	return len(g.adj)
+}
+
+// AddWeight adds w to the weight of the edge between a and b.
+func (g *WeightedGraph) AddWeight(a, b int, w float64) {
+	if a == b {
+		g.self[a] += w
+		return
+	}
+	g.adj[a][b] += w
+	g.adj[b][a] += w
+}
+
+// weightedEdge is an entry of a level's adjacency list.
+type weightedEdge struct {
+	to     int
+	weight float64
+}
+
+// louvainLevel is the graph one pass of the Louvain method works on: the input graph,
+// then graphs whose nodes are the communities of the level before.
+type louvainLevel struct {
+	// adj lists each node's neighbours in ascending order, so runs are deterministic.
+	adj  [][]weightedEdge
+	self []float64
+}
+
+// Louvain partitions the graph into communities by greedily maximising modularity with
+// the Louvain method: nodes move to the neighbouring community that gains the most, then
+// each community becomes a node of a smaller graph, until no move helps. It returns the
+// community of every node, numbered from 0 in order of each community's lowest node.
+func (g *WeightedGraph) Louvain() []int {
+	level := louvainLevel{adj: make([][]weightedEdge, len(g.adj)), self: append([]float64(nil), g.self...)}
+	for i, neighbours := range g.adj {
+		for j, w := range neighbours {
+			level.adj[i] = append(level.adj[i], weightedEdge{to: j, weight: w})
+		}
+		sort.Slice(level.adj[i], func(a, b int) bool { return level.adj[i][a].to < level.adj[i][b].to })
+	}
+
+	membership := make([]int, len(g.adj))
+	for i := range membership {
+		membership[i] = i
+	}
+	for l := 0; l < louvainMaxLevels; l++ {
+		community, moved := level.moveNodes()
+		if !moved {
+			break
+		}
+		community, count := renumber(community)
+		for i, c := range membership {
+			membership[i] = community[c]
+		}
+		level = level.aggregate(community, count)
+	}
+	membership, _ = renumber(membership)
+	return membership
+}
+
+// moveNodes sweeps the nodes, moving each to the neighbouring community with the best
+// modularity gain, until a sweep moves nothing. It reports whether any node moved.
+func (l louvainLevel) moveNodes() ([]int, bool) {
+	n := len(l.adj)
+	degree := make([]float64, n)
+	total := 0.0
+	for i := range l.adj {
+		degree[i] = 2 * l.self[i]
+		for _, e := range l.adj[i] {
+			degree[i] += e.weight
+		}
+		total += degree[i]
+	}
+	community := make([]int, n)
+	for i := range community {
+		community[i] = i
+	}
+	if total == 0 {
+		return community, false
+	}
+	// tot is the summed degree of each community's nodes.
+	tot := append([]float64(nil), degree...)
+
+	// links accumulates the weight from the current node to each neighbouring
+	// community; touched lists those communities in the order they were reached.
+	links := make([]float64, n)
+	var touched []int
+	moved := false
+	for pass := 0; pass < louvainMaxPasses; pass++ {
+		changes := 0
+		for i := 0; i < n; i++ {
+			current := community[i]
+			touched = touched[:0]
+			for _, e := range l.adj[i] {
+				c := community[e.to]
+				if links[c] == 0 {
+					touched = append(touched, c)
+				}
+				links[c] += e.weight
+			}
+
+			// Take the node out of its community, then put it where the gain is best,
+			// preferring its own community on ties.
+			tot[current] -= degree[i]
+			best, bestGain := current, links[current]-tot[current]*degree[i]/total
+			for _, c := range touched {
+				if gain := links[c] - tot[c]*degree[i]/total; gain > bestGain+louvainMinGain {
+					best, bestGain = c, gain
+				}
+			}
+			tot[best] += degree[i]
+			for _, c := range touched {
+				links[c] = 0
+			}
+			if best != current {
+				community[i] = best
+				changes++
+				moved = true
+			}
+		}
+		if changes == 0 {
+			break
+		}
+	}
+	return community, moved
+}
+
+// aggregate returns the graph whose nodes are the count communities of l: edges between
+// communities sum the edges between their nodes, and edges inside a community become a
+// self-loop.
+func (l louvainLevel) aggregate(community []int, count int) louvainLevel {
+	self := make([]float64, count)
+	weights := make([]map[int]float64, count)
+	for c := range weights {
+		weights[c] = map[int]float64{}
+	}
+	for i, neighbours := range l.adj {
+		ci := community[i]
+		self[ci] += l.self[i]
+		for _, e := range neighbours {
+			// Each undirected edge is listed from both ends; count it once.
+			if e.to < i {
+				continue
+			}
+			if cj := community[e.to]; cj == ci {
+				self[ci] += e.weight
+			} else {
+				weights[ci][cj] += e.weight
+				weights[cj][ci] += e.weight
+			}
+		}
+	}
+	next := louvainLevel{adj: make([][]weightedEdge, count), self: self}
+	for c, neighbours := range weights {
+		for d, w := range neighbours {
+			next.adj[c] = append(next.adj[c], weightedEdge{to: d, weight: w})
+		}
+		sort.Slice(next.adj[c], func(a, b int) bool { return next.adj[c][a].to < next.adj[c][b].to })
+	}
+	return next
+}
+
+// renumber maps community labels onto 0..count-1 in order of first appearance.
+func renumber(labels []int) ([]int, int) {
+	ids := map[int]int{}
+	out := make([]int, len(labels))
+	for i, label := range labels {
+		id, ok := ids[label]
+		if !ok {
+			id = len(ids)
+			ids[label] = id
+		}
+		out[i] = id
+	}
+	return out, len(ids)
+}
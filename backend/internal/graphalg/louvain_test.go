@@ -0,0 +1,109 @@
+package graphalg
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// weightedEdgeSpec is an edge of a test graph.
+type weightedEdgeSpec struct {
+	a, b int
+	w    float64
+}
+
+func TestLouvain(t *testing.T) {
+	tests := []struct {
+		name  string
+		n     int
+		edges []weightedEdgeSpec
+		want  []int
+	}{
+		{"no edges", 3, nil, []int{0, 1, 2}},
+		{
+			name:  "two triangles joined by one edge",
+			n:     6,
+			edges: []weightedEdgeSpec{{0, 1, 1}, {1, 2, 1}, {0, 2, 1}, {3, 4, 1}, {4, 5, 1}, {3, 5, 1}, {2, 3, 1}},
+			want:  []int{0, 0, 0, 1, 1, 1},
+		},
+		{
+			// Communities are numbered in order of their lowest node.
+			name:  "interleaved pairs",
+			n:     4,
+			edges: []weightedEdgeSpec{{0, 2, 5}, {1, 3, 5}, {0, 1, 0.1}},
+			want:  []int{0, 1, 0, 1},
+		},
+		{
+			name:  "weights decide",
+			n:     4,
+			edges: []weightedEdgeSpec{{0, 1, 1}, {1, 2, 10}, {2, 3, 1}, {3, 0, 10}},
+			want:  []int{0, 1, 1, 0},
+		},
+		{
+			name:  "self-loops",
+			n:     4,
+			edges: []weightedEdgeSpec{{0, 0, 3}, {0, 1, 2}, {2, 2, 3}, {2, 3, 2}, {1, 3, 0.1}},
+			want:  []int{0, 0, 1, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWeighted(tt.n)
+			for _, e := range tt.edges {
+				g.AddWeight(e.a, e.b, e.w)
+			}
+			if got := g.Louvain(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Louvain() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLouvainDeterministic(t *testing.T) {
+	// A ring of equal weights ties at every move, and cliques joined in a ring give the
+	// aggregation levels work to do; both must come out the same whatever order the
+	// edges are added in and however maps happen to iterate.
+	ring := []weightedEdgeSpec{}
+	for i := 0; i < 12; i++ {
+		ring = append(ring, weightedEdgeSpec{i, (i + 1) % 12, 1})
+	}
+	cliques := []weightedEdgeSpec{}
+	for c := 0; c < 5; c++ {
+		for i := 0; i < 4; i++ {
+			for j := i + 1; j < 4; j++ {
+				cliques = append(cliques, weightedEdgeSpec{c*4 + i, c*4 + j, 1})
+			}
+		}
+		cliques = append(cliques, weightedEdgeSpec{c*4 + 3, (c*4 + 4) % 20, 1})
+	}
+
+	tests := []struct {
+		name  string
+		n     int
+		edges []weightedEdgeSpec
+		want  []int
+	}{
+		{"ring", 12, ring, []int{0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2}},
+		{"cliques", 20, cliques, []int{0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 4, 4, 4, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			for run := 0; run < 20; run++ {
+				edges := append([]weightedEdgeSpec(nil), tt.edges...)
+				rng.Shuffle(len(edges), func(i, j int) { edges[i], edges[j] = edges[j], edges[i] })
+				g := NewWeighted(tt.n)
+				for _, e := range edges {
+					// Either end may come first; the graph is undirected.
+					if rng.Intn(2) == 0 {
+						e.a, e.b = e.b, e.a
+					}
+					g.AddWeight(e.a, e.b, e.w)
+				}
+				if got := g.Louvain(); !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("run %d: Louvain() = %v, want %v", run, got, tt.want)
+				}
+			}
+		})
+	}
+}
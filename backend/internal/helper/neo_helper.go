@@ -4,252 +4,470 @@ import (
 	"github.com/1107-adishjain/codemap/internal/models"
 	"context"
 	"fmt"
+	"path"
+	"strings"
 
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 )
 
-// Helper functions for the transaction
-func CreateNodesForFile(ctx context.Context, tx neo4j.ManagedTransaction, file models.File) error {
-	// Create File node
-	_, err := tx.Run(ctx, `
-        MERGE (f:File {path: $path})
-        ON CREATE SET f.language = $language
-    `, map[string]any{
-		"path":     file.Path,
-		"language": file.Language,
-	})
-	if err != nil {
-		return err
-	}
+// WriteCounts tallies what a batch of import statements created.
+type WriteCounts struct {
+	Nodes         int
+	Relationships int
+	Statements    int
+}
 
-	// Create Class and Property nodes
-	for _, class := range file.Classes {
-		classID := fmt.Sprintf("%s#%s", file.Path, class.Name)
-		_, err := tx.Run(ctx, `
-            MATCH (f:File {path: $filePath})
-            MERGE (c:Class {id: $classID})
-            ON CREATE SET c.name = $name, c.is_exported = $is_exported
-            MERGE (f)-[:CONTAINS]->(c)
-        `, map[string]any{
-			"filePath":    file.Path,
-			"classID":     classID,
-			"name":        class.Name,
-			"is_exported": class.IsExported,
+// Add accumulates other into c.
+func (c *WriteCounts) Add(other WriteCounts) {
+	c.Nodes += other.Nodes
+	c.Relationships += other.Relationships
+	c.Statements += other.Statements
+}
+
+// Node statements. Each takes $projectId and a $rows batch; Files and the top-level
+// symbols are also linked to the Project node, which must already exist. Files are
+// contained by their Directory, written before them, or by the Project at the root.
+const (
+	fileNodesQuery = `
+        MATCH (p:Project {id: $projectId})
+        UNWIND $rows AS row
+        MERGE (f:File {project_id: $projectId, path: row.path})
+        ON CREATE SET f.language = row.language
+        MERGE (f)-[:BELONGS_TO]->(p)
+        WITH p, f, row
+        OPTIONAL MATCH (d:Directory {project_id: $projectId, path: row.dir})
+        WITH f, coalesce(d, p) AS parent
+        MERGE (parent)-[:CONTAINS]->(f)
+    `
+	classNodesQuery = `
+        MATCH (p:Project {id: $projectId})
+        UNWIND $rows AS row
+        MATCH (f:File {project_id: $projectId, path: row.filePath})
+        MERGE (c:Class {project_id: $projectId, id: row.id})
+        ON CREATE SET c.name = row.name, c.is_exported = row.is_exported
+        SET c += row.location
+        MERGE (f)-[:CONTAINS]->(c)
+        MERGE (c)-[:BELONGS_TO]->(p)
+    `
+	propertyNodesQuery = `
+        UNWIND $rows AS row
+        MATCH (c:Class {project_id: $projectId, id: row.classID})
+        MERGE (p:Property {project_id: $projectId, id: row.id})
+        ON CREATE SET p.name = row.name
+        MERGE (c)-[:HAS_PROPERTY]->(p)
+    `
+	functionNodesQuery = `
+        MATCH (p:Project {id: $projectId})
+        UNWIND $rows AS row
+        MATCH (f:File {project_id: $projectId, path: row.filePath})
+        MERGE (fn:Function {project_id: $projectId, id: row.id})
+        ON CREATE SET
+            fn.name = row.name,
+            fn.is_exported = row.is_exported,
+            fn.is_method_of = row.is_method_of,
+            fn.return_types = row.return_types,
+            fn.param_count = row.param_count
+        SET fn += row.location
+        MERGE (f)-[:CONTAINS]->(fn)
+        MERGE (fn)-[:BELONGS_TO]->(p)
+    `
+	parameterNodesQuery = `
+        UNWIND $rows AS row
+        MATCH (fn:Function {project_id: $projectId, id: row.funcID})
+        MERGE (p:Parameter {project_id: $projectId, id: row.id})
+        ON CREATE SET
+            p.name = row.name,
+            p.position = row.position
+        SET p += row.location
+        MERGE (fn)-[:HAS_PARAMETER]->(p)
+    `
+	importNodesQuery = `
+        UNWIND $rows AS row
+        MATCH (f:File {project_id: $projectId, path: row.filePath})
+        MERGE (imp:Import {project_id: $projectId, id: row.id})
+        ON CREATE SET
+            imp.source = row.source,
+            imp.from_file = row.filePath
+        SET imp += row.location
+        MERGE (f)-[:HAS_IMPORT]->(imp)
+    `
+)
+
+// Relationship statements, run once every node of the project exists.
+const (
+	// Imports arrive resolved to the paths of the files they refer to. Those that
+	// resolve to none depend on something outside the tree, or on a file that is missing.
+	importsQuery = `
+        UNWIND $rows AS row
+        MATCH (importer:File {project_id: $projectId, path: row.path})
+        OPTIONAL MATCH (imported:File {project_id: $projectId}) WHERE imported.path IN row.targets
+        WITH importer, row, collect(imported) AS matches
+        FOREACH (f IN matches |
+            MERGE (importer)-[r:IMPORTS {
+                source: row.source,
+                import_type: 'internal',
+                resolved: true
+            }]->(f)
+            SET r += row.location
+        )
+        FOREACH (x IN CASE WHEN size(matches) = 0 THEN [1] ELSE [] END |
+            MERGE (ext:ExternalDependency {project_id: $projectId, name: row.source})
+            ON CREATE SET ext.type = row.dependencyType
+            MERGE (importer)-[r:DEPENDS_ON {
+                source: row.source,
+                import_type: row.importType,
+                resolved: false
+            }]->(ext)
+            SET r += row.location
+        )
+    `
+	hasMethodQuery = `
+        UNWIND $rows AS row
+        MATCH (c:Class {project_id: $projectId, id: row.classID})
+        MATCH (fn:Function {project_id: $projectId, id: row.methodID})
+        MERGE (c)-[:HAS_METHOD {method_name: row.methodName}]->(fn)
+    `
+	ownsMethodQuery = `
+        UNWIND $rows AS row
+        MATCH (c:Class {project_id: $projectId, id: row.classID})
+        MATCH (fn:Function {project_id: $projectId, id: row.funcID})
+        MERGE (c)-[:OWNS_METHOD]->(fn)
+        SET fn.is_method = true
+    `
+	// Calls arrive resolved before import. Those bound to a single function become CALLS
+	// edges; those that may target several, or whose target was not found, become
+	// MAY_CALL edges to the candidates or to an UnresolvedCall node for the call as
+	// written. Both carry how confident the resolution is.
+	resolvedCallsQuery = `
+        UNWIND $rows AS row
+        MATCH (caller:Function {project_id: $projectId, id: row.callerID})
+        MATCH (callee:Function {project_id: $projectId, id: row.calleeID})
+        MERGE (caller)-[r:CALLS {
+            function_name: row.calleeName,
+            call_order: row.callOrder,
+            call_type: CASE WHEN callee.is_method_of IS NOT NULL AND callee.is_method_of <> '' THEN 'method' ELSE 'function' END,
+            resolved: true
+        }]->(callee)
+        SET r += row.location, r.call_lines = row.callLines, r.call = row.call,
+            r.confidence = row.confidence, r.resolution = row.resolution
+    `
+	ambiguousCallsQuery = `
+        UNWIND $rows AS row
+        MATCH (caller:Function {project_id: $projectId, id: row.callerID})
+        MATCH (callee:Function {project_id: $projectId, id: row.calleeID})
+        MERGE (caller)-[r:MAY_CALL {call: row.call}]->(callee)
+        SET r += row.location, r.call_lines = row.callLines, r.function_name = row.calleeName,
+            r.confidence = row.confidence, r.resolution = row.resolution, r.candidates = row.candidates
+    `
+	unresolvedCallsQuery = `
+        UNWIND $rows AS row
+        MATCH (caller:Function {project_id: $projectId, id: row.callerID})
+        MERGE (u:UnresolvedCall {project_id: $projectId, name: row.call})
+        MERGE (caller)-[r:MAY_CALL {call: row.call}]->(u)
+        SET r += row.location, r.call_lines = row.callLines,
+            r.confidence = 0.0, r.resolution = 'unresolved', r.candidates = 0
+    `
+	// Create return type relationships for type analysis
+	returnsQuery = `
+        UNWIND $rows AS row
+        MATCH (fn:Function {project_id: $projectId, id: row.funcID})
+        MERGE (rt:ReturnType {project_id: $projectId, name: row.returnType})
+        MERGE (fn)-[:RETURNS]->(rt)
+    `
+)
+
+// CreateNodes writes the File, Class, Property, Function, Parameter and Import nodes of
+// files, sending one UNWIND statement per entity type and at most batchSize rows each.
+func CreateNodes(ctx context.Context, tx neo4j.ManagedTransaction, projectID string, files []models.File, batchSize int) (WriteCounts, error) {
+	var fileRows, classRows, propertyRows, functionRows, paramRows, importRows []map[string]any
+	for _, file := range files {
+		fileRows = append(fileRows, map[string]any{
+			"path":     file.Path,
+			"language": file.Language,
+			"dir":      path.Dir(file.Path),
 		})
-		if err != nil {
-			return err
-		}
 
-		for _, propName := range class.Properties {
-			propID := fmt.Sprintf("%s::%s", classID, propName)
-			_, err := tx.Run(ctx, `
-                MATCH (c:Class {id: $classID})
-                MERGE (p:Property {id: $propID})
-                ON CREATE SET p.name = $name
-                MERGE (c)-[:HAS_PROPERTY]->(p)
-            `, map[string]any{
-				"classID": classID,
-				"propID":  propID,
-				"name":    propName,
+		for _, class := range file.Classes {
+			classID := fmt.Sprintf("%s#%s", file.Path, class.Name)
+			classRows = append(classRows, map[string]any{
+				"filePath":    file.Path,
+				"id":          classID,
+				"name":        class.Name,
+				"is_exported": class.IsExported,
+				"location":    locationProps(class.Location),
 			})
-			if err != nil {
-				return err
+			for _, propName := range class.Properties {
+				propertyRows = append(propertyRows, map[string]any{
+					"classID": classID,
+					"id":      fmt.Sprintf("%s::%s", classID, propName),
+					"name":    propName,
+				})
 			}
 		}
-	}
-
-	// Create Function and Parameter nodes with enhanced properties
-	for _, function := range file.Functions {
-		funcID := fmt.Sprintf("%s#%s", file.Path, function.Name)
-		_, err := tx.Run(ctx, `
-            MATCH (f:File {path: $filePath})
-            MERGE (fn:Function {id: $funcID})
-            ON CREATE SET 
-                fn.name = $name, 
-                fn.is_exported = $is_exported, 
-                fn.is_method_of = $is_method_of,
-                fn.return_types = $return_types,
-                fn.param_count = $param_count
-            MERGE (f)-[:CONTAINS]->(fn)
-        `, map[string]any{
-			"filePath":     file.Path,
-			"funcID":       funcID,
-			"name":         function.Name,
-			"is_exported":  function.IsExported,
-			"is_method_of": function.IsMethodOf,
-			"return_types": function.ReturnTypes,
-			"param_count":  len(function.Params),
-		})
-		if err != nil {
-			return err
-		}
 
-		// Create Parameter nodes with enhanced information
-		for i, paramName := range function.Params {
-			paramID := fmt.Sprintf("%s(%s)", funcID, paramName)
-			_, err := tx.Run(ctx, `
-                MATCH (fn:Function {id: $funcID})
-                MERGE (p:Parameter {id: $paramID})
-                ON CREATE SET 
-                    p.name = $name,
-                    p.position = $position
-                MERGE (fn)-[:HAS_PARAMETER]->(p)
-            `, map[string]any{
-				"funcID":   funcID,
-				"paramID":  paramID,
-				"name":     paramName,
-				"position": i + 1,
+		for _, function := range file.Functions {
+			funcID := functionID(file.Path, function.IsMethodOf, function.Name)
+			functionRows = append(functionRows, map[string]any{
+				"filePath":     file.Path,
+				"id":           funcID,
+				"name":         function.Name,
+				"is_exported":  function.IsExported,
+				"is_method_of": receiverName(function.IsMethodOf),
+				"return_types": function.ReturnTypes,
+				"param_count":  len(function.Params),
+				"location":     locationProps(function.Location),
 			})
-			if err != nil {
-				return err
+			for i, paramName := range function.Params {
+				var paramLocation *models.Location
+				if i < len(function.ParamLocations) {
+					paramLocation = &function.ParamLocations[i]
+				}
+				paramRows = append(paramRows, map[string]any{
+					"funcID":   funcID,
+					"id":       fmt.Sprintf("%s(%s)", funcID, paramName),
+					"name":     paramName,
+					"position": i + 1,
+					"location": locationProps(paramLocation),
+				})
 			}
 		}
-	}
 
-	// Create Import nodes and relationships between files
-	for _, importItem := range file.Imports {
-		importID := fmt.Sprintf("%s->%s", file.Path, importItem.Source)
-		_, err := tx.Run(ctx, `
-            MATCH (f:File {path: $filePath})
-            MERGE (imp:Import {id: $importID})
-            ON CREATE SET 
-                imp.source = $source,
-                imp.from_file = $filePath
-            MERGE (f)-[:HAS_IMPORT]->(imp)
-        `, map[string]any{
-			"filePath": file.Path,
-			"importID": importID,
-			"source":   importItem.Source,
-		})
-		if err != nil {
-			return err
+		for _, importItem := range file.Imports {
+			importRows = append(importRows, map[string]any{
+				"filePath": file.Path,
+				"id":       fmt.Sprintf("%s->%s", file.Path, importItem.Source),
+				"source":   importItem.Source,
+				"location": locationProps(importItem.Location),
+			})
 		}
 	}
-	return nil
+
+	pkgRows, moduleRows := packageRows(files)
+
+	// Parents go first: every statement MATCHes the nodes written by the ones before it.
+	return runBatches(ctx, tx, projectID, batchSize, []batch{
+		{directoryNodesQuery, directoryRows(files)},
+		{fileNodesQuery, fileRows},
+		{packageNodesQuery, pkgRows},
+		{moduleNodesQuery, moduleRows},
+		{classNodesQuery, classRows},
+		{propertyNodesQuery, propertyRows},
+		{functionNodesQuery, functionRows},
+		{parameterNodesQuery, paramRows},
+		{importNodesQuery, importRows},
+	})
 }
 
-func CreateRelationshipsForFile(ctx context.Context, tx neo4j.ManagedTransaction, file models.File) error {
-	// Create enhanced IMPORTS relationships between files
-	for _, imp := range file.Imports {
-		if imp.Source != "" {
-			_, err := tx.Run(ctx, `
-                MATCH (importer:File {path: $importerPath})
-                // Try to find exact file matches first, then partial matches
-                OPTIONAL MATCH (imported:File) WHERE imported.path ENDS WITH $importSource OR imported.path CONTAINS $importSource
-                WITH importer, imported, $importSource as source
-                FOREACH (f IN CASE WHEN imported IS NOT NULL THEN [imported] ELSE [] END |
-                    MERGE (importer)-[:IMPORTS {
-                        source: source, 
-                        import_type: 'internal',
-                        resolved: true
-                    }]->(f)
-                )
-                // Create external dependency node for unresolved imports (libraries, etc.)
-                FOREACH (x IN CASE WHEN imported IS NULL THEN [1] ELSE [] END |
-                    MERGE (ext:ExternalDependency {name: source, type: 'library'})
-                    MERGE (importer)-[:DEPENDS_ON {
-                        source: source, 
-                        import_type: 'external',
-                        resolved: false
-                    }]->(ext)
-                )
-            `, map[string]any{
-				"importerPath": file.Path,
-				"importSource": imp.Source,
-			})
-			if err != nil {
-				fmt.Printf("Warning: Could not create import relationship: %v\n", err)
+// CreateRelationships writes the IMPORTS/DEPENDS_ON, HAS_METHOD, OWNS_METHOD, CALLS/MAY_CALL
+// and RETURNS relationships of files, whose imports and calls must already be resolved.
+// Every node of the project must already exist.
+func CreateRelationships(ctx context.Context, tx neo4j.ManagedTransaction, projectID string, files []models.File, batchSize int) (WriteCounts, error) {
+	var importRows, hasMethodRows, ownsMethodRows, resolvedRows, ambiguousRows, unresolvedRows, returnRows []map[string]any
+	for _, file := range files {
+		for _, imp := range file.Imports {
+			if imp.Source != "" {
+				importType, dependencyType := "external", "library"
+				if imp.Missing {
+					importType, dependencyType = "missing", "missing"
+				}
+				importRows = append(importRows, map[string]any{
+					"path":           file.Path,
+					"source":         imp.Source,
+					"targets":        imp.Resolved,
+					"importType":     importType,
+					"dependencyType": dependencyType,
+					"location":       locationProps(imp.Location),
+				})
 			}
 		}
-	}
 
-	// Create enhanced class-method relationships
-	for _, class := range file.Classes {
-		classID := fmt.Sprintf("%s#%s", file.Path, class.Name)
-
-		// Create direct relationships to class methods from class.Methods array
-		for _, methodName := range class.Methods {
-			methodID := fmt.Sprintf("%s#%s", file.Path, methodName)
-			_, err := tx.Run(ctx, `
-                MATCH (c:Class {id: $classID})
-                MATCH (fn:Function {id: $methodID})
-                MERGE (c)-[:HAS_METHOD {method_name: $methodName}]->(fn)
-            `, map[string]any{
-				"classID":    classID,
-				"methodID":   methodID,
-				"methodName": methodName,
-			})
-			if err != nil {
-				fmt.Printf("Warning: Could not create class-method relationship: %v\n", err)
+		for _, class := range file.Classes {
+			classID := fmt.Sprintf("%s#%s", file.Path, class.Name)
+			for _, methodName := range class.Methods {
+				hasMethodRows = append(hasMethodRows, map[string]any{
+					"classID":    classID,
+					"methodID":   functionID(file.Path, class.Name, methodName),
+					"methodName": methodName,
+				})
 			}
 		}
-	}
 
-	// Create enhanced function relationships
-	for _, function := range file.Functions {
-		funcID := fmt.Sprintf("%s#%s", file.Path, function.Name)
-
-		// Method ownership relationships
-		if function.IsMethodOf != "" {
-			classID := fmt.Sprintf("%s#%s", file.Path, function.IsMethodOf)
-			_, err := tx.Run(ctx, `
-                MATCH (c:Class {id: $classID})
-                MATCH (fn:Function {id: $funcID})
-                MERGE (c)-[:OWNS_METHOD]->(fn)
-                SET fn.is_method = true
-            `, map[string]any{
-				"classID": classID,
-				"funcID":  funcID,
-			})
-			if err != nil {
-				fmt.Printf("Warning: Could not create method ownership: %v\n", err)
+		for _, function := range file.Functions {
+			funcID := functionID(file.Path, function.IsMethodOf, function.Name)
+
+			if function.IsMethodOf != "" {
+				ownsMethodRows = append(ownsMethodRows, map[string]any{
+					"classID": fmt.Sprintf("%s#%s", file.Path, receiverName(function.IsMethodOf)),
+					"funcID":  funcID,
+				})
+			}
+
+			for i, call := range function.ResolvedCalls {
+				// Analyzers that bind calls by type leave the confidence unset.
+				confidence, resolution := call.Confidence, call.Resolution
+				if resolution == "" {
+					confidence, resolution = 1.0, "analyzer"
+				}
+				resolvedRows = append(resolvedRows, map[string]any{
+					"callerID":   funcID,
+					"calleeID":   functionID(call.File, call.Class, call.Name),
+					"calleeName": call.Name,
+					"call":       callText(call),
+					"callOrder":  i + 1,
+					"confidence": confidence,
+					"resolution": resolution,
+					"location":   locationProps(firstSite(call.Sites)),
+					"callLines":  siteLines(call.Sites),
+				})
+			}
+
+			candidates := map[string]int{}
+			for _, call := range function.AmbiguousCalls {
+				candidates[callText(call)]++
+			}
+			for _, call := range function.AmbiguousCalls {
+				ambiguousRows = append(ambiguousRows, map[string]any{
+					"callerID":   funcID,
+					"calleeID":   functionID(call.File, call.Class, call.Name),
+					"calleeName": call.Name,
+					"call":       callText(call),
+					"candidates": candidates[callText(call)],
+					"confidence": call.Confidence,
+					"resolution": call.Resolution,
+					"location":   locationProps(firstSite(call.Sites)),
+					"callLines":  siteLines(call.Sites),
+				})
+			}
+
+			for _, call := range function.UnresolvedCalls {
+				unresolvedRows = append(unresolvedRows, map[string]any{
+					"callerID":  funcID,
+					"call":      call.Call,
+					"location":  locationProps(firstSite(call.Sites)),
+					"callLines": siteLines(call.Sites),
+				})
+			}
+
+			for _, returnType := range function.ReturnTypes {
+				if returnType != "" && returnType != "void" && returnType != "any" {
+					returnRows = append(returnRows, map[string]any{
+						"funcID":     funcID,
+						"returnType": returnType,
+					})
+				}
 			}
 		}
+	}
 
-		// Enhanced function call relationships
-		for i, calledFuncName := range function.Calls {
-			_, err := tx.Run(ctx, `
-                MATCH (caller:Function {id: $callerID})
-                // Try to find called function in same file first, then globally
-                OPTIONAL MATCH (callee_same_file:Function) 
-                WHERE callee_same_file.id STARTS WITH $sameFilePrefix AND callee_same_file.name = $calleeName
-                OPTIONAL MATCH (callee_global:Function {name: $calleeName})
-                WHERE callee_same_file IS NULL
-                WITH caller, COALESCE(callee_same_file, callee_global) as callee, $calleeName as funcName
-                FOREACH (f IN CASE WHEN callee IS NOT NULL THEN [callee] ELSE [] END |
-                    MERGE (caller)-[:CALLS {
-                        function_name: funcName, 
-                        call_order: $callOrder,
-                        call_type: CASE WHEN callee.is_method_of IS NOT NULL THEN 'method' ELSE 'function' END
-                    }]->(f)
-                )
-            `, map[string]any{
-				"callerID":       funcID,
-				"calleeName":     calledFuncName,
-				"callOrder":      i + 1,
-				"sameFilePrefix": file.Path + "#",
+	return runBatches(ctx, tx, projectID, batchSize, []batch{
+		{importsQuery, importRows},
+		{hasMethodQuery, hasMethodRows},
+		{ownsMethodQuery, ownsMethodRows},
+		{resolvedCallsQuery, resolvedRows},
+		{ambiguousCallsQuery, ambiguousRows},
+		{unresolvedCallsQuery, unresolvedRows},
+		{returnsQuery, returnRows},
+	})
+}
+
+// batch pairs an UNWIND statement with the rows to feed it.
+type batch struct {
+	query string
+	rows  []map[string]any
+}
+
+// runBatches runs each statement over its rows in chunks of at most batchSize.
+func runBatches(ctx context.Context, tx neo4j.ManagedTransaction, projectID string, batchSize int, batches []batch) (WriteCounts, error) {
+	var counts WriteCounts
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	for _, b := range batches {
+		for start := 0; start < len(b.rows); start += batchSize {
+			end := min(start+batchSize, len(b.rows))
+			result, err := tx.Run(ctx, b.query, map[string]any{
+				"projectId": projectID,
+				"rows":      b.rows[start:end],
 			})
 			if err != nil {
-				fmt.Printf("Warning: Could not create CALLS relationship from %s to %s: %v\n", funcID, calledFuncName, err)
+				return counts, err
+			}
+			summary, err := result.Consume(ctx)
+			if err != nil {
+				return counts, err
 			}
+			counts.Nodes += summary.Counters().NodesCreated()
+			counts.Relationships += summary.Counters().RelationshipsCreated()
+			counts.Statements++
 		}
+	}
+	return counts, nil
+}
 
-		// Create return type relationships for type analysis
-		for _, returnType := range function.ReturnTypes {
-			if returnType != "" && returnType != "void" && returnType != "any" {
-				_, err := tx.Run(ctx, `
-                    MATCH (fn:Function {id: $funcID})
-                    MERGE (rt:ReturnType {name: $returnType})
-                    MERGE (fn)-[:RETURNS]->(rt)
-                `, map[string]any{
-					"funcID":     funcID,
-					"returnType": returnType,
-				})
-				if err != nil {
-					fmt.Printf("Warning: Could not create return type relationship: %v\n", err)
-				}
-			}
+// locationProps maps a source location onto the start_line, start_column, end_line
+// and end_column properties. Without a location the properties are null, which
+// removes any position stored by an earlier import.
+func locationProps(loc *models.Location) map[string]any {
+	if loc == nil {
+		return map[string]any{"start_line": nil, "start_column": nil, "end_line": nil, "end_column": nil}
+	}
+	return map[string]any{
+		"start_line":   loc.StartLine,
+		"start_column": loc.StartColumn,
+		"end_line":     loc.EndLine,
+		"end_column":   loc.EndColumn,
+	}
+}
+
+// functionID returns the node ID of the function name declared in the file at path,
+// qualified by the class or receiver type it is a method of so that methods of the same
+// name on different types stay distinct.
+func functionID(path, class, name string) string {
+	if class = receiverName(class); class != "" {
+		return fmt.Sprintf("%s#%s.%s", path, class, name)
+	}
+	return fmt.Sprintf("%s#%s", path, name)
+}
+
+// receiverName reduces a method's receiver type to the type name its Class node is keyed
+// by, stripping pointers, parentheses and type parameters: *Server, (*Server) and
+// Stack[T] become Server and Stack.
+func receiverName(class string) string {
+	class = strings.Trim(class, "*() \t")
+	if i := strings.IndexByte(class, '['); i >= 0 {
+		class = strings.TrimRight(class[:i], " \t")
+	}
+	return class
+}
+
+// callText returns a call as it was written.
+func callText(call models.ResolvedCall) string {
+	if call.Call != "" {
+		return call.Call
+	}
+	return call.Name
+}
+
+// firstSite returns the earliest call site, which CALLS relationships use as their location.
+func firstSite(sites []models.Location) *models.Location {
+	if len(sites) == 0 {
+		return nil
+	}
+	first := &sites[0]
+	for i := range sites {
+		if sites[i].StartLine < first.StartLine || (sites[i].StartLine == first.StartLine && sites[i].StartColumn < first.StartColumn) {
+			first = &sites[i]
 		}
 	}
-	return nil
+	return first
+}
+
+// siteLines lists the line of every call site, so a relationship covering several
+// calls to the same function still points at all of them.
+func siteLines(sites []models.Location) []int {
+	lines := make([]int, 0, len(sites))
+	for _, site := range sites {
+		lines = append(lines, site.StartLine)
+	}
+	return lines
 }
@@ -0,0 +1,25 @@
+package helper
+
+import "testing"
+
+func TestFunctionID(t *testing.T) {
+	tests := []struct {
+		name  string
+		class string
+		want  string
+	}{
+		{"function", "", "a.go#Run"},
+		{"value receiver", "Server", "a.go#Server.Run"},
+		{"pointer receiver", "*Server", "a.go#Server.Run"},
+		{"parenthesised pointer receiver", "(*Server)", "a.go#Server.Run"},
+		{"generic receiver", "Stack[T]", "a.go#Stack.Run"},
+		{"generic pointer receiver", "*Pair[K, V]", "a.go#Pair.Run"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := functionID("a.go", tt.class, "Run"); got != tt.want {
+				t.Errorf("functionID(%q) = %q, want %q", tt.class, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package helper
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/1107-adishjain/codemap/internal/models"
+)
+
+// Tree statements. Directories form a CONTAINS tree below the Project node, with each
+// File hanging off its directory (or the Project, at the root). Packages and modules
+// group files by what their language considers a unit, independent of that tree.
+const (
+	directoryNodesQuery = `
+        MATCH (p:Project {id: $projectId})
+        UNWIND $rows AS row
+        MERGE (d:Directory {project_id: $projectId, path: row.path})
+        ON CREATE SET d.name = row.name
+        WITH p, d, row
+        OPTIONAL MATCH (up:Directory {project_id: $projectId, path: row.parent})
+        WITH d, coalesce(up, p) AS parent
+        MERGE (parent)-[:CONTAINS]->(d)
+    `
+	packageNodesQuery = `
+        UNWIND $rows AS row
+        MATCH (f:File {project_id: $projectId, path: row.filePath})
+        MERGE (pkg:Package {project_id: $projectId, id: row.id})
+        ON CREATE SET pkg.name = row.name, pkg.language = row.language
+        MERGE (pkg)-[:CONTAINS]->(f)
+    `
+	moduleNodesQuery = `
+        UNWIND $rows AS row
+        MATCH (pkg:Package {project_id: $projectId, id: row.packageID})
+        MERGE (m:Module {project_id: $projectId, id: row.id})
+        ON CREATE SET m.name = row.name, m.language = row.language
+        MERGE (m)-[:CONTAINS]->(pkg)
+    `
+)
+
+// directoryRows returns a row for every directory above the files, parents before their
+// children so each directory can be linked to the one above it.
+func directoryRows(files []models.File) []map[string]any {
+	seen := map[string]bool{}
+	var dirs []string
+	for _, file := range files {
+		for dir := path.Dir(file.Path); dir != "." && dir != "/" && !seen[dir]; dir = path.Dir(dir) {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if di, dj := strings.Count(dirs[i], "/"), strings.Count(dirs[j], "/"); di != dj {
+			return di < dj
+		}
+		return dirs[i] < dirs[j]
+	})
+	rows := make([]map[string]any, 0, len(dirs))
+	for _, dir := range dirs {
+		rows = append(rows, map[string]any{
+			"path":   dir,
+			"name":   path.Base(dir),
+			"parent": path.Dir(dir),
+		})
+	}
+	return rows
+}
+
+// packageName returns the package a file belongs to, or "" when its language has no
+// packages or the file is outside any. Go, Java and Kotlin files report the package they
+// declare; Python packages follow the directory layout, so they are named after it.
+func packageName(file models.File) string {
+	if file.Package != "" {
+		return file.Package
+	}
+	if file.Language == "python" {
+		if dir := path.Dir(file.Path); dir != "." && dir != "/" {
+			return strings.ReplaceAll(strings.Trim(dir, "/"), "/", ".")
+		}
+	}
+	return ""
+}
+
+// packageRows returns the Package and Module rows of the files. Package and module IDs
+// are prefixed with the language, since e.g. a Java and a Python package may share a name.
+func packageRows(files []models.File) (packages, modules []map[string]any) {
+	seen := map[string]bool{}
+	for _, file := range files {
+		name := packageName(file)
+		if name == "" {
+			continue
+		}
+		id := file.Language + ":" + name
+		packages = append(packages, map[string]any{
+			"filePath": file.Path,
+			"id":       id,
+			"name":     name,
+			"language": file.Language,
+		})
+		if file.Module != "" && !seen[id] {
+			seen[id] = true
+			modules = append(modules, map[string]any{
+				"packageID": id,
+				"id":        file.Language + ":" + file.Module,
+				"name":      file.Module,
+				"language":  file.Language,
+			})
+		}
+	}
+	return packages, modules
+}
@@ -0,0 +1,287 @@
+package imports
+
+import (
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/1107-adishjain/codemap/internal/models"
+)
+
+// Confidence of each rule that binds a call. A call that several functions could
+// satisfy shares its rule's confidence among them.
+const (
+	// A method called on this or self, or a function of the calling file.
+	confidenceLocal = 1.0
+	// A method called on a class in scope.
+	confidenceClass = 0.95
+	// A function of an imported file or of the same package.
+	confidenceImport = 0.9
+	// A method called on a receiver of unknown type: any method of that name in scope.
+	confidenceReceiver = 0.5
+)
+
+// minResolvedConfidence is the confidence below which even a call with a single
+// candidate is recorded as ambiguous.
+const minResolvedConfidence = confidenceImport
+
+// maxCallCandidates caps the functions an ambiguous call is recorded against; calls
+// matching more are recorded as unresolved.
+const maxCallCandidates = 10
+
+// identifierPattern matches the name of a function.
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// function is a function of the tree, as the call resolver knows it.
+type function struct {
+	name  string
+	class string
+}
+
+// callScope is what a function can call into: its own file, its package and the files
+// its file imports, with the aliases those imports are known by.
+type callScope struct {
+	file     string
+	class    string
+	pkg      []string
+	imported []string
+	// aliases maps the names imports are known by to their files; nil files mark an
+	// import from outside the tree.
+	aliases map[string][]string
+	// implicitThis is set for languages whose methods call the methods of their class
+	// by bare name.
+	implicitThis bool
+	// typed is set for files whose calls an analyzer already bound from type
+	// information: what it left unbound is not in the tree.
+	typed bool
+}
+
+// addFunctions indexes the functions and classes of a file for call resolution.
+func (r *Resolver) addFunctions(file models.File) {
+	for _, fn := range file.Functions {
+		r.functions[file.Path] = append(r.functions[file.Path], function{name: fn.Name, class: fn.IsMethodOf})
+	}
+	for _, class := range file.Classes {
+		r.classes[file.Path] = append(r.classes[file.Path], class.Name)
+	}
+}
+
+// resolveCalls binds the Calls of every function of file, whose imports must already be
+// resolved, to functions of the tree. Calls go through the class of the calling method
+// for this and self, the classes in scope for static calls, and the resolved imports of
+// the file and its package for the rest. Calls with one target become ResolvedCalls,
+// calls with several become AmbiguousCalls, and calls with none that aren't made into
+// an import from outside the tree become UnresolvedCalls. Nothing is matched by name
+// across the whole tree.
+func (r *Resolver) resolveCalls(file *models.File) {
+	scope := r.callScope(file)
+	for i := range file.Functions {
+		fn := &file.Functions[i]
+		scope.class = fn.IsMethodOf
+		for _, call := range fn.Calls {
+			var sites []models.Location
+			for _, site := range fn.CallSites {
+				if site.Name == call {
+					sites = append(sites, site.Location)
+				}
+			}
+			targets, confidence, resolution, external := r.resolveCall(scope, call)
+			switch {
+			case external:
+			case len(targets) == 0 || len(targets) > maxCallCandidates:
+				fn.UnresolvedCalls = append(fn.UnresolvedCalls, models.UnresolvedCall{Call: call, Sites: sites})
+			case len(targets) == 1 && confidence >= minResolvedConfidence:
+				fn.ResolvedCalls = append(fn.ResolvedCalls, targets[0].call(call, sites, confidence, resolution))
+			default:
+				share := confidence / float64(len(targets))
+				for _, target := range targets {
+					fn.AmbiguousCalls = append(fn.AmbiguousCalls, target.call(call, sites, share, resolution))
+				}
+			}
+		}
+	}
+}
+
+// callTarget is a function a call may target.
+type callTarget struct {
+	file  string
+	class string
+	name  string
+}
+
+func (t callTarget) call(call string, sites []models.Location, confidence float64, resolution string) models.ResolvedCall {
+	out := models.ResolvedCall{Name: t.name, Class: t.class, File: t.file, Sites: sites, Confidence: confidence, Resolution: resolution}
+	if call != t.name {
+		out.Call = call
+	}
+	return out
+}
+
+// callScope returns the scope of the functions of file.
+func (r *Resolver) callScope(file *models.File) callScope {
+	scope := callScope{
+		file:    file.Path,
+		aliases: make(map[string][]string),
+		typed:   file.Language == "go" && file.Package != "",
+	}
+	switch path.Ext(file.Path) {
+	case ".go":
+		scope.pkg = r.goPackages[file.Package]
+	case ".java", ".kt", ".kts":
+		scope.pkg = r.jvmPackages[file.Package]
+		scope.implicitThis = true
+	case ".c", ".cc", ".cpp", ".h", ".hpp", ".dart":
+		scope.implicitThis = true
+	}
+	for _, imp := range file.Imports {
+		for _, target := range imp.Resolved {
+			if target != file.Path && !slices.Contains(scope.imported, target) {
+				scope.imported = append(scope.imported, target)
+			}
+		}
+		if alias := importAlias(imp.Source); alias != "" {
+			scope.aliases[alias] = append(scope.aliases[alias], imp.Resolved...)
+		}
+	}
+	return scope
+}
+
+// resolveCall returns the functions a call may target, with the confidence and the
+// name of the rule that found them. external reports a call into an import from
+// outside the tree, which is not recorded.
+func (r *Resolver) resolveCall(scope callScope, call string) (targets []callTarget, confidence float64, resolution string, external bool) {
+	receiver, name := splitCall(call)
+	if name == "" {
+		return nil, 0, "", false
+	}
+	if files, ok := scope.aliases[receiver]; ok && receiver != "" && len(files) == 0 {
+		return nil, 0, "", true
+	}
+	if scope.typed {
+		return nil, 0, "", false
+	}
+	local := []string{scope.file}
+
+	switch receiver {
+	case "":
+		// Implicit this, in the languages that have it, then the functions of the file,
+		// its package and its imports.
+		if scope.class != "" && scope.implicitThis {
+			if targets := r.find(local, name, scope.class); len(targets) > 0 {
+				return targets, confidenceLocal, "class", false
+			}
+		}
+		if targets := r.find(local, name, ""); len(targets) > 0 {
+			return targets, confidenceLocal, "file", false
+		}
+		if targets := r.find(scope.pkg, name, ""); len(targets) > 0 {
+			return targets, confidenceImport, "package", false
+		}
+		if targets := r.find(scope.imported, name, ""); len(targets) > 0 {
+			return targets, confidenceImport, "import", false
+		}
+		return nil, 0, "", false
+	case "this", "self", "cls":
+		if scope.class == "" {
+			return nil, 0, "", false
+		}
+		return r.find(local, name, scope.class), confidenceLocal, "class", false
+	case "super":
+		// The base class is not known.
+		return nil, 0, "", false
+	}
+
+	if identifierPattern.MatchString(receiver) {
+		// A static call on a class in scope.
+		for _, files := range [][]string{local, scope.pkg, scope.imported} {
+			if files := r.declaring(files, receiver); len(files) > 0 {
+				return r.find(files, name, receiver), confidenceClass, "class", false
+			}
+		}
+		// A function of an imported module, called through the module's name. What the
+		// module exports may also be an object, which the rule below covers.
+		if files, ok := scope.aliases[receiver]; ok {
+			if targets := r.find(files, name, ""); len(targets) > 0 {
+				return targets, confidenceImport, "import", false
+			}
+		}
+	}
+
+	// A method of an object whose type is unknown.
+	files := append(append(local, scope.pkg...), scope.imported...)
+	return r.find(files, name, "*"), confidenceReceiver, "receiver", false
+}
+
+// find returns the functions called name in files: the methods of class, any method
+// when class is "*", or the functions outside classes when class is "".
+func (r *Resolver) find(files []string, name, class string) []callTarget {
+	var out []callTarget
+	for _, file := range files {
+		for _, fn := range r.functions[file] {
+			if fn.name != name {
+				continue
+			}
+			// Overloads share a node, so they are one target.
+			target := callTarget{file: file, class: fn.class, name: name}
+			if ((class == "*" && fn.class != "") || fn.class == class) && !slices.Contains(out, target) {
+				out = append(out, target)
+			}
+		}
+	}
+	return out
+}
+
+// declaring returns the files among files that declare a class called name.
+func (r *Resolver) declaring(files []string, name string) []string {
+	var out []string
+	for _, file := range files {
+		if slices.Contains(r.classes[file], name) {
+			out = append(out, file)
+		}
+	}
+	return out
+}
+
+// splitCall splits a call as the extractors write it into the expression it is called
+// on, if any, and the name of the function: "utils.format" into "utils" and "format",
+// "a.b(x).c" into "a.b(x)" and "c". The name is "" when it is not an identifier, as
+// for an immediately invoked function.
+func splitCall(call string) (receiver, name string) {
+	call = strings.Join(strings.Fields(call), "")
+	call = strings.NewReplacer("?.", ".", "!!.", ".", "::", ".", "->", ".").Replace(call)
+	// Type arguments, as in foo<T>() or foo[T]().
+	if i := strings.LastIndexAny(call, ".)"); strings.ContainsAny(call[i+1:], "<[") {
+		call = call[:i+1+strings.IndexAny(call[i+1:], "<[")]
+	}
+	if i := strings.LastIndex(call, "."); i >= 0 {
+		receiver, name = call[:i], call[i+1:]
+	} else {
+		name = call
+	}
+	if !identifierPattern.MatchString(name) {
+		return "", ""
+	}
+	return receiver, name
+}
+
+// importAlias returns the name an import is referred to by in code, as a guess from its
+// source: the alias of "import a.b as c", else the last element of its path or dotted
+// name, without extension.
+func importAlias(source string) string {
+	if _, alias, ok := strings.Cut(source, " as "); ok {
+		return strings.TrimSpace(alias)
+	}
+	source = strings.Trim(source, "\"'`<>")
+	if strings.HasSuffix(source, ".*") {
+		return ""
+	}
+	name := source[strings.LastIndexAny(source, "/.:")+1:]
+	if ext := path.Ext(source); ext != "" && strings.HasSuffix(source, ext) && strings.Contains(source, "/") {
+		name = strings.TrimSuffix(path.Base(source), ext)
+	}
+	if !identifierPattern.MatchString(name) {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,181 @@
+package imports
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/1107-adishjain/codemap/internal/models"
+)
+
+// callTree returns the files of a small tree for call resolution, keyed by path.
+// src/app.js imports src/lib/b.js, src/lib/c.js and a package; src/lib/unused.js is
+// imported by nothing.
+func callTree() map[string]models.File {
+	fn := func(name, class string) models.Function {
+		return models.Function{Name: name, IsMethodOf: class}
+	}
+	c := models.File{
+		Path:      "src/lib/c.js",
+		Language:  "javascript",
+		Functions: []models.Function{fn("format", ""), fn("process", "Other"), fn("process", "Third"), fn("only", "Other")},
+		Classes:   []models.Class{{Name: "Other"}, {Name: "Third"}},
+	}
+	// More methods of one name than an ambiguous call is recorded against.
+	for i := 0; i <= maxCallCandidates; i++ {
+		c.Functions = append(c.Functions, fn("many", fmt.Sprintf("K%d", i)))
+	}
+	files := []models.File{
+		{
+			Path:      "src/app.js",
+			Language:  "javascript",
+			Functions: []models.Function{fn("helper", ""), fn("render", "Widget")},
+			Classes:   []models.Class{{Name: "Widget"}},
+			Imports:   []models.Import{{Source: "./lib/b"}, {Source: "./lib/c"}, {Source: "lodash"}},
+		},
+		{
+			Path:      "src/lib/b.js",
+			Language:  "javascript",
+			Functions: []models.Function{fn("format", ""), fn("parse", "Parser")},
+			Classes:   []models.Class{{Name: "Parser"}},
+		},
+		c,
+		{
+			Path:      "src/lib/unused.js",
+			Language:  "javascript",
+			Functions: []models.Function{fn("far", "")},
+		},
+		{
+			Path:      "src/com/acme/App.java",
+			Language:  "java",
+			Package:   "com.acme",
+			Functions: []models.Function{fn("start", "App")},
+			Classes:   []models.Class{{Name: "App"}},
+		},
+		{
+			Path:      "src/com/acme/Util.java",
+			Language:  "java",
+			Package:   "com.acme",
+			Functions: []models.Function{fn("assist", "Util")},
+			Classes:   []models.Class{{Name: "Util"}},
+		},
+		{
+			Path:      "main.go",
+			Language:  "go",
+			Package:   "example.com/app",
+			Functions: []models.Function{fn("helper", "")},
+		},
+	}
+	out := make(map[string]models.File, len(files))
+	for _, f := range files {
+		out[f.Path] = f
+	}
+	return out
+}
+
+func TestResolveCalls(t *testing.T) {
+	type call = models.ResolvedCall
+	tests := []struct {
+		name string
+		// file is the file of the calling function, and class the class it is a method of.
+		file, class string
+		call        string
+		resolved    []call
+		ambiguous   []call
+		unresolved  bool
+	}{
+		{
+			name: "function of the file", file: "src/app.js", call: "helper",
+			resolved: []call{{Name: "helper", File: "src/app.js", Confidence: confidenceLocal, Resolution: "file"}},
+		},
+		{
+			name: "this", file: "src/app.js", class: "Widget", call: "this.render",
+			resolved: []call{{Name: "render", File: "src/app.js", Class: "Widget", Call: "this.render", Confidence: confidenceLocal, Resolution: "class"}},
+		},
+		{
+			name: "optional chaining on this", file: "src/app.js", class: "Widget", call: "this?.render",
+			resolved: []call{{Name: "render", File: "src/app.js", Class: "Widget", Call: "this?.render", Confidence: confidenceLocal, Resolution: "class"}},
+		},
+		{
+			name: "implicit this", file: "src/com/acme/App.java", class: "App", call: "start",
+			resolved: []call{{Name: "start", File: "src/com/acme/App.java", Class: "App", Confidence: confidenceLocal, Resolution: "class"}},
+		},
+		{
+			name: "static call on an imported class", file: "src/app.js", call: "Parser.parse",
+			resolved: []call{{Name: "parse", File: "src/lib/b.js", Class: "Parser", Call: "Parser.parse", Confidence: confidenceClass, Resolution: "class"}},
+		},
+		{
+			name: "static call on a class of the package", file: "src/com/acme/App.java", class: "App", call: "Util.assist",
+			resolved: []call{{Name: "assist", File: "src/com/acme/Util.java", Class: "Util", Call: "Util.assist", Confidence: confidenceClass, Resolution: "class"}},
+		},
+		{
+			name: "through a module alias", file: "src/app.js", call: "b.format",
+			resolved: []call{{Name: "format", File: "src/lib/b.js", Call: "b.format", Confidence: confidenceImport, Resolution: "import"}},
+		},
+		{
+			name: "several imports", file: "src/app.js", call: "format",
+			ambiguous: []call{
+				{Name: "format", File: "src/lib/b.js", Confidence: confidenceImport / 2, Resolution: "import"},
+				{Name: "format", File: "src/lib/c.js", Confidence: confidenceImport / 2, Resolution: "import"},
+			},
+		},
+		{
+			name: "receiver of unknown type", file: "src/app.js", call: "obj.process",
+			ambiguous: []call{
+				{Name: "process", File: "src/lib/c.js", Class: "Other", Call: "obj.process", Confidence: confidenceReceiver / 2, Resolution: "receiver"},
+				{Name: "process", File: "src/lib/c.js", Class: "Third", Call: "obj.process", Confidence: confidenceReceiver / 2, Resolution: "receiver"},
+			},
+		},
+		{
+			name: "single candidate of an unknown receiver", file: "src/app.js", call: "obj.only",
+			ambiguous: []call{{Name: "only", File: "src/lib/c.js", Class: "Other", Call: "obj.only", Confidence: confidenceReceiver, Resolution: "receiver"}},
+		},
+		{name: "too many candidates", file: "src/app.js", call: "obj.many", unresolved: true},
+		{name: "this outside a class", file: "src/app.js", call: "this.render", unresolved: true},
+		{name: "super", file: "src/app.js", class: "Widget", call: "super.render", unresolved: true},
+		{name: "function of a file not imported", file: "src/app.js", call: "far", unresolved: true},
+		{name: "no such function", file: "src/app.js", call: "nowhere", unresolved: true},
+		{name: "call into a package", file: "src/app.js", call: "lodash.map"},
+		{name: "typed file", file: "main.go", call: "helper", unresolved: true},
+	}
+	sites := []models.Location{{StartLine: 7, StartColumn: 3, EndLine: 7, EndColumn: 12}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := callTree()
+			caller := files[tt.file]
+			caller.Functions = append(caller.Functions, models.Function{
+				Name:       "caller",
+				IsMethodOf: tt.class,
+				Calls:      []string{tt.call},
+				CallSites:  []models.CallSite{{Name: tt.call, Location: sites[0]}},
+			})
+			files[tt.file] = caller
+
+			r := NewResolver("")
+			for _, f := range files {
+				r.Add(f)
+			}
+			r.ResolveFile(&caller)
+			got := caller.Functions[len(caller.Functions)-1]
+
+			for _, want := range [][]call{tt.resolved, tt.ambiguous} {
+				for i := range want {
+					want[i].Sites = sites
+				}
+			}
+			if !reflect.DeepEqual(got.ResolvedCalls, tt.resolved) {
+				t.Errorf("ResolvedCalls = %+v, want %+v", got.ResolvedCalls, tt.resolved)
+			}
+			if !reflect.DeepEqual(got.AmbiguousCalls, tt.ambiguous) {
+				t.Errorf("AmbiguousCalls = %+v, want %+v", got.AmbiguousCalls, tt.ambiguous)
+			}
+			var want []models.UnresolvedCall
+			if tt.unresolved {
+				want = []models.UnresolvedCall{{Call: tt.call, Sites: sites}}
+			}
+			if !reflect.DeepEqual(got.UnresolvedCalls, want) {
+				t.Errorf("UnresolvedCalls = %+v, want %+v", got.UnresolvedCalls, want)
+			}
+		})
+	}
+}
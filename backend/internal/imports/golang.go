@@ -0,0 +1,80 @@
+package imports
+
+import (
+	"path"
+	"strings"
+
+	"github.com/1107-adishjain/codemap/internal/analysis"
+	"github.com/1107-adishjain/codemap/internal/models"
+)
+
+// goModule is the module a directory belongs to.
+type goModule struct {
+	// dir is the repo-relative directory of the go.mod; "" when there is none.
+	dir  string
+	path string
+}
+
+// addGo indexes a Go file under the import path of its package: the one the analyzer
+// reported, or else the one its directory has in the module of the nearest go.mod.
+// Test files are left out, since no other package can import them.
+func (r *Resolver) addGo(file models.File) {
+	if file.Module != "" {
+		r.goModules[file.Module] = true
+	}
+	if strings.HasSuffix(file.Path, "_test.go") {
+		return
+	}
+	importPath := file.Package
+	if importPath == "" {
+		dir := path.Dir(file.Path)
+		mod := r.goModule(dir)
+		if mod.path == "" {
+			return
+		}
+		importPath = mod.path
+		if dir != mod.dir {
+			importPath += "/" + strings.TrimPrefix(dir, mod.dir+"/")
+		}
+	}
+	r.goPackages[importPath] = append(r.goPackages[importPath], file.Path)
+}
+
+// resolveGo resolves a Go import path to the files of the package. Import paths inside
+// a module of the tree that match no package are missing; all others are external.
+func (r *Resolver) resolveGo(source string) ([]string, bool) {
+	source = strings.Trim(source, "\"`")
+	if files, ok := r.goPackages[source]; ok {
+		return sorted(files), false
+	}
+	for mod := range r.goModules {
+		if source == mod || strings.HasPrefix(source, mod+"/") {
+			return nil, true
+		}
+	}
+	return nil, false
+}
+
+// goModule returns the module declared by the nearest go.mod at or above dir.
+func (r *Resolver) goModule(dir string) goModule {
+	if mod, ok := r.goModDirs[dir]; ok {
+		return mod
+	}
+	var mod goModule
+	if modPath := r.readModulePath(path.Join(dir, "go.mod")); modPath != "" {
+		mod = goModule{dir: dir, path: modPath}
+		r.goModules[modPath] = true
+	} else if dir != "." {
+		mod = r.goModule(path.Dir(dir))
+	}
+	r.goModDirs[dir] = mod
+	return mod
+}
+
+// readModulePath returns the module path of the go.mod file at the repo-relative path p.
+func (r *Resolver) readModulePath(p string) string {
+	if r.root == "" {
+		return ""
+	}
+	return analysis.ReadModulePath(r.diskPath(p))
+}
@@ -0,0 +1,250 @@
+package imports
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path"
+	"strings"
+)
+
+// jsExtensions are tried, in order, on module paths without a file extension, as
+// TypeScript's bundler resolution does.
+var jsExtensions = []string{".ts", ".tsx", ".d.ts", ".js", ".jsx", ".mjs", ".cjs"}
+
+// maxTSConfigExtends bounds the chain of tsconfig files followed through "extends".
+const maxTSConfigExtends = 8
+
+// tsConfig holds the module resolution options of a tsconfig.json or jsconfig.json.
+type tsConfig struct {
+	// baseURL is the repo-relative directory non-relative imports are resolved from, if
+	// hasBaseURL is set.
+	baseURL    string
+	hasBaseURL bool
+	// paths maps import patterns to their substitutions, which are relative to pathsDir.
+	paths    map[string][]string
+	pathsDir string
+}
+
+// resolveJS resolves a JavaScript or TypeScript import. Relative imports are resolved
+// from the importing file, trying the extensions of jsExtensions and index files of
+// directories. Other imports go through the paths and baseUrl of the nearest tsconfig.json
+// or jsconfig.json; what they don't match is a package outside the tree.
+func (r *Resolver) resolveJS(from, source string) ([]string, bool) {
+	if isRelative(source) || strings.HasPrefix(source, "/") {
+		base := join(path.Dir(from), source)
+		if strings.HasPrefix(source, "/") {
+			base = path.Clean(strings.TrimPrefix(source, "/"))
+		}
+		if file, ok := r.jsFile(base); ok {
+			return []string{file}, false
+		}
+		return nil, true
+	}
+	if cfg := r.tsConfig(path.Dir(from)); cfg != nil {
+		for _, candidate := range cfg.candidates(source) {
+			if file, ok := r.jsFile(candidate); ok {
+				return []string{file}, false
+			}
+		}
+	}
+	return nil, false
+}
+
+// jsFile finds the file a module path refers to: the path itself, the path with one of
+// jsExtensions (also in place of a .js extension, which TypeScript allows for .ts
+// sources), or the index file of the directory at the path.
+func (r *Resolver) jsFile(base string) (string, bool) {
+	if base == "" {
+		return "", false
+	}
+	if r.files[base] {
+		return base, true
+	}
+	stem := base
+	switch path.Ext(base) {
+	case ".js", ".jsx", ".mjs", ".cjs":
+		stem = strings.TrimSuffix(base, path.Ext(base))
+	}
+	for _, ext := range jsExtensions {
+		if r.files[stem+ext] {
+			return stem + ext, true
+		}
+	}
+	for _, ext := range jsExtensions {
+		if index := path.Join(base, "index"+ext); r.files[index] {
+			return index, true
+		}
+	}
+	return "", false
+}
+
+// candidates returns the module paths a non-relative import maps to: the substitutions of
+// the paths pattern with the longest matching prefix, then the import under baseUrl.
+func (c *tsConfig) candidates(source string) []string {
+	var out []string
+	subs, capture := c.paths[source], ""
+	if subs == nil {
+		best := -1
+		for pattern, patternSubs := range c.paths {
+			prefix, suffix, wildcard := strings.Cut(pattern, "*")
+			if !wildcard || len(prefix) <= best || len(source) < len(prefix)+len(suffix) ||
+				!strings.HasPrefix(source, prefix) || !strings.HasSuffix(source, suffix) {
+				continue
+			}
+			best, subs, capture = len(prefix), patternSubs, source[len(prefix):len(source)-len(suffix)]
+		}
+	}
+	for _, sub := range subs {
+		out = append(out, join(c.pathsDir, strings.Replace(sub, "*", capture, 1)))
+	}
+	if c.hasBaseURL {
+		out = append(out, join(c.baseURL, source))
+	}
+	return out
+}
+
+// tsConfig returns the configuration of the nearest tsconfig.json or jsconfig.json at or
+// above dir, or nil if there is none.
+func (r *Resolver) tsConfig(dir string) *tsConfig {
+	if r.root == "" {
+		return nil
+	}
+	if cfg, ok := r.tsConfigs[dir]; ok {
+		return cfg
+	}
+	var cfg *tsConfig
+	for _, name := range []string{"tsconfig.json", "jsconfig.json"} {
+		if cfg = r.loadTSConfig(path.Join(dir, name), 0); cfg != nil {
+			break
+		}
+	}
+	if cfg == nil && dir != "." {
+		cfg = r.tsConfig(path.Dir(dir))
+	}
+	r.tsConfigs[dir] = cfg
+	return cfg
+}
+
+// loadTSConfig reads the tsconfig file at the repo-relative path p, merged over the
+// configurations it extends. It returns nil if the file can't be read or parsed.
+func (r *Resolver) loadTSConfig(p string, depth int) *tsConfig {
+	data, err := os.ReadFile(r.diskPath(p))
+	if err != nil {
+		return nil
+	}
+	var raw struct {
+		Extends         any `json:"extends"`
+		CompilerOptions struct {
+			BaseURL *string             `json:"baseUrl"`
+			Paths   map[string][]string `json:"paths"`
+		} `json:"compilerOptions"`
+	}
+	if err := json.Unmarshal(stripJSONC(data), &raw); err != nil {
+		return nil
+	}
+
+	cfg := &tsConfig{}
+	dir := path.Dir(p)
+	// Only configurations extended by relative path are in the tree.
+	var parents []string
+	switch extends := raw.Extends.(type) {
+	case string:
+		parents = []string{extends}
+	case []any:
+		for _, e := range extends {
+			if s, ok := e.(string); ok {
+				parents = append(parents, s)
+			}
+		}
+	}
+	for _, parent := range parents {
+		if !isRelative(parent) || depth >= maxTSConfigExtends {
+			continue
+		}
+		if !strings.HasSuffix(parent, ".json") {
+			parent += ".json"
+		}
+		if base := r.loadTSConfig(join(dir, parent), depth+1); base != nil {
+			*cfg = *base
+		}
+	}
+
+	if raw.CompilerOptions.BaseURL != nil {
+		cfg.baseURL, cfg.hasBaseURL = join(dir, *raw.CompilerOptions.BaseURL), true
+	}
+	if raw.CompilerOptions.Paths != nil {
+		cfg.paths, cfg.pathsDir = raw.CompilerOptions.Paths, dir
+	}
+	// Paths are relative to baseUrl when there is one, else to the file declaring them.
+	if cfg.hasBaseURL {
+		cfg.pathsDir = cfg.baseURL
+	}
+	return cfg
+}
+
+// stripJSONC turns the JSON with comments and trailing commas that tsconfig files allow
+// into plain JSON.
+func stripJSONC(data []byte) []byte {
+	var out bytes.Buffer
+	scanJSON(data, func(i int, inString bool) int {
+		switch {
+		case inString:
+		case data[i] == '/' && i+1 < len(data) && data[i+1] == '/':
+			end := bytes.IndexByte(data[i:], '\n')
+			if end < 0 {
+				return len(data)
+			}
+			return i + end
+		case data[i] == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				return len(data)
+			}
+			return i + end + 4
+		}
+		out.WriteByte(data[i])
+		return i + 1
+	})
+
+	// With the comments gone, drop commas that only whitespace separates from a
+	// closing bracket.
+	data = out.Bytes()
+	var clean bytes.Buffer
+	scanJSON(data, func(i int, inString bool) int {
+		if !inString && data[i] == ',' {
+			rest := bytes.TrimLeft(data[i+1:], " \t\r\n")
+			if len(rest) > 0 && (rest[0] == '}' || rest[0] == ']') {
+				return i + 1
+			}
+		}
+		clean.WriteByte(data[i])
+		return i + 1
+	})
+	return clean.Bytes()
+}
+
+// scanJSON walks data, telling visit for each position whether it is inside a string
+// literal (including its closing quote); visit returns the position to continue at.
+func scanJSON(data []byte, visit func(i int, inString bool) int) {
+	inString, escaped := false, false
+	for i := 0; i < len(data); {
+		c := data[i]
+		wasString := inString
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+		}
+		next := visit(i, wasString)
+		if !wasString && c == '"' && next == i+1 {
+			inString = true
+		}
+		i = next
+	}
+}
@@ -0,0 +1,67 @@
+package imports
+
+import (
+	"path"
+	"strings"
+
+	"github.com/1107-adishjain/codemap/internal/models"
+)
+
+// addJVM indexes a Java or Kotlin file under its declared package, along with the
+// qualified names of its classes and of the file itself, which is what a Java file's
+// public class is named after.
+func (r *Resolver) addJVM(file models.File) {
+	if file.Package == "" {
+		return
+	}
+	r.jvmPackages[file.Package] = append(r.jvmPackages[file.Package], file.Path)
+	stem := strings.TrimSuffix(path.Base(file.Path), path.Ext(file.Path))
+	for _, name := range append([]string{stem}, classNames(file)...) {
+		qualified := file.Package + "." + name
+		if _, ok := r.jvmClasses[qualified]; !ok {
+			r.jvmClasses[qualified] = file.Path
+		}
+	}
+}
+
+// resolveJVM resolves a Java or Kotlin import of a class, of a static member or nested
+// class of one, or of a whole package with ".*". Sources without a declared package
+// fall back to the conventional directory layout, if exactly one file matches it.
+func (r *Resolver) resolveJVM(source string) ([]string, bool) {
+	source = strings.TrimSuffix(source, ";")
+	name, wildcard := strings.CutSuffix(source, ".*")
+	if !wildcard {
+		// A class, or the class of a static member or nested class.
+		for i, candidate := 0, name; i < 2; i++ {
+			if file, ok := r.jvmClasses[candidate]; ok {
+				return []string{file}, false
+			}
+			cut := strings.LastIndex(candidate, ".")
+			if cut < 0 {
+				break
+			}
+			candidate = candidate[:cut]
+		}
+	}
+	if files, ok := r.jvmPackages[name]; ok {
+		return sorted(files), false
+	}
+	if !wildcard {
+		layout := strings.ReplaceAll(name, ".", "/")
+		for _, ext := range []string{".java", ".kt"} {
+			if file, ok := r.uniqueSuffix(layout + ext); ok {
+				return []string{file}, false
+			}
+		}
+	}
+	return nil, false
+}
+
+// classNames returns the names of the classes a file declares.
+func classNames(file models.File) []string {
+	names := make([]string, 0, len(file.Classes))
+	for _, class := range file.Classes {
+		names = append(names, class.Name)
+	}
+	return names
+}
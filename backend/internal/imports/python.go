@@ -0,0 +1,59 @@
+package imports
+
+import (
+	"path"
+	"strings"
+)
+
+// resolvePython resolves a Python import. Relative imports (from . or ..) are resolved
+// from the importing file's package. Absolute ones are looked up from the directories
+// Python would search for the importing file: each ancestor directory that is not itself
+// a regular package, nearest first, up to the root of the tree. Modules found in none of
+// them come from the standard library or installed packages.
+func (r *Resolver) resolvePython(from, source string) ([]string, bool) {
+	// The analyser reports "import a.b as c" with its alias.
+	source, _, _ = strings.Cut(source, " ")
+	dir := path.Dir(from)
+	if strings.HasPrefix(source, ".") {
+		module := strings.TrimLeft(source, ".")
+		for up := len(source) - len(module); up > 1; up-- {
+			if dir == "." {
+				return nil, true
+			}
+			dir = path.Dir(dir)
+		}
+		if file, ok := r.pyModule(dir, module); ok {
+			return []string{file}, false
+		}
+		// "from . import name" also works in namespace packages, which have no file.
+		return nil, module != ""
+	}
+	for root := dir; ; root = path.Dir(root) {
+		if !r.pyPackages[root] || root == "." {
+			if file, ok := r.pyModule(root, source); ok {
+				return []string{file}, false
+			}
+		}
+		if root == "." {
+			return nil, false
+		}
+	}
+}
+
+// pyModule finds the file of the dotted module name under dir: a module file or the
+// __init__.py of a package. An empty name is the package at dir itself.
+func (r *Resolver) pyModule(dir, module string) (string, bool) {
+	base := dir
+	var candidates []string
+	if module != "" {
+		base = path.Join(dir, strings.ReplaceAll(module, ".", "/"))
+		candidates = append(candidates, base+".py")
+	}
+	candidates = append(candidates, path.Join(base, "__init__.py"))
+	for _, candidate := range candidates {
+		if r.files[candidate] {
+			return candidate, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,229 @@
+// Package imports resolves the import statements of analysed files to the files of the
+// same tree they refer to, following the module rules of each language, and then the
+// calls of those files through what they import, so the graph never has to guess an
+// import's or a call's target from its text.
+package imports
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/1107-adishjain/codemap/internal/models"
+)
+
+// Resolver resolves imports against the files added to it. Every file of the tree must be
+// added before any import is resolved. Configuration the languages keep outside their
+// sources, such as go.mod and tsconfig.json files, is read from the tree on disk.
+type Resolver struct {
+	// root is the analysed tree on disk, or "" when it is not available; imports are
+	// then resolved from the files alone.
+	root  string
+	files map[string]bool
+	// byBase lists the files by base name, for lookups by path suffix.
+	byBase map[string][]string
+
+	// goPackages holds the non-test files of each Go package by import path, and
+	// goModules the module paths of the tree.
+	goPackages map[string][]string
+	goModules  map[string]bool
+	goModDirs  map[string]goModule
+
+	// jvmPackages holds the Java and Kotlin files of each package, and jvmClasses the
+	// file declaring each fully qualified class (or file-level type) name.
+	jvmPackages map[string][]string
+	jvmClasses  map[string]string
+
+	// pyPackages holds the directories that are regular Python packages.
+	pyPackages map[string]bool
+
+	// functions and classes hold what each file declares, for call resolution.
+	functions map[string][]function
+	classes   map[string][]string
+
+	tsConfigs map[string]*tsConfig
+}
+
+// NewResolver returns a resolver for the tree at root, which may be "".
+func NewResolver(root string) *Resolver {
+	return &Resolver{
+		root:        root,
+		files:       make(map[string]bool),
+		byBase:      make(map[string][]string),
+		goPackages:  make(map[string][]string),
+		goModules:   make(map[string]bool),
+		goModDirs:   make(map[string]goModule),
+		jvmPackages: make(map[string][]string),
+		jvmClasses:  make(map[string]string),
+		pyPackages:  make(map[string]bool),
+		functions:   make(map[string][]function),
+		classes:     make(map[string][]string),
+		tsConfigs:   make(map[string]*tsConfig),
+	}
+}
+
+// Add indexes a file of the tree.
+func (r *Resolver) Add(file models.File) {
+	if r.files[file.Path] {
+		return
+	}
+	r.files[file.Path] = true
+	base := path.Base(file.Path)
+	r.byBase[base] = append(r.byBase[base], file.Path)
+	r.addFunctions(file)
+
+	switch path.Ext(file.Path) {
+	case ".go":
+		r.addGo(file)
+	case ".java", ".kt", ".kts":
+		r.addJVM(file)
+	case ".py":
+		if base == "__init__.py" {
+			r.pyPackages[path.Dir(file.Path)] = true
+		}
+	}
+}
+
+// ResolveFile resolves every import of file in place, setting its Resolved files or
+// marking it Missing, then binds the calls of its functions; see resolveCalls.
+func (r *Resolver) ResolveFile(file *models.File) {
+	for i := range file.Imports {
+		imp := &file.Imports[i]
+		imp.Resolved, imp.Missing = r.Resolve(file.Path, imp.Source)
+	}
+	r.resolveCalls(file)
+}
+
+// Resolve returns the files an import of source in the file at from refers to: a single
+// file, or every file of an imported Go package or Java wildcard import. It returns no
+// files for imports outside the tree, such as the standard library or third-party
+// packages; missing reports an import that names a file of the tree, e.g. by a relative
+// path, which does not exist.
+func (r *Resolver) Resolve(from, source string) (files []string, missing bool) {
+	source = strings.TrimSpace(source)
+	if source == "" {
+		return nil, false
+	}
+	switch path.Ext(from) {
+	case ".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx":
+		return r.resolveJS(from, source)
+	case ".py":
+		return r.resolvePython(from, source)
+	case ".go":
+		return r.resolveGo(source)
+	case ".java", ".kt", ".kts":
+		return r.resolveJVM(source)
+	case ".c", ".cc", ".cpp", ".h", ".hpp":
+		return r.resolveInclude(from, source)
+	case ".dart":
+		return r.resolveDart(from, source)
+	default:
+		return r.resolveRelative(from, source)
+	}
+}
+
+// resolveInclude resolves a C or C++ #include. Quoted includes are looked up next to the
+// including file, then from the root of the tree, then as the unique file ending in the
+// include's path, which covers include directories. Angle-bracket includes are system
+// headers.
+func (r *Resolver) resolveInclude(from, source string) ([]string, bool) {
+	if !strings.HasPrefix(source, `"`) {
+		return nil, false
+	}
+	name := strings.Trim(source, `"`)
+	for _, candidate := range []string{join(path.Dir(from), name), path.Clean(name)} {
+		if r.files[candidate] {
+			return []string{candidate}, false
+		}
+	}
+	if file, ok := r.uniqueSuffix(name); ok {
+		return []string{file}, false
+	}
+	return nil, true
+}
+
+// resolveDart resolves a Dart import: relative URIs from the importing file, and
+// package: URIs to the lib directory of a package in the tree. dart: libraries and
+// packages that are not in the tree are external.
+func (r *Resolver) resolveDart(from, source string) ([]string, bool) {
+	if rest, ok := strings.CutPrefix(source, "package:"); ok {
+		name, file, _ := strings.Cut(rest, "/")
+		for _, suffix := range []string{name + "/lib/" + file, "lib/" + file} {
+			if match, ok := r.uniqueSuffix(suffix); ok {
+				return []string{match}, false
+			}
+		}
+		return nil, false
+	}
+	if strings.Contains(source, ":") {
+		return nil, false
+	}
+	return r.resolveRelative(from, source)
+}
+
+// resolveRelative resolves a path relative to the importing file, as CSS @import and
+// most other languages without a module system use. Paths with a scheme are external.
+func (r *Resolver) resolveRelative(from, source string) ([]string, bool) {
+	source = strings.Trim(source, `"'`)
+	if inner, ok := strings.CutPrefix(source, "url("); ok {
+		source = strings.Trim(strings.TrimSuffix(inner, ")"), `"'`)
+	}
+	if source == "" || strings.Contains(source, "://") || strings.HasPrefix(source, "//") {
+		return nil, false
+	}
+	candidate := join(path.Dir(from), source)
+	if strings.HasPrefix(source, "/") {
+		candidate = path.Clean(strings.TrimPrefix(source, "/"))
+	}
+	if r.files[candidate] {
+		return []string{candidate}, false
+	}
+	return nil, isRelative(source)
+}
+
+// uniqueSuffix returns the one file whose path is suffix or ends in /suffix, if exactly
+// one does.
+func (r *Resolver) uniqueSuffix(suffix string) (string, bool) {
+	suffix = path.Clean(suffix)
+	var match string
+	for _, file := range r.byBase[path.Base(suffix)] {
+		if file == suffix || strings.HasSuffix(file, "/"+suffix) {
+			if match != "" {
+				return "", false
+			}
+			match = file
+		}
+	}
+	return match, match != ""
+}
+
+// diskPath returns where the repo-relative path p is on disk, or "" without a tree.
+func (r *Resolver) diskPath(p string) string {
+	if r.root == "" {
+		return ""
+	}
+	return filepath.Join(r.root, filepath.FromSlash(p))
+}
+
+// isRelative reports whether source is a path relative to the importing file.
+func isRelative(source string) bool {
+	return source == "." || source == ".." || strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../")
+}
+
+// join joins a relative path onto dir. Paths that leave the tree come back as "", which
+// matches no file.
+func join(dir, rel string) string {
+	p := path.Join(dir, rel)
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return ""
+	}
+	return p
+}
+
+// sorted returns a sorted copy of files.
+func sorted(files []string) []string {
+	out := append([]string(nil), files...)
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,156 @@
+package imports
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/1107-adishjain/codemap/internal/models"
+)
+
+// testTree lays out a tree of every language the resolver knows on disk and returns a
+// resolver with all of its files added. Files are keyed by path; Java and Kotlin files
+// name the package they declare, or "" for none.
+func testTree(t *testing.T) *Resolver {
+	t.Helper()
+	files := map[string]string{
+		"go.mod":                                   "module example.com/app\n\ngo 1.22\n",
+		"main.go":                                  "",
+		"internal/util/util.go":                    "",
+		"internal/util/strings.go":                 "",
+		"internal/util/util_test.go":               "",
+		"web/tsconfig.json":                        tsconfig,
+		"web/src/app.ts":                           "",
+		"web/src/lib/format.ts":                    "",
+		"web/src/components/index.tsx":             "",
+		"web/src/config.ts":                        "",
+		"web/src/legacy.js":                        "",
+		"pkg/__init__.py":                          "",
+		"pkg/mod.py":                               "",
+		"pkg/sub/__init__.py":                      "",
+		"pkg/sub/helper.py":                        "",
+		"scripts/run.py":                           "",
+		"scripts/tools.py":                         "",
+		"src/main/java/com/acme/App.java":          "com.acme",
+		"src/main/java/com/acme/util/Strings.java": "com.acme.util",
+		"src/main/kotlin/com/acme/Ext.kt":          "com.acme",
+		"lib/org/x/Y.java":                         "",
+		"include/lib.h":                            "",
+		"src/main.c":                               "",
+		"styles/main.css":                          "",
+		"styles/base.css":                          "",
+	}
+	// The tree must be on disk before files are added, which reads go.mod files.
+	root := t.TempDir()
+	for p, content := range files {
+		file := filepath.Join(root, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if isJVM(p) {
+			content = ""
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := NewResolver(root)
+	for p, content := range files {
+		f := models.File{Path: p}
+		if isJVM(p) {
+			f.Package = content
+		}
+		if p == "src/main/kotlin/com/acme/Ext.kt" {
+			f.Classes = []models.Class{{Name: "Helper"}}
+		}
+		r.Add(f)
+	}
+	return r
+}
+
+// isJVM reports whether p is a Java or Kotlin file.
+func isJVM(p string) bool {
+	return filepath.Ext(p) == ".java" || filepath.Ext(p) == ".kt"
+}
+
+// tsconfig maps paths both by wildcard and exactly, with the comments and trailing
+// commas tsconfig files allow.
+const tsconfig = `{
+	// Resolve bare imports from the project directory.
+	"compilerOptions": {
+		"baseUrl": ".",
+		/* Aliases. */
+		"paths": {
+			"@lib/*": ["src/lib/*"],
+			"~config": ["src/config.ts"],
+		},
+	},
+}`
+
+func TestResolve(t *testing.T) {
+	r := testTree(t)
+	tests := []struct {
+		name    string
+		from    string
+		source  string
+		want    []string
+		missing bool
+	}{
+		// JavaScript and TypeScript.
+		{"js relative", "web/src/app.ts", "./lib/format", []string{"web/src/lib/format.ts"}, false},
+		{"js .js extension of a .ts file", "web/src/app.ts", "./lib/format.js", []string{"web/src/lib/format.ts"}, false},
+		{"js index file", "web/src/app.ts", "./components", []string{"web/src/components/index.tsx"}, false},
+		{"js parent directory", "web/src/lib/format.ts", "../config", []string{"web/src/config.ts"}, false},
+		{"js missing relative", "web/src/app.ts", "./nope", nil, true},
+		{"js relative out of the tree", "web/src/app.ts", "../../../../x", nil, true},
+		{"tsconfig wildcard path", "web/src/app.ts", "@lib/format", []string{"web/src/lib/format.ts"}, false},
+		{"tsconfig exact path", "web/src/app.ts", "~config", []string{"web/src/config.ts"}, false},
+		{"tsconfig baseUrl", "web/src/app.ts", "src/legacy", []string{"web/src/legacy.js"}, false},
+		{"js package", "web/src/app.ts", "react", nil, false},
+
+		// Go.
+		{"go package", "main.go", "example.com/app/internal/util", []string{"internal/util/strings.go", "internal/util/util.go"}, false},
+		{"go quoted import", "main.go", `"example.com/app/internal/util"`, []string{"internal/util/strings.go", "internal/util/util.go"}, false},
+		{"go missing package of the module", "main.go", "example.com/app/internal/nope", nil, true},
+		{"go standard library", "main.go", "fmt", nil, false},
+		{"go other module", "main.go", "example.com/other/util", nil, false},
+
+		// Python.
+		{"python sibling module of a script", "scripts/run.py", "tools", []string{"scripts/tools.py"}, false},
+		{"python absolute module", "pkg/sub/helper.py", "pkg.mod", []string{"pkg/mod.py"}, false},
+		{"python package", "scripts/run.py", "pkg.sub", []string{"pkg/sub/__init__.py"}, false},
+		{"python alias", "pkg/mod.py", "pkg.sub.helper as h", []string{"pkg/sub/helper.py"}, false},
+		{"python relative module", "pkg/mod.py", ".sub", []string{"pkg/sub/__init__.py"}, false},
+		{"python parent package", "pkg/sub/helper.py", "..mod", []string{"pkg/mod.py"}, false},
+		{"python own package", "pkg/sub/helper.py", ".", []string{"pkg/sub/__init__.py"}, false},
+		{"python missing relative module", "pkg/mod.py", ".nope", nil, true},
+		{"python relative import above the root", "pkg/mod.py", "...x", nil, true},
+		{"python standard library", "pkg/mod.py", "os.path", nil, false},
+
+		// Java and Kotlin.
+		{"jvm class", "src/main/java/com/acme/App.java", "com.acme.util.Strings", []string{"src/main/java/com/acme/util/Strings.java"}, false},
+		{"jvm static member", "src/main/java/com/acme/App.java", "com.acme.util.Strings.join;", []string{"src/main/java/com/acme/util/Strings.java"}, false},
+		{"jvm wildcard", "src/main/java/com/acme/App.java", "com.acme.*", []string{"src/main/java/com/acme/App.java", "src/main/kotlin/com/acme/Ext.kt"}, false},
+		{"kotlin class declared in another file name", "src/main/java/com/acme/App.java", "com.acme.Helper", []string{"src/main/kotlin/com/acme/Ext.kt"}, false},
+		{"jvm directory layout", "src/main/java/com/acme/App.java", "org.x.Y", []string{"lib/org/x/Y.java"}, false},
+		{"jvm library", "src/main/kotlin/com/acme/Ext.kt", "java.util.List", nil, false},
+
+		// C and CSS.
+		{"c include directory", "src/main.c", `"lib.h"`, []string{"include/lib.h"}, false},
+		{"c system header", "src/main.c", "<stdio.h>", nil, false},
+		{"c missing header", "src/main.c", `"nope.h"`, nil, true},
+		{"css url", "styles/main.css", "url('base.css')", []string{"styles/base.css"}, false},
+		{"css remote", "styles/main.css", "https://fonts.example.com/a.css", nil, false},
+
+		{"empty source", "main.go", " ", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, missing := r.Resolve(tt.from, tt.source)
+			if !reflect.DeepEqual(got, tt.want) || missing != tt.missing {
+				t.Errorf("Resolve(%q, %q) = %v, %v; want %v, %v", tt.from, tt.source, got, missing, tt.want, tt.missing)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/1107-adishjain/codemap/internal/analysis"
+	"github.com/1107-adishjain/codemap/internal/clusters"
+	"github.com/1107-adishjain/codemap/internal/database"
+	"github.com/1107-adishjain/codemap/internal/events"
+	"github.com/1107-adishjain/codemap/internal/helper"
+	"github.com/1107-adishjain/codemap/internal/metrics"
+)
+
+// importTimeout bounds how long the Neo4j import may run after the analyser finishes.
+const importTimeout = 15 * time.Minute
+
+// metricsTimeout bounds how long scoring, and separately clustering, the imported graph
+// may take.
+const metricsTimeout = 10 * time.Minute
+
+// execute runs the fetch → extract → analyze → import pipeline for a claimed job.
+// Cancelling ctx kills the analyser and removes whatever was already imported.
+func (p *Pool) execute(ctx context.Context, job *database.Job) error {
+	project, err := p.db.GetProjectByID(job.ProjectID)
+	if err != nil {
+		return fmt.Errorf("failed to load project: %w", err)
+	}
+	if project == nil {
+		return fmt.Errorf("project %s no longer exists", job.ProjectID)
+	}
+
+	var sourceDir string
+	switch job.SourceType {
+	case database.SourceS3:
+		var workDir string
+		workDir, sourceDir, err = p.prepareStored(job)
+		if workDir != "" {
+			defer os.RemoveAll(workDir)
+		}
+	case database.SourceGitHub:
+		var cloneDir string
+		cloneDir, err = p.prepareGitHub(job)
+		if cloneDir != "" {
+			defer os.RemoveAll(filepath.Dir(cloneDir))
+		}
+		sourceDir = cloneDir
+	default:
+		err = fmt.Errorf("unknown job source type %q", job.SourceType)
+	}
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Files are imported as the analyser streams them, so the analyzing stage also
+	// writes nodes; the importing stage covers relationships and the commit.
+	p.setStage(job, database.StageAnalyzing)
+	importCtx, cancel := context.WithTimeout(ctx, p.cfg.AnalysisTimeout+importTimeout)
+	defer cancel()
+	progress := func(phase string, processed, total int) {
+		p.publish(job, events.Event{Type: events.TypeProgress, Stage: job.Stage, Phase: phase, Processed: processed, Total: total})
+	}
+	importer, err := p.db.NewImporter(importCtx, job.ProjectID, project.Name, database.ImportOptions{
+		BatchSize:  p.cfg.ImportBatchSize,
+		CommitSize: p.cfg.ImportCommitSize,
+		Progress:   progress,
+		SourceDir:  sourceDir,
+		Logger:     p.logger,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to import data to Neo4j: %w", err)
+	}
+	defer importer.Close()
+
+	result, err := p.analyzers.Stream(ctx, analysis.Options{
+		ToolsPath: p.cfg.ToolsPath,
+		TargetDir: sourceDir,
+		Timeout:   p.cfg.AnalysisTimeout,
+	}, importer.Add)
+	if err != nil {
+		var runErr *analysis.RunError
+		if errors.As(err, &runErr) {
+			return fmt.Errorf("analysis failed: %w", err)
+		}
+		return fmt.Errorf("failed to import data to Neo4j: %w", err)
+	}
+	if n := len(result.Diagnostics); n > 0 {
+		p.logger.Printf("Job %s: analyser reported %d diagnostics", job.ID, n)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.setStage(job, database.StageImporting)
+	if err := importer.Commit(); err != nil {
+		return fmt.Errorf("failed to import data to Neo4j: %w", err)
+	}
+	p.logger.Printf("Job %s: imported %s", job.ID, importer.Stats())
+
+	// The graph is complete without metrics and clusters, so failing to compute them
+	// is logged rather than failing the job; both can be recomputed on demand.
+	p.setStage(job, database.StageScoring)
+	metricsCtx, cancelMetrics := context.WithTimeout(ctx, metricsTimeout)
+	defer cancelMetrics()
+	stats, err := metrics.Compute(metricsCtx, p.db, job.ProjectID)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err != nil {
+		p.logger.Printf("Job %s: could not compute graph metrics: %v", job.ID, err)
+	} else {
+		p.logger.Printf("Job %s: metrics: %s", job.ID, stats)
+	}
+
+	p.setStage(job, database.StageClustering)
+	clusterCtx, cancelClusters := context.WithTimeout(ctx, metricsTimeout)
+	defer cancelClusters()
+	clusterStats, err := clusters.Compute(clusterCtx, p.db, job.ProjectID)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err != nil {
+		p.logger.Printf("Job %s: could not cluster the graph: %v", job.ID, err)
+	} else {
+		p.logger.Printf("Job %s: clusters: %s", job.ID, clusterStats)
+	}
+	return nil
+}
+
+// prepareGitHub clones the repository and uploads it to S3.
+// It returns the clone directory; the caller removes its parent when done.
+func (p *Pool) prepareGitHub(job *database.Job) (string, error) {
+	s3Key, cloneDir, err := p.s3.UploadGitRepo(job.Source)
+	if err != nil {
+		return cloneDir, fmt.Errorf("failed to clone and upload repository: %w", err)
+	}
+	if err := p.db.UpdateProjectS3Key(job.ProjectID, s3Key); err != nil {
+		return cloneDir, fmt.Errorf("failed to record S3 key: %w", err)
+	}
+	p.logger.Printf("📤 Uploaded GitHub repo to S3: %s", s3Key)
+	return cloneDir, nil
+}
+
+// prepareStored downloads the project's archive from S3 and extracts it.
+// It returns the working directory to remove afterwards and the directory to analyze.
+func (p *Pool) prepareStored(job *database.Job) (string, string, error) {
+	workDir, err := os.MkdirTemp(p.cfg.TempUploads, "codemap-job-*")
+	if err != nil {
+		return "", "", fmt.Errorf("could not create temp directory: %w", err)
+	}
+	zipPath := filepath.Join(workDir, "archive.zip")
+	if err := p.s3.DownloadFile(job.Source, zipPath); err != nil {
+		return workDir, "", err
+	}
+	p.logger.Printf("📥 Downloaded %s from S3 for job %s", job.Source, job.ID)
+
+	unzipDest := filepath.Join(workDir, "unzipped")
+	if err := helper.Unzip(zipPath, unzipDest); err != nil {
+		return workDir, "", fmt.Errorf("failed to unzip file: %w", err)
+	}
+	return workDir, unzipDest, nil
+}
+
+// setStage persists a stage transition and announces it to event subscribers.
+// Failures are logged rather than aborting the job.
+func (p *Pool) setStage(job *database.Job, stage string) {
+	job.Stage = stage
+	if err := p.db.UpdateJobStage(job.ID, job.Attempts, stage); err != nil {
+		p.logger.Printf("Could not update job %s to stage %s: %v", job.ID, stage, err)
+	}
+	p.publish(job, events.Event{Type: events.TypeStage, Stage: stage})
+}
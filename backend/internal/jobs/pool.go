@@ -0,0 +1,394 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/1107-adishjain/codemap/internal/analysis"
+	"github.com/1107-adishjain/codemap/internal/config"
+	"github.com/1107-adishjain/codemap/internal/database"
+	"github.com/1107-adishjain/codemap/internal/events"
+	"github.com/1107-adishjain/codemap/internal/s3"
+)
+
+// pollInterval is how often idle workers check Postgres for jobs they were not woken for,
+// e.g. jobs requeued as stale or enqueued by another API instance.
+const pollInterval = 5 * time.Second
+
+// Several API instances may share the queue, so a job left running by a process that died
+// can't be told apart from one another instance is running except by its lease: running
+// jobs renew it every heartbeatInterval, and jobs whose lease is older than jobLease are
+// requeued.
+const (
+	heartbeatInterval = 15 * time.Second
+	jobLease          = 2 * time.Minute
+)
+
+// cancelPollInterval is how often CancelAndWait checks whether a job cancelled in another
+// process has stopped.
+const cancelPollInterval = time.Second
+
+var (
+	// errCancelled is the cancellation cause of jobs stopped through Cancel.
+	errCancelled = errors.New("job cancelled by user")
+	// errLeaseLost is the cancellation cause of jobs whose lease another worker took over.
+	errLeaseLost = errors.New("job lease lost")
+)
+
+// Pool runs queued analysis jobs on a fixed number of workers.
+type Pool struct {
+	cfg     *config.AppConfig
+	db      *database.DB
+	s3      *s3.Service
+	events  *events.Broker
+	logger  *log.Logger
+	workers int
+	// analyzers runs the native analyzers ahead of the Node.js tool.
+	analyzers *analysis.Registry
+
+	wake   chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// mu serialises claiming jobs with Cancel so a job is always either
+	// queued in Postgres or registered in running.
+	mu      sync.Mutex
+	running map[string]*runningJob
+}
+
+// runningJob is a job a worker of this process is executing.
+type runningJob struct {
+	cancel context.CancelCauseFunc
+	// done is closed once the worker has finished with the job.
+	done chan struct{}
+}
+
+func NewPool(cfg *config.AppConfig, db *database.DB, s3Service *s3.Service, broker *events.Broker, logger *log.Logger) *Pool {
+	workers := cfg.AnalysisWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	return &Pool{
+		cfg:       cfg,
+		db:        db,
+		s3:        s3Service,
+		events:    broker,
+		logger:    logger,
+		workers:   workers,
+		analyzers: analysis.DefaultRegistry(),
+		wake:      make(chan struct{}, workers),
+		running:   make(map[string]*runningJob),
+	}
+}
+
+// Start queues the reanalysis of projects whose graph predates the current importer, and
+// launches the workers and the reaper that requeues jobs whose process died.
+func (p *Pool) Start() {
+	p.queueLegacyProjects()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.wg.Add(1)
+	go p.reap(ctx)
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.worker(ctx)
+	}
+	p.logger.Printf("Started %d analysis workers", p.workers)
+}
+
+// queueLegacyProjects enqueues an analysis of the stored archive of every project that
+// schema migrations flagged as imported by an older importer, unless it has a job in
+// flight already. Projects without an archive keep their migrated graph.
+func (p *Pool) queueLegacyProjects() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ids, err := p.db.ClaimLegacyProjects(ctx)
+	if err != nil {
+		p.logger.Printf("Could not look up projects to reanalyse: %v", err)
+		return
+	}
+	queued := 0
+	for _, id := range ids {
+		project, err := p.db.GetProjectByID(id)
+		if err != nil {
+			p.logger.Printf("Could not reanalyse project %s: %v", id, err)
+			continue
+		}
+		if project == nil || project.S3Key == "" {
+			continue
+		}
+		job, err := p.db.GetLatestJobByProject(id)
+		if err != nil {
+			p.logger.Printf("Could not reanalyse project %s: %v", id, err)
+			continue
+		}
+		if job != nil && !database.Terminal(job.Stage) {
+			continue
+		}
+		if _, err := p.db.CreateJob(id, database.SourceS3, project.S3Key); err != nil {
+			p.logger.Printf("Could not reanalyse project %s: %v", id, err)
+			continue
+		}
+		queued++
+	}
+	if queued > 0 {
+		p.logger.Printf("Queued %d projects with legacy graphs for reanalysis", queued)
+	}
+}
+
+// reap requeues jobs whose lease has expired, at startup and then every half lease, and
+// wakes the workers when it found any.
+func (p *Pool) reap(ctx context.Context) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(jobLease / 2)
+	defer ticker.Stop()
+	for {
+		if n, err := p.db.RequeueStaleJobs(jobLease); err != nil {
+			p.logger.Printf("Could not requeue stale analysis jobs: %v", err)
+		} else if n > 0 {
+			p.logger.Printf("Requeued %d interrupted analysis jobs", n)
+			for i := 0; i < p.workers; i++ {
+				p.Notify()
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Notify wakes an idle worker after a job has been enqueued.
+func (p *Pool) Notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Stop cancels running jobs and waits for the workers to exit or ctx to expire.
+// Cancelled jobs are put back on the queue so the next process picks them up.
+func (p *Pool) Stop(ctx context.Context) error {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) worker(ctx context.Context) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil && p.runNext(ctx) {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Cancel stops a job. Queued jobs are cancelled in Postgres; running jobs have their
+// context cancelled, which kills the analyser and rolls back the import transaction. Jobs
+// running in another process are marked cancelled in Postgres, and their worker stops them
+// at its next heartbeat. It reports false if the job is neither queued nor running.
+func (p *Pool) Cancel(job *database.Job) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if run, ok := p.running[job.ID]; ok {
+		run.cancel(errCancelled)
+		return true, nil
+	}
+	cancelled, err := p.db.CancelQueuedJob(job.ID)
+	if err != nil {
+		return false, err
+	}
+	if !cancelled {
+		// Jobs claimed under p.mu are in p.running, so this one runs elsewhere, if at all.
+		return p.db.CancelRunningJob(job.ID)
+	}
+	if err := p.db.UpdateProjectStatus(job.ProjectID, database.StageCancelled); err != nil {
+		p.logger.Printf("Could not update status of project %s: %v", job.ProjectID, err)
+	}
+	p.publish(job, events.Event{Type: events.TypeCancelled, Stage: database.StageCancelled})
+	return true, nil
+}
+
+// CancelAndWait cancels the job like Cancel and waits until no worker, in this process or
+// another, runs it any more, or ctx expires. Afterwards nothing writes the project's
+// graph any more, so the caller can safely remove it.
+func (p *Pool) CancelAndWait(ctx context.Context, job *database.Job) error {
+	if _, err := p.Cancel(job); err != nil {
+		return err
+	}
+	// Cancel either stopped the job in Postgres or cancelled it here, even if a
+	// worker claimed it in the meantime; a job running here is waited for directly.
+	p.mu.Lock()
+	run, ok := p.running[job.ID]
+	p.mu.Unlock()
+	if ok {
+		select {
+		case <-run.done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	ticker := time.NewTicker(cancelPollInterval)
+	defer ticker.Stop()
+	for {
+		stopped, err := p.db.JobStopped(job.ID, jobLease)
+		if err != nil || stopped {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// runNext claims and processes one job. It reports whether a job was found.
+func (p *Pool) runNext(ctx context.Context) bool {
+	p.mu.Lock()
+	job, err := p.db.ClaimNextJob()
+	if err != nil || job == nil {
+		p.mu.Unlock()
+		if err != nil {
+			p.logger.Printf("Could not claim analysis job: %v", err)
+		}
+		return false
+	}
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	run := &runningJob{cancel: cancel, done: make(chan struct{})}
+	p.running[job.ID] = run
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.running, job.ID)
+		p.mu.Unlock()
+		cancel(nil)
+		close(run.done)
+	}()
+	go p.heartbeat(jobCtx, job, cancel)
+	p.process(jobCtx, job)
+	return true
+}
+
+// heartbeat renews the lease of a running job until ctx ends. A cancellation requested
+// from another process cancels the job like Cancel does; should the job have been taken
+// over, e.g. after this process stalled for longer than the lease, it is stopped so that
+// only its new owner writes the graph.
+func (p *Pool) heartbeat(ctx context.Context, job *database.Job, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		stage, err := p.db.HeartbeatJob(job.ID, job.Attempts)
+		switch {
+		case err != nil:
+			p.logger.Printf("Could not renew lease of job %s: %v", job.ID, err)
+		case ctx.Err() != nil:
+			return
+		case stage == database.StageCancelled:
+			cancel(errCancelled)
+			return
+		case stage == "" || stage == database.StageQueued || database.Terminal(stage):
+			cancel(errLeaseLost)
+			return
+		}
+	}
+}
+
+func (p *Pool) process(ctx context.Context, job *database.Job) {
+	p.logger.Printf("⚙️ Job %s started for project %s (attempt %d)", job.ID, job.ProjectID, job.Attempts)
+	p.publish(job, events.Event{Type: events.TypeStage, Stage: job.Stage})
+
+	err := p.execute(ctx, job)
+	if err != nil && errors.Is(context.Cause(ctx), errLeaseLost) {
+		// The job belongs to another attempt now; its row and events are not ours.
+		p.logger.Printf("Job %s lost its lease to another worker, stopped", job.ID)
+		return
+	}
+	if err != nil && ctx.Err() != nil && !errors.Is(context.Cause(ctx), errCancelled) {
+		// A job cancelled while shutting down stays cancelled; finish records it below.
+		requeued, rqErr := p.db.RequeueJob(job.ID, job.Attempts)
+		if rqErr != nil {
+			p.logger.Printf("Could not requeue job %s: %v", job.ID, rqErr)
+		}
+		if requeued || rqErr != nil {
+			p.logger.Printf("Job %s interrupted by shutdown, requeued", job.ID)
+			p.publish(job, events.Event{Type: events.TypeStage, Stage: database.StageQueued})
+			return
+		}
+	}
+
+	switch {
+	case err != nil && errors.Is(context.Cause(ctx), errCancelled):
+		p.logger.Printf("🛑 Job %s cancelled", job.ID)
+		p.finish(job, database.StageCancelled, events.TypeCancelled, "")
+	case err != nil:
+		p.logger.Printf("❌ Job %s failed: %v", job.ID, err)
+		p.finish(job, database.StageFailed, events.TypeFailed, err.Error())
+	default:
+		p.finish(job, database.StageCompleted, events.TypeCompleted, "")
+		p.logger.Printf("✅ Analysis and import completed for project ID: %s", job.ProjectID)
+	}
+}
+
+// finish records a terminal stage on the job and its project and announces it. A job
+// whose cancellation was requested while it ran is recorded as cancelled whatever its
+// outcome, and a job another attempt owns is left alone.
+func (p *Pool) finish(job *database.Job, stage, eventType, errMsg string) {
+	finished, err := p.db.FinishJob(job.ID, job.Attempts, stage, errMsg)
+	if err != nil {
+		p.logger.Printf("Could not mark job %s %s: %v", job.ID, stage, err)
+	} else if !finished {
+		cancelled, err := p.db.FinishCancelledJob(job.ID, job.Attempts)
+		if err != nil {
+			p.logger.Printf("Could not mark job %s cancelled: %v", job.ID, err)
+		} else if !cancelled {
+			p.logger.Printf("Job %s belongs to another attempt, not marked %s", job.ID, stage)
+			return
+		}
+		stage, eventType, errMsg = database.StageCancelled, events.TypeCancelled, ""
+	}
+	if err := p.db.UpdateProjectStatus(job.ProjectID, stage); err != nil {
+		p.logger.Printf("Could not update status of project %s: %v", job.ProjectID, err)
+	}
+	p.publish(job, events.Event{Type: eventType, Stage: stage, Error: errMsg})
+}
+
+// publish stamps an event with the job's identity and hands it to the broker.
+func (p *Pool) publish(job *database.Job, e events.Event) {
+	e.ProjectID = job.ProjectID
+	e.JobID = job.ID
+	e.Attempt = job.Attempts
+	p.events.Publish(e)
+}
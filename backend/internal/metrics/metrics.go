@@ -0,0 +1,136 @@
+// Package metrics scores the functions, classes and files of a project graph by how
+// central they are to it, and stores the scores on the nodes.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/1107-adishjain/codemap/internal/database"
+	"github.com/1107-adishjain/codemap/internal/graphalg"
+)
+
+// Names of the node properties metrics are stored in.
+const (
+	// FanIn counts the distinct nodes depending on a node (afferent coupling, Ca).
+	FanIn = "fan_in"
+	// FanOut counts the distinct nodes a node depends on (efferent coupling, Ce).
+	FanOut = "fan_out"
+	// PageRank ranks nodes depended on by other highly ranked nodes; ranks sum to 1.
+	PageRank = "pagerank"
+	// Betweenness is the share of shortest dependency paths passing through a node.
+	Betweenness = "betweenness"
+	// Instability is Ce / (Ca + Ce): 0 for nodes only depended on, 1 for nodes only
+	// depending on others. Nodes with no dependencies either way score 0.
+	Instability = "instability"
+)
+
+// Names lists every metric.
+var Names = []string{FanIn, FanOut, PageRank, Betweenness, Instability}
+
+// Labels are the kinds of node scored.
+var Labels = []string{"Function", "Class", "File"}
+
+const (
+	pageRankDamping    = 0.85
+	pageRankIterations = 100
+	pageRankTolerance  = 1e-9
+	// betweennessSamples caps the source nodes of the betweenness computation; larger
+	// graphs get an estimate, since the exact value costs O(nodes × edges).
+	betweennessSamples = 2000
+)
+
+// dependencyQueries load, for each label, every node of the project with the nodes of
+// the same label it depends on: functions through the functions they call, classes
+// through the classes whose methods their methods call, and files through the files
+// they import. Self-dependencies are left out.
+var dependencyQueries = map[string]string{
+	"Function": `
+		MATCH (n:Function {project_id: $projectId})
+		OPTIONAL MATCH (n)-[:CALLS]->(m:Function {project_id: $projectId})
+		WHERE m <> n
+		RETURN elementId(n) AS id, collect(DISTINCT elementId(m)) AS targets
+	`,
+	"Class": `
+		MATCH (n:Class {project_id: $projectId})
+		OPTIONAL MATCH (n)-[:HAS_METHOD|OWNS_METHOD]->(:Function)-[:CALLS]->(:Function)<-[:HAS_METHOD|OWNS_METHOD]-(m:Class {project_id: $projectId})
+		WHERE m <> n
+		RETURN elementId(n) AS id, collect(DISTINCT elementId(m)) AS targets
+	`,
+	"File": `
+		MATCH (n:File {project_id: $projectId})
+		OPTIONAL MATCH (n)-[:IMPORTS]->(m:File {project_id: $projectId})
+		WHERE m <> n
+		RETURN elementId(n) AS id, collect(DISTINCT elementId(m)) AS targets
+	`,
+}
+
+// Stats describes a metrics run.
+type Stats struct {
+	// Nodes counts the scored nodes per label.
+	Nodes    map[string]int
+	Duration time.Duration
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%d functions, %d classes, %d files scored in %s",
+		s.Nodes["Function"], s.Nodes["Class"], s.Nodes["File"], s.Duration.Round(time.Millisecond))
+}
+
+// Compute scores every function, class and file of a project against the dependency
+// graph of its own kind and stores the scores (see Names) on the nodes.
+func Compute(ctx context.Context, db *database.DB, projectID string) (Stats, error) {
+	started := time.Now()
+	stats := Stats{Nodes: map[string]int{}}
+	var scored []database.NodeMetrics
+	for _, label := range Labels {
+		records, err := db.ProjectQuery(ctx, projectID, dependencyQueries[label], nil)
+		if err != nil {
+			return Stats{}, fmt.Errorf("failed to load %s dependencies: %w", label, err)
+		}
+		g := graphalg.New()
+		for _, record := range records {
+			id, _ := record["id"].(string)
+			g.AddNode(id)
+			targets, _ := record["targets"].([]any)
+			for _, t := range targets {
+				if target, ok := t.(string); ok {
+					g.AddEdge(id, target)
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return Stats{}, err
+		}
+		scored = append(scored, score(g)...)
+		stats.Nodes[label] = g.Len()
+	}
+	if err := db.WriteNodeMetrics(ctx, projectID, scored); err != nil {
+		return Stats{}, err
+	}
+	stats.Duration = time.Since(started)
+	return stats, nil
+}
+
+// score computes the metrics of every node of g, whose nodes are element IDs.
+func score(g *graphalg.Graph) []database.NodeMetrics {
+	in, out := g.Degrees()
+	rank := g.PageRank(pageRankDamping, pageRankIterations, pageRankTolerance)
+	between := g.Betweenness(betweennessSamples)
+	nodes := make([]database.NodeMetrics, 0, g.Len())
+	for i, id := range g.Nodes() {
+		instability := 0.0
+		if in[i]+out[i] > 0 {
+			instability = float64(out[i]) / float64(in[i]+out[i])
+		}
+		nodes = append(nodes, database.NodeMetrics{ElementID: id, Values: map[string]any{
+			FanIn:       in[i],
+			FanOut:      out[i],
+			PageRank:    rank[i],
+			Betweenness: between[i],
+			Instability: instability,
+		}})
+	}
+	return nodes
+}
@@ -3,24 +3,48 @@ package models
 // Analysis represents the top-level structure of our analysis-output.json.
 type Analysis struct {
 	Files []File `json:"files"`
+	// Diagnostics are the warnings and errors the analyser reported on stderr.
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// Diagnostic is a single message reported by the analyser.
+type Diagnostic struct {
+	Level   string `json:"level"`
+	Message string `json:"message"`
+	File    string `json:"file,omitempty"`
 }
 
 // File represents a single source code file.
 type File struct {
-	Path      string     `json:"path"`
-	Language  string     `json:"language"`
+	Path     string `json:"path"`
+	Language string `json:"language"`
+	// Package is the package the file declares, for languages that have one: the
+	// import path of a Go file, or the package of a Java or Kotlin file.
+	Package string `json:"package,omitempty"`
+	// Module is the path of the Go module the file belongs to.
+	Module    string     `json:"module,omitempty"`
 	Classes   []Class    `json:"classes,omitempty"`
 	Functions []Function `json:"functions,omitempty"`
 	Imports   []Import   `json:"imports,omitempty"`
 	Error     string     `json:"error,omitempty"`
 }
 
+// Location is a span of source code. Lines and columns are 1-based and columns
+// count bytes; the end position is exclusive.
+type Location struct {
+	StartLine   int `json:"start_line"`
+	StartColumn int `json:"start_column"`
+	EndLine     int `json:"end_line"`
+	EndColumn   int `json:"end_column"`
+}
+
 // Class represents a class definition.
 type Class struct {
-	Name       string   `json:"name"`
-	IsExported bool     `json:"is_exported"`
-	Properties []string `json:"properties,omitempty"`
-	Methods    []string `json:"methods,omitempty"`
+	Name       string    `json:"name"`
+	IsExported bool      `json:"is_exported"`
+	Properties []string  `json:"properties,omitempty"`
+	Methods    []string  `json:"methods,omitempty"`
+	Location   *Location `json:"location,omitempty"`
 }
 
 // Function represents a function or method.
@@ -31,9 +55,59 @@ type Function struct {
 	ReturnTypes []string `json:"return_types,omitempty"`
 	Calls       []string `json:"calls,omitempty"`
 	IsMethodOf  string   `json:"is_method_of,omitempty"`
+	// ResolvedCalls are calls bound to a function in the tree, by an analyzer or by
+	// resolving Calls before import.
+	ResolvedCalls []ResolvedCall `json:"resolved_calls,omitempty"`
+	// AmbiguousCalls are calls that may target any of several functions, one entry per
+	// candidate; their confidences add up to at most that of a single resolved call.
+	AmbiguousCalls []ResolvedCall `json:"ambiguous_calls,omitempty"`
+	// UnresolvedCalls are calls to no function of the tree that could be found.
+	UnresolvedCalls []UnresolvedCall `json:"unresolved_calls,omitempty"`
+	Location        *Location        `json:"location,omitempty"`
+	// ParamLocations holds the location of each entry of Params, in order.
+	ParamLocations []Location `json:"param_locations,omitempty"`
+	// CallSites lists every call behind Calls, one entry per occurrence.
+	CallSites []CallSite `json:"call_sites,omitempty"`
+}
+
+// ResolvedCall identifies the exact function a call targets.
+type ResolvedCall struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+	// Class is the class or receiver type the function is a method of, if any.
+	Class string `json:"class,omitempty"`
+	// Sites lists every occurrence of the call.
+	Sites []Location `json:"sites,omitempty"`
+	// Call is the call as written, e.g. "utils.format", when it differs from Name.
+	Call string `json:"call,omitempty"`
+	// Confidence is how likely the call is to target the function, from 0 to 1. Zero
+	// means the analyzer bound the call from type information, which is certain.
+	Confidence float64 `json:"confidence,omitempty"`
+	// Resolution names the rule that bound the call, e.g. "class" or "import".
+	Resolution string `json:"resolution,omitempty"`
+}
+
+// UnresolvedCall is a call, as written, whose target could not be found.
+type UnresolvedCall struct {
+	Call string `json:"call"`
+	// Sites lists every occurrence of the call.
+	Sites []Location `json:"sites,omitempty"`
+}
+
+// CallSite is a single occurrence of a call.
+type CallSite struct {
+	Name     string   `json:"name"`
+	Location Location `json:"location"`
 }
 
 // Import represents an import statement.
 type Import struct {
-	Source string `json:"source"`
+	Source   string    `json:"source"`
+	Location *Location `json:"location,omitempty"`
+	// Resolved lists the files of the tree the import refers to: one file, or every file
+	// of an imported Go package or Java wildcard import. It is set before import.
+	Resolved []string `json:"resolved,omitempty"`
+	// Missing marks an import of a file of the tree, e.g. by relative path, that does
+	// not exist.
+	Missing bool `json:"missing,omitempty"`
 }
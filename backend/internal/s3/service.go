@@ -125,12 +125,15 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
 
 type Service struct {
-	uploader *s3manager.Uploader
-	bucket   string
+	client     *awss3.S3
+	uploader   *s3manager.Uploader
+	downloader *s3manager.Downloader
+	bucket     string
 }
 
 func NewS3Service(region, accessKey, secretKey, bucket string) (*Service, error) {
@@ -143,13 +146,47 @@ func NewS3Service(region, accessKey, secretKey, bucket string) (*Service, error)
 	}
 
 	uploader := s3manager.NewUploader(sess)
+	downloader := s3manager.NewDownloader(sess)
 
 	return &Service{
-		uploader: uploader,
-		bucket:   bucket,
+		client:     awss3.New(sess),
+		uploader:   uploader,
+		downloader: downloader,
+		bucket:     bucket,
 	}, nil
 }
 
+// DownloadFile downloads the object stored under key to destPath
+func (s *Service) DownloadFile(key, destPath string) error {
+	file, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", destPath, err)
+	}
+	defer file.Close()
+
+	_, err = s.downloader.Download(file, &awss3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to download %s from S3: %w", key, err)
+	}
+	return nil
+}
+
+// DeleteFile removes the object stored under key. Deleting a missing object is not an error,
+// so the call is safe to repeat.
+func (s *Service) DeleteFile(key string) error {
+	_, err := s.client.DeleteObject(&awss3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s from S3: %w", key, err)
+	}
+	return nil
+}
+
 // UploadZipFile uploads a zip file to S3 and returns the S3 key
 func (s *Service) UploadZipFile(zipData []byte, filename string) (string, error) {
 	// Generate unique key with timestamp
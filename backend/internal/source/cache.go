@@ -0,0 +1,251 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/1107-adishjain/codemap/internal/helper"
+	"github.com/1107-adishjain/codemap/internal/s3"
+)
+
+// ErrNotFound is returned when a path does not name a file in the project's tree.
+var ErrNotFound = errors.New("file not found in project source")
+
+// ErrBinary is returned for files that do not look like text.
+var ErrBinary = errors.New("file is not a text file")
+
+// MaxFileSize caps the files Read will return.
+const MaxFileSize = 2 << 20
+
+// Cache keeps extracted copies of project archives on local disk so source can be
+// served without going back to S3 for every request. Each entry is keyed by the
+// project and its S3 key, so a re-analysed project never serves a stale tree.
+// The least recently used entries are removed once more than maxEntries exist.
+type Cache struct {
+	dir        string
+	maxEntries int
+	s3         *s3.Service
+
+	mu sync.Mutex
+	// loading holds one lock per entry being fetched, so concurrent requests
+	// for the same project download the archive once.
+	loading map[string]*sync.Mutex
+}
+
+func NewCache(dir string, maxEntries int, s3Service *s3.Service) *Cache {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+	return &Cache{
+		dir:        dir,
+		maxEntries: maxEntries,
+		s3:         s3Service,
+		loading:    make(map[string]*sync.Mutex),
+	}
+}
+
+// Tree returns the directory holding the extracted archive stored under s3Key,
+// downloading and extracting it on first use.
+func (c *Cache) Tree(projectID, s3Key string) (string, error) {
+	sum := sha256.Sum256([]byte(s3Key))
+	name := projectID + "-" + hex.EncodeToString(sum[:8])
+	entry := filepath.Join(c.dir, name)
+	tree := filepath.Join(entry, "tree")
+
+	lock := c.entryLock(name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, err := os.Stat(tree); err == nil {
+		// Touch the entry so eviction sees it as recently used.
+		now := time.Now()
+		os.Chtimes(entry, now, now)
+		return tree, nil
+	}
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return "", fmt.Errorf("could not create source cache: %w", err)
+	}
+	// Extract into a scratch directory and rename it into place, so a failed or
+	// interrupted download never leaves a half-populated entry behind.
+	work, err := os.MkdirTemp(c.dir, ".fetch-*")
+	if err != nil {
+		return "", fmt.Errorf("could not create source cache entry: %w", err)
+	}
+	defer os.RemoveAll(work)
+
+	zipPath := filepath.Join(work, "archive.zip")
+	if err := c.s3.DownloadFile(s3Key, zipPath); err != nil {
+		return "", err
+	}
+	if err := helper.Unzip(zipPath, filepath.Join(work, "tree")); err != nil {
+		return "", fmt.Errorf("failed to unzip project archive: %w", err)
+	}
+	os.Remove(zipPath)
+
+	// Drop entries for older archives of the same project.
+	old, _ := filepath.Glob(filepath.Join(c.dir, projectID+"-*"))
+	for _, dir := range old {
+		os.RemoveAll(dir)
+	}
+	if err := os.Rename(work, entry); err != nil {
+		return "", fmt.Errorf("could not store source cache entry: %w", err)
+	}
+	fmt.Printf("📥 SOURCE CACHE: Extracted %s for project %s\n", s3Key, projectID)
+
+	c.evict(name)
+	return tree, nil
+}
+
+// Remove drops every cached tree of the project.
+func (c *Cache) Remove(projectID string) {
+	entries, _ := filepath.Glob(filepath.Join(c.dir, projectID+"-*"))
+	for _, entry := range entries {
+		name := filepath.Base(entry)
+		lock := c.entryLock(name)
+		lock.Lock()
+		os.RemoveAll(entry)
+		lock.Unlock()
+		c.mu.Lock()
+		delete(c.loading, name)
+		c.mu.Unlock()
+	}
+}
+
+func (c *Cache) entryLock(name string) *sync.Mutex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lock, ok := c.loading[name]
+	if !ok {
+		lock = &sync.Mutex{}
+		c.loading[name] = lock
+	}
+	return lock
+}
+
+// evict removes the least recently used entries beyond maxEntries, never keep.
+func (c *Cache) evict(keep string) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	type cached struct {
+		name string
+		used time.Time
+	}
+	var all []cached
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		all = append(all, cached{e.Name(), info.ModTime()})
+	}
+	if len(all) <= c.maxEntries {
+		return
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].used.Before(all[j].used) })
+	for _, e := range all[:len(all)-c.maxEntries] {
+		if e.name == keep {
+			continue
+		}
+		c.mu.Lock()
+		delete(c.loading, e.name)
+		c.mu.Unlock()
+		os.RemoveAll(filepath.Join(c.dir, e.name))
+	}
+}
+
+// Resolve maps a file path as stored in the graph, which is relative to the root of the
+// analysed archive, to the file at the same place in tree. Only that exact file is
+// served: a path that is not in tree, or that would escape it, is ErrNotFound.
+func Resolve(tree, path string) (string, error) {
+	parts := strings.FieldsFunc(filepath.ToSlash(path), func(r rune) bool { return r == '/' })
+	if len(parts) == 0 {
+		return "", ErrNotFound
+	}
+	for _, part := range parts {
+		if part == ".." {
+			return "", ErrNotFound
+		}
+	}
+	candidate := filepath.Join(tree, filepath.FromSlash(strings.Join(parts, "/")))
+	info, err := os.Stat(candidate)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", ErrNotFound
+	}
+	return candidate, nil
+}
+
+// Snippet is a range of lines from a source file.
+type Snippet struct {
+	StartLine  int    `json:"start_line"`
+	EndLine    int    `json:"end_line"`
+	TotalLines int    `json:"total_lines"`
+	Content    string `json:"content"`
+}
+
+// Read returns lines start through end (1-based, inclusive) of file. A zero start
+// or end extends the range to the beginning or end of the file; ranges running past
+// the end of the file are clipped.
+func Read(file string, start, end int) (*Snippet, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > MaxFileSize {
+		return nil, fmt.Errorf("file is larger than %d bytes", MaxFileSize)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return nil, ErrBinary
+	}
+
+	if start < 1 {
+		start = 1
+	}
+	snippet := &Snippet{StartLine: start}
+	var content strings.Builder
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), MaxFileSize)
+	for scanner.Scan() {
+		snippet.TotalLines++
+		line := snippet.TotalLines
+		if line >= start && (end == 0 || line <= end) {
+			content.Write(scanner.Bytes())
+			content.WriteByte('\n')
+			snippet.EndLine = line
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if snippet.EndLine == 0 {
+		// Range lies entirely past the end of the file
+		snippet.EndLine = snippet.StartLine - 1
+	}
+	snippet.Content = content.String()
+	return snippet, nil
+}
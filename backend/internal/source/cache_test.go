@@ -0,0 +1,49 @@
+package source
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	tree := t.TempDir()
+	for _, p := range []string{"main.go", "src/app.go"} {
+		file := filepath.Join(tree, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte("package main\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"main.go", "main.go"},
+		{"src/app.go", "src/app.go"},
+		{"/src//app.go", "src/app.go"},
+		// A missing file is not answered with another file of the same name.
+		{"src/main.go", ""},
+		{"other/src/app.go", ""},
+		{"src", ""},
+		{"../main.go", ""},
+		{"src/../../main.go", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, err := Resolve(tree, tt.path)
+		if tt.want == "" {
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("Resolve(%q) = %q, %v; want ErrNotFound", tt.path, got, err)
+			}
+			continue
+		}
+		if want := filepath.Join(tree, filepath.FromSlash(tt.want)); err != nil || got != want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", tt.path, got, err, want)
+		}
+	}
+}
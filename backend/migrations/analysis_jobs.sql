@@ -0,0 +1,20 @@
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS s3_key TEXT NOT NULL DEFAULT '';
+
+-- analysis_jobs is the durable queue drained by the analysis worker pool.
+-- source holds the S3 key of the archive for stored sources and the repository URL for GitHub imports.
+CREATE TABLE analysis_jobs (
+    id UUID PRIMARY KEY,
+    project_id UUID REFERENCES projects(id) ON DELETE CASCADE,
+    source_type TEXT NOT NULL,
+    source TEXT NOT NULL,
+    stage TEXT NOT NULL DEFAULT 'queued',
+    error TEXT NOT NULL DEFAULT '',
+    attempts INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT NOW(),
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX analysis_jobs_stage_idx ON analysis_jobs (stage, created_at);
+CREATE INDEX analysis_jobs_project_idx ON analysis_jobs (project_id, created_at DESC);
@@ -24,7 +24,7 @@ const GraphExplorer = ({ projectId }) => {
     setError("");
     try {
       const query = `MATCH (p:Project {id: $projectId})<-[:BELONGS_TO]-(source:File)-[r]-(target:File)-[:BELONGS_TO]->(p) RETURN source, r, target LIMIT 100`;
-      const response = await fetch("http://localhost:8080/api/v1/query", {
+      const response = await fetch(`http://localhost:8080/api/v1/query?projectId=${encodeURIComponent(projectId)}`, {
         method: "POST",
         headers: {
           Authorization: `Bearer ${localStorage.getItem("access_token")}`,
@@ -48,6 +48,15 @@ const GraphExplorer = ({ projectId }) => {
 
   // Minimal conversion for Neo4j result to Cytoscape format
   const convertToGraphFormat = (neo4jResults) => {
+    // Graph queries come back from the API already shaped as { nodes, edges }
+    if (neo4jResults && Array.isArray(neo4jResults.nodes)) {
+      return {
+        nodes: neo4jResults.nodes.map((n) => ({
+          data: { ...n, label: n.path || n.name || n.id, type: n.type || "File" }
+        })),
+        edges: (neo4jResults.edges || []).map((e) => ({ data: { ...e } }))
+      };
+    }
     if (!Array.isArray(neo4jResults)) return { nodes: [], edges: [] };
     const nodes = new Map();
     const edges = [];
@@ -89,7 +98,7 @@ const GraphExplorer = ({ projectId }) => {
     setDebugFiles(null);
     try {
       const query = `MATCH (p:Project {id: $projectId})<-[:BELONGS_TO]-(f:File) RETURN f LIMIT 20`;
-      const response = await fetch("http://localhost:8080/api/v1/query", {
+      const response = await fetch(`http://localhost:8080/api/v1/query?projectId=${encodeURIComponent(projectId)}`, {
         method: "POST",
         headers: {
           Authorization: `Bearer ${localStorage.getItem("access_token")}`,